@@ -5,8 +5,16 @@ package assets
 
 import _ "embed"
 
-//go:embed agent.Dockerfile
-var AgentBuilderDockerfile []byte
+// AgentBuilderDockerfileTemplate is the text/template of the Dockerfile of python agent images
+//
+//go:embed agent.Dockerfile.tmpl
+var AgentBuilderDockerfileTemplate []byte
+
+// AgentEntrypointDockerfile adds the manifest and the entrypoint to the final stage of agent images,
+// it is appended to the Dockerfiles of agents building their own images as well
+//
+//go:embed agent_entrypoint.Dockerfile
+var AgentEntrypointDockerfile []byte
 
 //go:embed start_agws.sh
 var StartAGWSScript []byte
@@ -1,4 +1,21 @@
 #!/usr/bin/env bash
+# export secrets mounted by docker compose into /run/secrets as env vars, the file name is the env var name
+if [ -d /run/secrets ]; then
+  for secret_file in /run/secrets/*; do
+    secret_name=$(basename "$secret_file")
+    if [ -f "$secret_file" ] && [[ "$secret_name" =~ ^[A-Za-z_][A-Za-z0-9_]*$ ]]; then
+      export "$secret_name"="$(cat "$secret_file")"
+    fi
+  done
+fi
+# images assembled without a container engine install the agent when the container starts,
+# offline from the wheels of their wheelhouse
+if [ -n "$AGENT_INSTALL_DIR" ] && [ ! -f /tmp/wfsm_agent_installed ]; then
+  : ${AGENT_WHEELHOUSE:?"agent wheelhouse must be provided"}
+  wheels=$(for wheel in "$AGENT_WHEELHOUSE"/*.whl; do basename "$wheel" | cut -d- -f1; done | sort -u)
+  poetry run pip install --no-index --find-links "$AGENT_WHEELHOUSE" $wheels || exit 1
+  touch /tmp/wfsm_agent_installed
+fi
 : ${AGENT_ID:?"agent id must be provided"}
 export AGENTS_REF="{\"$AGENT_ID\": \"$AGENT_OBJECT\"}"
 # Run the Poetry server
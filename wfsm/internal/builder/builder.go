@@ -3,17 +3,97 @@
 package builder
 
 import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/sync/errgroup"
+
 	"github.com/cisco-eti/wfsm/internal"
 	"github.com/cisco-eti/wfsm/internal/builder/container"
 	"github.com/cisco-eti/wfsm/internal/builder/python"
+	"github.com/cisco-eti/wfsm/internal/wfsm/manifest"
 	"github.com/cisco-eti/wfsm/manifests"
 )
 
-func GetAgentBuilder(deploymentOption manifests.AgentDeploymentDeploymentOptionsInner, deleteBuildFolders bool, forceBuild bool, baseImage string) internal.AgentDeploymentBuilder {
+// DefaultBuildConcurrency is the number of agents built at the same time by default
+const DefaultBuildConcurrency = 4
+
+func GetAgentBuilder(deploymentOption manifests.AgentDeploymentDeploymentOptionsInner, opts python.BuildOptions) internal.AgentDeploymentBuilder {
 	if deploymentOption.DockerDeployment != nil {
 		return container.NewContainerAgentBuilder()
 	} else if deploymentOption.SourceCodeDeployment != nil {
-		return python.NewPythonAgentBuilder(baseImage, deleteBuildFolders, forceBuild)
+		return python.NewPythonAgentBuilder(opts)
 	}
 	return nil
 }
+
+// BuildAgents builds the agents of the agent specs, at most concurrency of them at the same time.
+// When several agents are built, the log and build output of each agent is prefixed with its name.
+// The first failing build cancels the others, its error is returned.
+func BuildAgents(ctx context.Context, agentSpecs map[string]internal.AgentSpec, opts python.BuildOptions, concurrency int) (map[string]internal.AgentDeploymentBuildSpec, error) {
+	return buildAgents(ctx, agentSpecs, opts, concurrency, func(agentSpec internal.AgentSpec, opts python.BuildOptions) internal.AgentDeploymentBuilder {
+		deployment := manifest.GetDeployment(agentSpec.Manifest)
+		return GetAgentBuilder(deployment.DeploymentOptions[agentSpec.SelectedDeploymentOption], opts)
+	})
+}
+
+func buildAgents(ctx context.Context, agentSpecs map[string]internal.AgentSpec, opts python.BuildOptions, concurrency int,
+	getBuilder func(agentSpec internal.AgentSpec, opts python.BuildOptions) internal.AgentDeploymentBuilder) (map[string]internal.AgentDeploymentBuildSpec, error) {
+	if concurrency < 1 {
+		return nil, fmt.Errorf("build concurrency must be at least 1, got %d", concurrency)
+	}
+
+	out := opts.Output
+	if out == nil {
+		out = os.Stdout
+	}
+	var outLock sync.Mutex
+
+	var resultsLock sync.Mutex
+	agDeploymentSpecs := make(map[string]internal.AgentDeploymentBuildSpec, len(agentSpecs))
+
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(concurrency)
+	for _, depName := range slices.Sorted(maps.Keys(agentSpecs)) {
+		agentSpec := agentSpecs[depName]
+		group.Go(func() error {
+			// builds waiting for a worker are not started once a build failed
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			agentCtx := ctx
+			agentOpts := opts
+			if len(agentSpecs) > 1 {
+				log := zerolog.Ctx(ctx).With().Str("agent", depName).Logger()
+				agentCtx = log.WithContext(ctx)
+				agentOutput := newPrefixWriter(out, &outLock, depName)
+				defer agentOutput.Flush() //nolint:errcheck
+				agentOpts.Output = agentOutput
+			}
+
+			builder := getBuilder(agentSpec, agentOpts)
+			if builder == nil {
+				return fmt.Errorf("failed to build agent %s: unsupported deployment option", depName)
+			}
+			agdbSpec, err := builder.Build(agentCtx, agentSpec)
+			if err != nil {
+				return fmt.Errorf("failed to build agent %s: %v", depName, err)
+			}
+
+			resultsLock.Lock()
+			defer resultsLock.Unlock()
+			agDeploymentSpecs[depName] = agdbSpec
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+	return agDeploymentSpecs, nil
+}
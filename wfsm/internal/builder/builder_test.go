@@ -0,0 +1,193 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package builder
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cisco-eti/wfsm/internal"
+	"github.com/cisco-eti/wfsm/internal/builder/python"
+	"github.com/cisco-eti/wfsm/internal/builder/python/source"
+	"github.com/cisco-eti/wfsm/internal/cache"
+	"github.com/cisco-eti/wfsm/manifests"
+)
+
+// fakeBuilder builds the image agentName:latest, writing a line to the build output, or fails for the agent failing
+type fakeBuilder struct {
+	opts    python.BuildOptions
+	failing string
+	running *atomic.Int32
+	maxRun  *atomic.Int32
+	started chan string
+}
+
+func (b fakeBuilder) Build(ctx context.Context, inputSpec internal.AgentSpec) (internal.AgentDeploymentBuildSpec, error) {
+	running := b.running.Add(1)
+	defer b.running.Add(-1)
+	for {
+		maxRun := b.maxRun.Load()
+		if running <= maxRun || b.maxRun.CompareAndSwap(maxRun, running) {
+			break
+		}
+	}
+	b.started <- inputSpec.DeploymentName
+
+	fmt.Fprintf(b.opts.Output, "building %s\n", inputSpec.DeploymentName)
+	if inputSpec.DeploymentName == b.failing {
+		return internal.AgentDeploymentBuildSpec{}, fmt.Errorf("build failed")
+	}
+	if b.failing != "" {
+		// the other builds run until they are cancelled
+		<-ctx.Done()
+		return internal.AgentDeploymentBuildSpec{}, ctx.Err()
+	}
+	return internal.AgentDeploymentBuildSpec{AgentSpec: inputSpec, Image: inputSpec.DeploymentName + ":latest"}, nil
+}
+
+func TestBuildAgents(t *testing.T) {
+	agentSpecs := map[string]internal.AgentSpec{}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		agentSpecs[name] = internal.AgentSpec{DeploymentName: name}
+	}
+
+	tests := []struct {
+		name           string
+		concurrency    int
+		failing        string
+		expectedImages map[string]string
+		expectedError  string
+	}{
+		{
+			name:           "sequential",
+			concurrency:    1,
+			expectedImages: map[string]string{"a": "a:latest", "b": "b:latest", "c": "c:latest", "d": "d:latest", "e": "e:latest"},
+		},
+		{
+			name:           "concurrent",
+			concurrency:    2,
+			expectedImages: map[string]string{"a": "a:latest", "b": "b:latest", "c": "c:latest", "d": "d:latest", "e": "e:latest"},
+		},
+		{
+			name:          "first failure cancels the other builds",
+			concurrency:   3,
+			failing:       "b",
+			expectedError: "failed to build agent b: build failed",
+		},
+		{
+			name:          "invalid concurrency",
+			concurrency:   0,
+			expectedError: "build concurrency must be at least 1, got 0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			var running, maxRun atomic.Int32
+			started := make(chan string, len(agentSpecs))
+			getBuilder := func(agentSpec internal.AgentSpec, opts python.BuildOptions) internal.AgentDeploymentBuilder {
+				return fakeBuilder{opts: opts, failing: tt.failing, running: &running, maxRun: &maxRun, started: started}
+			}
+
+			agDeploymentSpecs, err := buildAgents(context.Background(), agentSpecs, python.BuildOptions{Output: &out}, tt.concurrency, getBuilder)
+			close(started)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				// builds waiting for a worker are not started after the failure
+				assert.LessOrEqual(t, len(started), tt.concurrency)
+				return
+			}
+			assert.NoError(t, err)
+
+			images := make(map[string]string, len(agDeploymentSpecs))
+			for depName, agdbSpec := range agDeploymentSpecs {
+				images[depName] = agdbSpec.Image
+			}
+			assert.Equal(t, tt.expectedImages, images)
+			assert.LessOrEqual(t, int(maxRun.Load()), tt.concurrency)
+			for depName := range agentSpecs {
+				assert.Contains(t, out.String(), fmt.Sprintf("[%s] building %s\n", depName, depName))
+			}
+		})
+	}
+}
+
+// sourceBuilder copies the source of the agent to a workspace like the python builder, without building an image
+type sourceBuilder struct {
+	opts python.BuildOptions
+	url  string
+}
+
+func (b sourceBuilder) Build(ctx context.Context, inputSpec internal.AgentSpec) (internal.AgentDeploymentBuildSpec, error) {
+	agSrc, err := source.GetAgentSource(&manifests.SourceCodeDeployment{Url: b.url}, "", nil, b.opts.SrcCache)
+	if err != nil {
+		return internal.AgentDeploymentBuildSpec{}, err
+	}
+	workspacePath, err := os.MkdirTemp("", "wfsm_workspace_")
+	if err != nil {
+		return internal.AgentDeploymentBuildSpec{}, err
+	}
+	defer os.RemoveAll(workspacePath)
+	if err := agSrc.CopyToWorkspace(ctx, workspacePath); err != nil {
+		return internal.AgentDeploymentBuildSpec{}, err
+	}
+	if _, err := os.Stat(filepath.Join(workspacePath, "agent.py")); err != nil {
+		return internal.AgentDeploymentBuildSpec{}, err
+	}
+	return internal.AgentDeploymentBuildSpec{AgentSpec: inputSpec, Image: inputSpec.DeploymentName + ":latest"}, nil
+}
+
+func TestBuildAgents_SharedSource(t *testing.T) {
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	content := []byte("print('hi')")
+	assert.NoError(t, tw.WriteHeader(&tar.Header{Name: "agent.py", Mode: 0644, Size: int64(len(content))}))
+	_, err := tw.Write(content)
+	assert.NoError(t, err)
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, gz.Close())
+	archivePath := filepath.Join(t.TempDir(), "agent.tar.gz")
+	assert.NoError(t, os.WriteFile(archivePath, archive.Bytes(), 0644))
+
+	agentSpecs := map[string]internal.AgentSpec{}
+	for _, name := range []string{"a", "b"} {
+		agentSpecs[name] = internal.AgentSpec{DeploymentName: name}
+	}
+	getBuilder := func(agentSpec internal.AgentSpec, opts python.BuildOptions) internal.AgentDeploymentBuilder {
+		return sourceBuilder{opts: opts, url: "file://" + archivePath}
+	}
+	// the builds of the agents fetch and commit the same source tree at the same time
+	for i := 0; i < 10; i++ {
+		opts := python.BuildOptions{Output: &bytes.Buffer{}, SrcCache: cache.NewCache(t.TempDir(), false)}
+		agDeploymentSpecs, err := buildAgents(context.Background(), agentSpecs, opts, len(agentSpecs), getBuilder)
+		assert.NoError(t, err)
+		assert.Len(t, agDeploymentSpecs, len(agentSpecs))
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	var lock sync.Mutex
+	w := newPrefixWriter(&out, &lock, "mailcomposer")
+
+	for _, s := range []string{"Step 1/3\nStep", " 2/3\n", "pulling 10%\rpulling 100%\r\n\n", "done"} {
+		n, err := w.Write([]byte(s))
+		assert.NoError(t, err)
+		assert.Equal(t, len(s), n)
+	}
+	assert.Equal(t, "[mailcomposer] Step 1/3\n[mailcomposer] Step 2/3\n[mailcomposer] pulling 10%\n[mailcomposer] pulling 100%\n", out.String())
+
+	assert.NoError(t, w.Flush())
+	assert.Equal(t, "[mailcomposer] Step 1/3\n[mailcomposer] Step 2/3\n[mailcomposer] pulling 10%\n[mailcomposer] pulling 100%\n[mailcomposer] done\n", out.String())
+}
@@ -0,0 +1,71 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package builder
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// prefixWriter prefixes the lines written to it with the name of an agent, so the output of concurrent builds
+// stays readable. Lines are written to the shared writer as a whole, the writers of the builds share the lock of out.
+type prefixWriter struct {
+	out    io.Writer
+	lock   *sync.Mutex
+	prefix []byte
+	buf    []byte
+}
+
+func newPrefixWriter(out io.Writer, lock *sync.Mutex, agentName string) *prefixWriter {
+	return &prefixWriter{
+		out:    out,
+		lock:   lock,
+		prefix: []byte("[" + agentName + "] "),
+	}
+}
+
+// Write writes the complete lines of p, a partial line at the end is kept until the rest of it is written
+// or the writer is flushed. Carriage returns end a line as well, they are used to render progress in place.
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	var lines []byte
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		line := w.buf[:i]
+		w.buf = w.buf[i+1:]
+		if len(line) == 0 {
+			continue
+		}
+		lines = append(lines, w.prefix...)
+		lines = append(lines, line...)
+		lines = append(lines, '\n')
+	}
+	if err := w.write(lines); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes the partial line kept by the writer
+func (w *prefixWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	line := append(append(append([]byte{}, w.prefix...), w.buf...), '\n')
+	w.buf = w.buf[:0]
+	return w.write(line)
+}
+
+func (w *prefixWriter) write(lines []byte) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	_, err := w.out.Write(lines)
+	return err
+}
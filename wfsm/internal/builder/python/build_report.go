@@ -0,0 +1,165 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package python
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	dockerclient "github.com/docker/docker/client"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/rs/zerolog"
+
+	"github.com/cisco-eti/wfsm/internal/buildreport"
+	"github.com/cisco-eti/wfsm/internal/util"
+)
+
+// recordBuild generates the SBOM of the built image and stores it with the build report of the image.
+// The SBOM lists the base image and the python packages of the image, see imagePackages.
+// The SBOM of pushed images is attached to them as an OCI referrer, the one of OCI layout tarballs is written next to them.
+func recordBuild(ctx context.Context, client dockerclient.APIClient, img string, workspacePath string, agentSourceDir string, baseImage string, labels map[string]string, opts BuildOptions) error {
+	log := zerolog.Ctx(ctx)
+
+	report := buildreport.Report{
+		Image:      img,
+		BuiltAt:    time.Now().UTC(),
+		BaseImage:  baseImage,
+		Platforms:  buildPlatforms(opts),
+		Pushed:     opts.Push,
+		OutputPath: opts.OutputPath,
+		Labels:     labels,
+		SBOMFormat: opts.sbomFormat(),
+	}
+	if len(report.Platforms) == 0 {
+		report.Platforms = []string{util.CurrentArchToDockerPlatform()}
+	}
+
+	packages, packagesSource, packagesFallback, err := imagePackages(ctx, client, img, report.Platforms, workspacePath, agentSourceDir, opts)
+	if err != nil {
+		return fmt.Errorf("failed to list the python packages of image %s: %v", img, err)
+	}
+	report.PackagesSource = packagesSource
+	report.PackagesFallback = packagesFallback
+	report.BaseImageDigest = baseImageDigest(ctx, client, baseImage)
+
+	sbom, err := buildreport.SBOM{
+		Format:           report.SBOMFormat,
+		Image:            img,
+		BaseImage:        baseImage,
+		BaseImageDigest:  report.BaseImageDigest,
+		Packages:         packages,
+		PackagesSource:   packagesSource,
+		PackagesFallback: packagesFallback,
+	}.Generate()
+	if err != nil {
+		return fmt.Errorf("failed to generate SBOM of image %s: %v", img, err)
+	}
+
+	if opts.Push {
+		report.SBOMReferrer, err = buildreport.PushSBOM(ctx, img, report.SBOMFormat, sbom)
+		if err != nil {
+			return err
+		}
+		log.Info().Str("image", img).Str("referrer", report.SBOMReferrer).Msg("SBOM attached to image")
+	} else if opts.OutputPath != "" {
+		sbomPath := opts.OutputPath + "." + buildreport.SBOMFileName(report.SBOMFormat)
+		if err := os.WriteFile(sbomPath, sbom, util.OwnerCanReadWrite); err != nil {
+			return fmt.Errorf("failed to write SBOM of image %s: %v", img, err)
+		}
+		log.Info().Str("image", img).Str("path", sbomPath).Msg("SBOM written next to OCI layout tarball")
+	}
+
+	if opts.Reports != nil {
+		if err := opts.Reports.Write(report, sbom); err != nil {
+			return err
+		}
+	}
+	log.Info().Str("image", img).Int("packages", len(packages)).Str("format", report.SBOMFormat).Msg("build report and SBOM stored")
+	return nil
+}
+
+// imagePackages returns the python packages of the image, where they were taken from and the reason of the fallback to
+// the locked packages if any. They are listed by pip in a container of the image if it is in the local image store of client
+// and built for the platform of the host, otherwise (or if the container fails, e.g. because it can't be run without emulation)
+// they are taken from the dependency files of the agent.
+func imagePackages(ctx context.Context, client dockerclient.ContainerAPIClient, img string, platforms []string, workspacePath string, agentSourceDir string, opts BuildOptions) ([]buildreport.Package, string, string, error) {
+	log := zerolog.Ctx(ctx)
+
+	fallback := ""
+	if client != nil && !opts.Push {
+		if !hostPlatforms(platforms) {
+			fallback = fmt.Sprintf("image built for platforms %s, not the platform of the host", strings.Join(platforms, ","))
+		} else {
+			packages, err := installedPackages(ctx, client, img)
+			if err == nil {
+				return packages, buildreport.InstalledPackages, "", nil
+			}
+			fallback = fmt.Sprintf("failed to list the python packages installed in the image: %v", err)
+		}
+		log.Warn().Str("image", img).Str("reason", fallback).Msg("the SBOM lists the python packages pinned by the dependency files of the agent instead of the installed ones")
+	}
+	wheelhousePath := ""
+	if opts.Daemonless && opts.Wheelhouse != "" {
+		wheelhousePath = path.Join(workspacePath, wheelhouseDir)
+	}
+	packages, err := lockedPackages(path.Join(workspacePath, agentSourceDir), wheelhousePath)
+	return packages, buildreport.LockedPackages, fallback, err
+}
+
+// hostPlatforms returns true if all the platforms have the OS and the architecture of the host, so containers of images
+// built for them run without emulation
+func hostPlatforms(platforms []string) bool {
+	host, err := v1.ParsePlatform(util.CurrentArchToDockerPlatform())
+	if err != nil {
+		return false
+	}
+	for _, platform := range platforms {
+		parsed, err := v1.ParsePlatform(platform)
+		if err != nil || parsed.OS != host.OS || parsed.Architecture != host.Architecture {
+			return false
+		}
+	}
+	return true
+}
+
+// buildPlatforms returns the platforms the image is built for, daemonless images are built for the platform of the host by default
+func buildPlatforms(opts BuildOptions) []string {
+	if opts.Daemonless && len(opts.Platforms) == 0 {
+		return []string{util.CurrentArchToDockerPlatform()}
+	}
+	return opts.Platforms
+}
+
+// baseImageDigest returns the digest of the base image, the one it was pulled by if it is in the local image store of client,
+// or the one in its registry. It is empty if the digest cannot be found, e.g. for base images built locally.
+func baseImageDigest(ctx context.Context, client dockerclient.ImageAPIClient, baseImage string) string {
+	log := zerolog.Ctx(ctx)
+
+	if client != nil {
+		inspect, err := client.ImageInspect(ctx, baseImage)
+		if err == nil {
+			for _, repoDigest := range inspect.RepoDigests {
+				if _, digest, ok := strings.Cut(repoDigest, "@"); ok {
+					return digest
+				}
+			}
+		}
+	}
+	ref, err := name.ParseReference(baseImage)
+	if err != nil {
+		return ""
+	}
+	desc, err := remote.Head(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithContext(ctx))
+	if err != nil {
+		log.Warn().Err(err).Str("image", baseImage).Msg("failed to get the digest of the base image")
+		return ""
+	}
+	return desc.Digest.String()
+}
@@ -0,0 +1,74 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package python
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	dockerclient "github.com/docker/docker/client"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cisco-eti/wfsm/internal/buildreport"
+	"github.com/cisco-eti/wfsm/internal/util"
+)
+
+// failingContainerClient fails to create containers, like engines which can't run images of foreign platforms
+type failingContainerClient struct {
+	dockerclient.ContainerAPIClient
+	created int
+}
+
+func (c *failingContainerClient) ContainerCreate(context.Context, *container.Config, *container.HostConfig, *network.NetworkingConfig, *ocispec.Platform, string) (container.CreateResponse, error) {
+	c.created++
+	return container.CreateResponse{}, errors.New("exec format error")
+}
+
+func TestImagePackages(t *testing.T) {
+	workspacePath := t.TempDir()
+	assert.NoError(t, os.MkdirAll(path.Join(workspacePath, "agent"), 0755))
+	assert.NoError(t, os.WriteFile(path.Join(workspacePath, "agent", "requirements.txt"), []byte("langgraph==0.2.60\n"), 0644))
+	expectedPackages := []buildreport.Package{{Name: "langgraph", Version: "0.2.60"}}
+
+	tests := []struct {
+		name             string
+		platforms        []string
+		opts             BuildOptions
+		expectedCreated  int
+		expectedFallback string
+	}{
+		{
+			name:             "pip list fails",
+			platforms:        []string{util.CurrentArchToDockerPlatform()},
+			expectedCreated:  1,
+			expectedFallback: "failed to list the python packages installed in the image: failed to create container: exec format error",
+		},
+		{
+			name:             "foreign platform",
+			platforms:        []string{"linux/s390x"},
+			expectedFallback: "image built for platforms linux/s390x, not the platform of the host",
+		},
+		{
+			name:      "pushed image",
+			platforms: []string{util.CurrentArchToDockerPlatform()},
+			opts:      BuildOptions{Push: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &failingContainerClient{}
+			packages, packagesSource, packagesFallback, err := imagePackages(context.Background(), client, "agntcy/wfsm-mailcomposer:0123abcd", tt.platforms, workspacePath, "agent", tt.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, buildreport.LockedPackages, packagesSource)
+			assert.Equal(t, tt.expectedFallback, packagesFallback)
+			assert.Equal(t, expectedPackages, packages)
+			assert.Equal(t, tt.expectedCreated, client.created)
+		})
+	}
+}
@@ -5,29 +5,124 @@ package python
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
 	"strings"
 
 	"github.com/cisco-eti/wfsm/internal"
 	"github.com/cisco-eti/wfsm/internal/builder/python/source"
+	"github.com/cisco-eti/wfsm/internal/buildreport"
+	"github.com/cisco-eti/wfsm/internal/cache"
+	"github.com/cisco-eti/wfsm/internal/engine"
 	"github.com/cisco-eti/wfsm/internal/wfsm/manifest"
+	"github.com/cisco-eti/wfsm/manifests"
 
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/rs/zerolog"
 )
 
 const AgentImage = "agntcy/wfsm"
 
+// BuildOptions are the options of building python agent images
+type BuildOptions struct {
+	// BaseImage is the workflow server image the agent images are based on, the latest one if empty
+	BaseImage          string
+	DeleteBuildFolders bool
+	// ForceBuild builds the images without build cache even if they exist
+	ForceBuild bool
+	// Platforms are the platforms (e.g. linux/amd64) the images are built for, the platform of the host if empty
+	Platforms []string
+	// Registry is prepended to the names of the images, e.g. ghcr.io/org
+	Registry string
+	// Push pushes the images to the registry instead of storing them in the local image store
+	Push bool
+	// Daemonless assembles the images with go-containerregistry instead of building them with a container engine
+	Daemonless bool
+	// OutputPath is the OCI layout tarball daemonless images are written to if they are not pushed
+	OutputPath string
+	// Wheelhouse is a dir of wheels of the agent and its dependencies added to daemonless images,
+	// they are installed offline when the container starts
+	Wheelhouse string
+	// Engine is the container engine the images are built with, docker if empty
+	Engine string
+	// PackageIndex is the python package index the dependencies of the agents are installed from, PyPI if not set
+	PackageIndex PackageIndex
+	// SBOMFormat is the format of the SBOMs of the images, cyclonedx if empty
+	SBOMFormat string
+	// Reports stores the build reports and SBOMs of the built images, they are not stored if nil
+	Reports *buildreport.Store
+	// Output is where the output of the image builds is written to, stdout if nil,
+	// e.g. a writer prefixing the lines with the name of the agent when several agents are built concurrently
+	Output   io.Writer
+	SrcCache *cache.Cache
+}
+
+// sbomFormat returns the format of the SBOMs of the images
+func (o BuildOptions) sbomFormat() string {
+	if o.SBOMFormat == "" {
+		return buildreport.CycloneDX
+	}
+	return o.SBOMFormat
+}
+
+// output returns the writer of the output of the image builds
+func (o BuildOptions) output() io.Writer {
+	if o.Output == nil {
+		return os.Stdout
+	}
+	return o.Output
+}
+
+// Validate checks the options, images for several platforms can only be pushed to a registry
+// as the local image store of docker holds one platform of an image, daemonless images are never stored locally.
+// Images for other platforms and pushed images are built with docker buildx, which is not available with podman.
+func (o BuildOptions) Validate() error {
+	if err := engine.Validate(o.Engine); err != nil {
+		return err
+	}
+	if err := buildreport.ValidateSBOMFormat(o.sbomFormat()); err != nil {
+		return err
+	}
+	for _, platform := range o.Platforms {
+		if _, err := v1.ParsePlatform(platform); err != nil || !strings.Contains(platform, "/") {
+			return fmt.Errorf("invalid platform %s, expected os/arch[/variant] (e.g. linux/amd64)", platform)
+		}
+	}
+	if o.Push && o.Registry == "" {
+		return fmt.Errorf("pushing images requires a registry (e.g. ghcr.io/org)")
+	}
+	if o.Daemonless {
+		if o.Push == (o.OutputPath != "") {
+			return fmt.Errorf("daemonless images are either pushed to a registry or written to an OCI layout tarball")
+		}
+		if o.PackageIndex.IsSet() {
+			return fmt.Errorf("package indexes are not supported by daemonless builds, the dependencies are installed from the wheelhouse")
+		}
+		if o.Wheelhouse == "" {
+			return fmt.Errorf("daemonless builds require a wheelhouse with the wheels of the agent and its dependencies")
+		}
+		return nil
+	}
+	if o.OutputPath != "" || o.Wheelhouse != "" {
+		return fmt.Errorf("OCI layout tarballs and wheelhouses are only supported by daemonless builds")
+	}
+	if o.Engine == engine.Podman && (o.Push || len(o.Platforms) > 0) {
+		return fmt.Errorf("building images for other platforms and pushing them is not supported with podman, use daemonless builds")
+	}
+	if len(o.Platforms) > 1 && !o.Push {
+		return fmt.Errorf("building images for several platforms (%s) requires pushing them to a registry", strings.Join(o.Platforms, ","))
+	}
+	return nil
+}
+
 // builder implementation of AgentDeployer
 type pyBuilder struct {
-	baseImage          string
-	deleteBuildFolders bool
-	forceBuild         bool
+	opts BuildOptions
 }
 
-func NewPythonAgentBuilder(baseImage string, deleteBuildFolders bool, forceBuild bool) internal.AgentDeploymentBuilder {
+func NewPythonAgentBuilder(opts BuildOptions) internal.AgentDeploymentBuilder {
 	return &pyBuilder{
-		baseImage:          baseImage,
-		deleteBuildFolders: deleteBuildFolders,
-		forceBuild:         forceBuild,
+		opts: opts,
 	}
 }
 
@@ -39,13 +134,16 @@ func (b *pyBuilder) Build(ctx context.Context, inputSpec internal.AgentSpec) (in
 
 	deploymentManifest := manifest.GetDeployment(inputSpec.Manifest)
 	deployment := deploymentManifest.DeploymentOptions[inputSpec.SelectedDeploymentOption]
-	agSrc, err := source.GetAgentSource(deployment.SourceCodeDeployment, inputSpec.ManifestPath)
+	agSrc, err := source.GetAgentSource(deployment.SourceCodeDeployment, inputSpec.ManifestPath, inputSpec.Manifest.Locators, b.opts.SrcCache)
 	if err != nil {
 		return deploymentSpec, fmt.Errorf("failed to get agent source: %v", err)
 	}
 
-	imageName := strings.Join([]string{AgentImage, inputSpec.Manifest.Name}, "-")
-	imgNameWithTag, err := EnsureContainerImage(ctx, imageName, agSrc, inputSpec, b.deleteBuildFolders, b.forceBuild, b.baseImage)
+	imageName := ImageName(inputSpec.Manifest)
+	if b.opts.Registry != "" {
+		imageName = strings.TrimSuffix(b.opts.Registry, "/") + "/" + imageName
+	}
+	imgNameWithTag, err := EnsureContainerImage(ctx, imageName, agSrc, inputSpec, b.opts)
 	if err != nil {
 		return deploymentSpec, err
 	}
@@ -53,5 +151,12 @@ func (b *pyBuilder) Build(ctx context.Context, inputSpec internal.AgentSpec) (in
 	log.Debug().Msg(fmt.Sprintf("agent image: %s", imgNameWithTag))
 	deploymentSpec.Image = imgNameWithTag
 	deploymentSpec.ServiceName = inputSpec.DeploymentName
+	// the entrypoint added by wfsm to the image reads the secrets mounted into /run/secrets
+	deploymentSpec.SecretFiles = true
 	return deploymentSpec, nil
 }
+
+// ImageName returns the name of the image built for the agent, the tag is calculated from the agent source during the build
+func ImageName(agentManifest manifests.AgentManifest) string {
+	return strings.Join([]string{AgentImage, agentManifest.Name}, "-")
+}
@@ -0,0 +1,82 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package python
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	controlapi "github.com/moby/buildkit/api/services/control"
+)
+
+// buildKitTraceID is the id of the build log messages carrying the BuildKit build status
+const buildKitTraceID = "moby.buildkit.trace"
+
+// buildKitTrace prints the steps of a BuildKit build with their output, like the plain progress output of docker build
+type buildKitTrace struct {
+	out io.Writer
+	// steps are the numbers of the printed vertexes
+	steps map[string]int
+	// done are the vertexes printed as completed
+	done map[string]bool
+}
+
+func newBuildKitTrace(out io.Writer) *buildKitTrace {
+	return &buildKitTrace{out: out, steps: map[string]int{}, done: map[string]bool{}}
+}
+
+// display prints the status in the aux field of a build log message
+func (t *buildKitTrace) display(aux json.RawMessage) error {
+	var data []byte
+	if err := json.Unmarshal(aux, &data); err != nil {
+		return fmt.Errorf("failed to unmarshal build status: %w", err)
+	}
+	var status controlapi.StatusResponse
+	if err := status.UnmarshalVT(data); err != nil {
+		return fmt.Errorf("failed to unmarshal build status: %w", err)
+	}
+
+	for _, vertex := range status.Vertexes {
+		if vertex.Started == nil && !vertex.Cached {
+			continue
+		}
+		step := t.step(vertex.Digest, vertex.Name)
+		if t.done[vertex.Digest] {
+			continue
+		}
+		switch {
+		case vertex.Cached:
+			fmt.Fprintf(t.out, "#%d CACHED\n", step)
+		case vertex.Error != "":
+			fmt.Fprintf(t.out, "#%d ERROR: %s\n", step, vertex.Error)
+		case vertex.Completed != nil:
+			fmt.Fprintf(t.out, "#%d DONE %.1fs\n", step, vertex.Completed.AsTime().Sub(vertex.Started.AsTime()).Seconds())
+		default:
+			continue
+		}
+		t.done[vertex.Digest] = true
+	}
+	for _, log := range status.Logs {
+		step, ok := t.steps[log.Vertex]
+		if !ok {
+			continue
+		}
+		for _, line := range bytes.Split(bytes.TrimRight(log.Msg, "\n"), []byte("\n")) {
+			fmt.Fprintf(t.out, "#%d %s\n", step, line)
+		}
+	}
+	return nil
+}
+
+// step returns the number of the vertex, printing its name the first time it is seen
+func (t *buildKitTrace) step(digest string, name string) int {
+	if step, ok := t.steps[digest]; ok {
+		return step
+	}
+	step := len(t.steps) + 1
+	t.steps[digest] = step
+	fmt.Fprintf(t.out, "#%d %s\n", step, name)
+	return step
+}
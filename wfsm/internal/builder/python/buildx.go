@@ -0,0 +1,242 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package python
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/cisco-eti/wfsm/internal"
+	"github.com/cisco-eti/wfsm/internal/util"
+	"github.com/docker/docker/api/types/image"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/rs/zerolog"
+)
+
+// buildImageWithBuildx builds the agent image with docker buildx for the platforms of the options.
+// The image is pushed to its registry (as a multi-arch index for several platforms) or loaded into the local image store of client.
+func buildImageWithBuildx(ctx context.Context, client dockerclient.APIClient, img string, workspacePath string, inputSpec internal.AgentSpec, agentSourceDir string, baseImage string, labels map[string]string, opts BuildOptions) error {
+	log := zerolog.Ctx(ctx)
+
+	dockerFile, err := agentDockerfile(path.Join(workspacePath, agentSourceDir), inputSpec.Dockerfile, true, opts.PackageIndex.secretMounts())
+	if err != nil {
+		return err
+	}
+	if err := writeBuildFiles(workspacePath, dockerFile); err != nil {
+		return err
+	}
+	buildArgs, err := getBuildArgs(inputSpec, agentSourceDir, baseImage)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"buildx", "build", "--tag", img, "--file", path.Join(workspacePath, "Dockerfile")}
+	if len(opts.Platforms) > 0 {
+		args = append(args, "--platform", strings.Join(opts.Platforms, ","))
+	}
+	buildArgNames := make([]string, 0, len(buildArgs))
+	for buildArg := range buildArgs {
+		buildArgNames = append(buildArgNames, buildArg)
+	}
+	slices.Sort(buildArgNames)
+	for _, buildArg := range buildArgNames {
+		args = append(args, "--build-arg", fmt.Sprintf("%s=%s", buildArg, *buildArgs[buildArg]))
+	}
+	for _, label := range slices.Sorted(maps.Keys(labels)) {
+		args = append(args, "--label", fmt.Sprintf("%s=%s", label, labels[label]))
+	}
+	if opts.ForceBuild {
+		args = append(args, "--no-cache")
+	}
+	if opts.PackageIndex.IsSet() {
+		// the secret files are kept out of the build context
+		secretsDir, err := os.MkdirTemp("", "wfsm_secrets_")
+		if err != nil {
+			return fmt.Errorf("failed to create build secrets dir: %v", err)
+		}
+		defer os.RemoveAll(secretsDir)
+		secretArgs, err := writeSecretFiles(secretsDir, opts.PackageIndex)
+		if err != nil {
+			return err
+		}
+		args = append(args, secretArgs...)
+	}
+	if opts.Push {
+		args = append(args, "--push")
+	} else {
+		args = append(args, "--load")
+	}
+	args = append(args, workspacePath)
+
+	log.Info().Str("image", img).Strs("platforms", opts.Platforms).Bool("push", opts.Push).Msg("building image with buildx")
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdout = opts.output()
+	cmd.Stderr = opts.output()
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to build image %s with docker buildx: %v", img, err)
+	}
+
+	if inputSpec.Dockerfile != "" {
+		if err := checkBuildxImage(ctx, client, img, baseImage, opts); err != nil {
+			return fmt.Errorf("image %s built from dockerfile %s is invalid: %w", img, inputSpec.Dockerfile, err)
+		}
+	}
+	log.Info().Msg("successfully built image")
+	return nil
+}
+
+// checkBuildxImage checks an image built with buildx from the dockerfile of the agent. An image loaded for the platform of the host
+// is checked for the workflow server in a container. Images of other platforms or pushed images cannot be run, their first layers
+// must be the layers of the base image for their platform instead.
+func checkBuildxImage(ctx context.Context, client dockerclient.APIClient, img string, baseImage string, opts BuildOptions) error {
+	if !opts.Push && hostPlatforms(opts.Platforms) {
+		return validateWorkflowServer(ctx, client, img)
+	}
+
+	platforms := opts.Platforms
+	if len(platforms) == 0 {
+		platforms = []string{util.CurrentArchToDockerPlatform()}
+	}
+	for _, platform := range platforms {
+		baseLayers, err := remoteLayers(ctx, baseImage, platform)
+		if err != nil {
+			return fmt.Errorf("failed to get the layers of base image %s: %w", baseImage, err)
+		}
+		var imgLayers []string
+		if opts.Push {
+			imgLayers, err = remoteLayers(ctx, img, platform)
+		} else {
+			// buildx loads a single platform into the local image store
+			var inspect image.InspectResponse
+			inspect, err = client.ImageInspect(ctx, img)
+			imgLayers = inspect.RootFS.Layers
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get the layers of image %s: %w", img, err)
+		}
+		if len(imgLayers) < len(baseLayers) || !slices.Equal(imgLayers[:len(baseLayers)], baseLayers) {
+			return fmt.Errorf("the image for platform %s is not based on base image %s, the final stage must be based on $BASE_IMAGE", platform, baseImage)
+		}
+	}
+	return nil
+}
+
+// remoteLayers returns the diff IDs of the layers of the image for the platform in its registry
+func remoteLayers(ctx context.Context, img string, platform string) ([]string, error) {
+	ref, err := name.ParseReference(img)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image name %s: %v", img, err)
+	}
+	p, err := v1.ParsePlatform(platform)
+	if err != nil {
+		return nil, fmt.Errorf("invalid platform %s: %v", platform, err)
+	}
+	remoteImg, err := remote.Image(ref, remote.WithPlatform(*p), remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	configFile, err := remoteImg.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	layers := make([]string, 0, len(configFile.RootFS.DiffIDs))
+	for _, diffID := range configFile.RootFS.DiffIDs {
+		layers = append(layers, diffID.String())
+	}
+	return layers, nil
+}
+
+// findRemoteImage checks whether the image exists in its registry for all the platforms
+func findRemoteImage(ctx context.Context, img string, platforms []string) (bool, error) {
+	log := zerolog.Ctx(ctx)
+
+	ref, err := name.ParseReference(img)
+	if err != nil {
+		return false, fmt.Errorf("invalid image name %s: %v", img, err)
+	}
+	desc, err := remote.Get(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithContext(ctx))
+	if err != nil {
+		var transportErr *transport.Error
+		if errors.As(err, &transportErr) && transportErr.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to look up image %s in registry: %v", img, err)
+	}
+
+	supported, err := imagePlatforms(desc)
+	if err != nil {
+		return false, fmt.Errorf("failed to get the platforms of image %s: %v", img, err)
+	}
+	if missing := missingPlatforms(platforms, supported); len(missing) > 0 {
+		log.Info().Str("image", img).Strs("platforms", missing).Msg("image found in registry without some of the platforms")
+		return false, nil
+	}
+	log.Info().Str("image", img).Msg("image found in registry")
+	return true, nil
+}
+
+// imagePlatforms returns the platforms of the images of an index, or the platform of an image
+func imagePlatforms(desc *remote.Descriptor) ([]v1.Platform, error) {
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return nil, err
+		}
+		indexManifest, err := idx.IndexManifest()
+		if err != nil {
+			return nil, err
+		}
+		platforms := make([]v1.Platform, 0, len(indexManifest.Manifests))
+		for _, manifest := range indexManifest.Manifests {
+			// attestations pushed by buildx have the platform unknown/unknown
+			if manifest.Platform != nil && manifest.Platform.OS != "unknown" {
+				platforms = append(platforms, *manifest.Platform)
+			}
+		}
+		return platforms, nil
+	}
+
+	img, err := desc.Image()
+	if err != nil {
+		return nil, err
+	}
+	configFile, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	return []v1.Platform{{OS: configFile.OS, Architecture: configFile.Architecture, Variant: configFile.Variant}}, nil
+}
+
+// missingPlatforms returns the requested platforms none of the supported platforms satisfy,
+// e.g. linux/arm64 is satisfied by linux/arm64/v8
+func missingPlatforms(requested []string, supported []v1.Platform) []string {
+	missing := make([]string, 0)
+	for _, platform := range requested {
+		spec, err := v1.ParsePlatform(platform)
+		if err != nil || !slices.ContainsFunc(supported, func(p v1.Platform) bool { return p.Satisfies(*spec) }) {
+			missing = append(missing, platform)
+		}
+	}
+	return missing
+}
+
+func platformsString(platforms []v1.Platform) string {
+	platformStrs := make([]string, 0, len(platforms))
+	for _, platform := range platforms {
+		platformStrs = append(platformStrs, platform.String())
+	}
+	return strings.Join(platformStrs, ", ")
+}
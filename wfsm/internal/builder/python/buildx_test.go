@@ -0,0 +1,207 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package python
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// pushIndex pushes an index with an image for each platform to the registry
+func pushIndex(t *testing.T, ref string, platforms ...string) {
+	idx := v1.ImageIndex(empty.Index)
+	for _, platform := range platforms {
+		img, err := random.Image(64, 1)
+		assert.NoError(t, err)
+		p, err := v1.ParsePlatform(platform)
+		assert.NoError(t, err)
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{Add: img, Descriptor: v1.Descriptor{Platform: p}})
+	}
+	// attestation manifests of buildx are ignored
+	attestation, err := random.Image(64, 1)
+	assert.NoError(t, err)
+	idx = mutate.AppendManifests(idx, mutate.IndexAddendum{Add: attestation, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "unknown", Architecture: "unknown"}}})
+
+	tag, err := name.NewTag(ref)
+	assert.NoError(t, err)
+	assert.NoError(t, remote.WriteIndex(tag, idx))
+}
+
+func TestGetBaseImage_Platforms(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+
+	baseImage := u.Host + "/agntcy/acp/wfsrv:0.1.0"
+	pushIndex(t, baseImage, "linux/amd64", "linux/arm64/v8")
+
+	tests := []struct {
+		name        string
+		platforms   []string
+		expectedErr string
+	}{
+		{name: "host platform", platforms: nil},
+		{name: "supported platforms", platforms: []string{"linux/amd64", "linux/arm64"}},
+		{name: "unsupported platform", platforms: []string{"linux/amd64", "linux/s390x"},
+			expectedErr: "base image " + baseImage + " does not support platforms linux/s390x, it supports linux/amd64, linux/arm64/v8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getBaseImage(baseImage, WorkflowServerRepo, tt.platforms)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, baseImage, got)
+		})
+	}
+}
+
+func TestFindRemoteImage(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+
+	img := u.Host + "/org/agntcy/wfsm-agent:1234"
+	pushIndex(t, img, "linux/amd64")
+
+	singleImg := u.Host + "/org/agntcy/wfsm-single:1234"
+	randomImg, err := random.Image(64, 1)
+	assert.NoError(t, err)
+	randomImg, err = mutate.ConfigFile(randomImg, &v1.ConfigFile{OS: "linux", Architecture: "arm64"})
+	assert.NoError(t, err)
+	tag, err := name.NewTag(singleImg)
+	assert.NoError(t, err)
+	assert.NoError(t, remote.Write(tag, randomImg))
+
+	tests := []struct {
+		name      string
+		img       string
+		platforms []string
+		expected  bool
+	}{
+		{name: "index with all platforms", img: img, platforms: []string{"linux/amd64"}, expected: true},
+		{name: "index without some platforms", img: img, platforms: []string{"linux/amd64", "linux/arm64"}, expected: false},
+		{name: "image of the platform", img: singleImg, platforms: []string{"linux/arm64"}, expected: true},
+		{name: "image of another platform", img: singleImg, platforms: []string{"linux/amd64"}, expected: false},
+		{name: "missing tag", img: u.Host + "/org/agntcy/wfsm-agent:5678", expected: false},
+		{name: "missing repository", img: u.Host + "/org/agntcy/wfsm-other:1234", expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := findRemoteImage(context.Background(), tt.img, tt.platforms)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, found)
+		})
+	}
+}
+
+func TestBuildOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        BuildOptions
+		expectedErr string
+	}{
+		{name: "host platform", opts: BuildOptions{}},
+		{name: "single platform", opts: BuildOptions{Platforms: []string{"linux/amd64"}}},
+		{name: "pushed platforms", opts: BuildOptions{Platforms: []string{"linux/amd64", "linux/arm64"}, Push: true, Registry: "ghcr.io/org"}},
+		{name: "several platforms without push", opts: BuildOptions{Platforms: []string{"linux/amd64", "linux/arm64"}},
+			expectedErr: "building images for several platforms (linux/amd64,linux/arm64) requires pushing them to a registry"},
+		{name: "invalid platform", opts: BuildOptions{Platforms: []string{"amd64"}},
+			expectedErr: "invalid platform amd64, expected os/arch[/variant] (e.g. linux/amd64)"},
+		{name: "push without registry", opts: BuildOptions{Push: true},
+			expectedErr: "pushing images requires a registry (e.g. ghcr.io/org)"},
+		{name: "daemonless platforms in OCI layout", opts: BuildOptions{Platforms: []string{"linux/amd64", "linux/arm64"}, Daemonless: true, OutputPath: "agent.tar", Wheelhouse: "wheels"}},
+		{name: "daemonless without wheelhouse", opts: BuildOptions{Daemonless: true, OutputPath: "agent.tar"},
+			expectedErr: "daemonless builds require a wheelhouse with the wheels of the agent and its dependencies"},
+		{name: "daemonless without output", opts: BuildOptions{Daemonless: true},
+			expectedErr: "daemonless images are either pushed to a registry or written to an OCI layout tarball"},
+		{name: "wheelhouse without daemonless", opts: BuildOptions{Wheelhouse: "wheels"},
+			expectedErr: "OCI layout tarballs and wheelhouses are only supported by daemonless builds"},
+		{name: "daemonless with package index", opts: BuildOptions{Daemonless: true, OutputPath: "agent.tar", PackageIndex: PackageIndex{IndexURL: "https://pypi.example.com/simple"}},
+			expectedErr: "package indexes are not supported by daemonless builds, the dependencies are installed from the wheelhouse"},
+		{name: "podman", opts: BuildOptions{Engine: "podman"}},
+		{name: "podman daemonless push", opts: BuildOptions{Engine: "podman", Daemonless: true, Push: true, Registry: "ghcr.io/org", Wheelhouse: "wheels"}},
+		{name: "podman platforms", opts: BuildOptions{Engine: "podman", Platforms: []string{"linux/amd64"}},
+			expectedErr: "building images for other platforms and pushing them is not supported with podman, use daemonless builds"},
+		{name: "unsupported engine", opts: BuildOptions{Engine: "containerd"},
+			expectedErr: "unsupported container engine containerd, supported engines: docker, podman"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestCheckBuildxImage_Push(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+
+	platform := "linux/amd64"
+	p, err := v1.ParsePlatform(platform)
+	assert.NoError(t, err)
+	pushImage := func(ref string, img v1.Image) {
+		idx := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: img, Descriptor: v1.Descriptor{Platform: p}})
+		tag, err := name.NewTag(ref)
+		assert.NoError(t, err)
+		assert.NoError(t, remote.WriteIndex(tag, idx))
+	}
+
+	base, err := random.Image(64, 2)
+	assert.NoError(t, err)
+	baseImage := u.Host + "/agntcy/acp/wfsrv:0.1.0"
+	pushImage(baseImage, base)
+
+	layer, err := random.Layer(64, types.DockerLayer)
+	assert.NoError(t, err)
+	based, err := mutate.AppendLayers(base, layer)
+	assert.NoError(t, err)
+	pushImage(u.Host+"/agent:based", based)
+
+	other, err := random.Image(64, 3)
+	assert.NoError(t, err)
+	pushImage(u.Host+"/agent:other", other)
+
+	tests := []struct {
+		name        string
+		img         string
+		expectedErr string
+	}{
+		{name: "based on the base image", img: u.Host + "/agent:based"},
+		{name: "not based on the base image", img: u.Host + "/agent:other",
+			expectedErr: "the image for platform linux/amd64 is not based on base image " + baseImage + ", the final stage must be based on $BASE_IMAGE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkBuildxImage(context.Background(), nil, tt.img, baseImage, BuildOptions{Push: true, Platforms: []string{platform}})
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,367 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package python
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/rs/zerolog"
+
+	"github.com/cisco-eti/wfsm/assets"
+	"github.com/cisco-eti/wfsm/internal"
+	"github.com/cisco-eti/wfsm/internal/util"
+)
+
+// paths of the files of the agent in the image, the same as in the Dockerfile of the agent image
+const (
+	agentSrcImagePath        = "/opt/agent_src"
+	agentWheelhouseImagePath = "/opt/agent_wheels"
+	agentManifestImagePath   = "/opt/spec/manifest.json"
+	startScriptImagePath     = "/opt/start_agws.sh"
+	workflowServerDir        = "/opt/agent-workflow-server"
+	wheelhouseDir            = "wheelhouse"
+)
+
+// layerEpoch is the modification time of the files in the layers of daemonless images,
+// so the same files always give the same layer digest
+var layerEpoch = time.Unix(0, 0).UTC()
+
+// assembleImage assembles the agent image without a container engine, appending layers with the wheelhouse,
+// the agent source, the manifest and the entrypoint to the base image of every platform.
+// The agent is installed by the entrypoint when the container starts, as nothing can run in the image during assembly.
+func assembleImage(ctx context.Context, img string, workspacePath string, inputSpec internal.AgentSpec, agentSourceDir string, baseImage string, labels map[string]string, opts BuildOptions) error {
+	log := zerolog.Ctx(ctx)
+
+	platforms := buildPlatforms(opts)
+	baseRef, err := name.ParseReference(baseImage)
+	if err != nil {
+		return fmt.Errorf("invalid base image %s: %v", baseImage, err)
+	}
+
+	buildArgs, err := getBuildArgs(inputSpec, agentSourceDir, baseImage)
+	if err != nil {
+		return err
+	}
+	env := map[string]string{
+		"AGENT_FRAMEWORK":     *buildArgs["AGENT_FRAMEWORK"],
+		"AGENT_OBJECT":        *buildArgs["AGENT_OBJECT"],
+		"AGENT_MANIFEST_PATH": agentManifestImagePath,
+		"AGWS_STORAGE_FILE":   "/opt/storage/agws_storage.pkl",
+		"AGENT_INSTALL_DIR":   agentSrcImagePath,
+	}
+
+	manifestFile, err := os.ReadFile(path.Join(workspacePath, "manifest.json"))
+	if err != nil {
+		return fmt.Errorf("failed to read agent manifest: %v", err)
+	}
+	layers := make([]layerContent, 0, 3)
+	// the containers install the agent offline from the wheelhouse, nothing is installed from PyPI
+	wheels, err := filepath.Glob(path.Join(workspacePath, wheelhouseDir, "*.whl"))
+	if err != nil || len(wheels) == 0 {
+		return fmt.Errorf("the wheelhouse has no wheels of the agent and its dependencies")
+	}
+	env["AGENT_WHEELHOUSE"] = agentWheelhouseImagePath
+	// the wheelhouse changes less often than the agent source, so it comes first
+	layers = append(layers,
+		layerContent{dir: path.Join(workspacePath, wheelhouseDir), target: agentWheelhouseImagePath},
+		layerContent{dir: path.Join(workspacePath, agentSourceDir), target: agentSrcImagePath},
+		layerContent{files: []layerFile{
+			{name: agentManifestImagePath, content: manifestFile, mode: 0o644},
+			{name: startScriptImagePath, content: assets.StartAGWSScript, mode: 0o755},
+		}},
+	)
+
+	images := make([]v1.Image, 0, len(platforms))
+	for _, platform := range platforms {
+		p, err := v1.ParsePlatform(platform)
+		if err != nil {
+			return fmt.Errorf("invalid platform %s: %v", platform, err)
+		}
+		base, err := remote.Image(baseRef, remote.WithPlatform(*p), remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("failed to get base image %s for platform %s: %v", baseImage, platform, err)
+		}
+		agentImage, err := appendAgentLayers(base, layers, env, labels)
+		if err != nil {
+			return fmt.Errorf("failed to assemble image for platform %s: %v", platform, err)
+		}
+		images = append(images, agentImage)
+	}
+
+	if opts.Push {
+		log.Info().Str("image", img).Strs("platforms", platforms).Msg("pushing assembled image")
+		return pushAssembledImage(ctx, img, images)
+	}
+	log.Info().Str("image", img).Strs("platforms", platforms).Str("path", opts.OutputPath).Msg("writing assembled image to OCI layout tarball")
+	return writeOCILayoutTarball(opts.OutputPath, img, images)
+}
+
+// appendAgentLayers appends the layers to the base image and sets the env vars, the labels and the entrypoint of the agent
+func appendAgentLayers(base v1.Image, contents []layerContent, env map[string]string, labels map[string]string) (v1.Image, error) {
+	mediaType, err := base.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	layerMediaType := types.DockerLayer
+	if mediaType == types.OCIManifestSchema1 {
+		layerMediaType = types.OCILayer
+	}
+
+	layers := make([]v1.Layer, 0, len(contents))
+	for _, content := range contents {
+		layer, err := content.layer(layerMediaType)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer)
+	}
+	agentImage, err := mutate.AppendLayers(base, layers...)
+	if err != nil {
+		return nil, err
+	}
+
+	configFile, err := agentImage.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	config := *configFile.Config.DeepCopy()
+	config.Env = setEnv(config.Env, env)
+	if config.Labels == nil {
+		config.Labels = make(map[string]string, len(labels))
+	}
+	maps.Copy(config.Labels, labels)
+	config.Entrypoint = []string{startScriptImagePath}
+	config.Cmd = nil
+	config.WorkingDir = workflowServerDir
+	return mutate.Config(agentImage, config)
+}
+
+// setEnv sets the values in the KEY=VALUE env list, keeping the order of the existing env vars
+func setEnv(env []string, values map[string]string) []string {
+	result := make([]string, 0, len(env)+len(values))
+	set := make(map[string]bool, len(values))
+	for _, envVar := range env {
+		key, _, _ := strings.Cut(envVar, "=")
+		if value, ok := values[key]; ok {
+			envVar = key + "=" + value
+			set[key] = true
+		}
+		result = append(result, envVar)
+	}
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		if !set[key] {
+			result = append(result, key+"="+values[key])
+		}
+	}
+	return result
+}
+
+// pushAssembledImage pushes the image, or a multi-arch index of the images of several platforms
+func pushAssembledImage(ctx context.Context, img string, images []v1.Image) error {
+	tag, err := name.NewTag(img)
+	if err != nil {
+		return fmt.Errorf("invalid image name %s: %v", img, err)
+	}
+	remoteOpts := []remote.Option{remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithContext(ctx)}
+	if len(images) == 1 {
+		if err := remote.Write(tag, images[0], remoteOpts...); err != nil {
+			return fmt.Errorf("failed to push image %s: %v", img, err)
+		}
+		return nil
+	}
+	idx, err := imageIndex(images)
+	if err != nil {
+		return err
+	}
+	if err := remote.WriteIndex(tag, idx, remoteOpts...); err != nil {
+		return fmt.Errorf("failed to push image %s: %v", img, err)
+	}
+	return nil
+}
+
+// imageIndex returns the OCI index of the images with their platforms
+func imageIndex(images []v1.Image) (v1.ImageIndex, error) {
+	idx := mutate.IndexMediaType(empty.Index, types.OCIImageIndex)
+	for _, image := range images {
+		configFile, err := image.ConfigFile()
+		if err != nil {
+			return nil, err
+		}
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{
+			Add:        image,
+			Descriptor: v1.Descriptor{Platform: configFile.Platform()},
+		})
+	}
+	return idx, nil
+}
+
+// writeOCILayoutTarball writes the images to a tarball of an OCI image layout, the index is annotated with the image name
+func writeOCILayoutTarball(outputPath string, img string, images []v1.Image) error {
+	layoutDir, err := os.MkdirTemp("", "wfsm_oci_layout_")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary dir for OCI layout: %v", err)
+	}
+	defer os.RemoveAll(layoutDir)
+
+	layoutPath, err := layout.Write(layoutDir, empty.Index)
+	if err != nil {
+		return fmt.Errorf("failed to write OCI layout: %v", err)
+	}
+	annotations := layout.WithAnnotations(map[string]string{"org.opencontainers.image.ref.name": img})
+	if len(images) == 1 {
+		err = layoutPath.AppendImage(images[0], annotations)
+	} else {
+		var idx v1.ImageIndex
+		if idx, err = imageIndex(images); err == nil {
+			err = layoutPath.AppendIndex(idx, annotations)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write image %s to OCI layout: %v", img, err)
+	}
+
+	// the layout is streamed to the tarball, its blobs can be too large to be held in memory
+	f, err := os.OpenFile(outputPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, util.OwnerCanReadWrite)
+	if err != nil {
+		return fmt.Errorf("failed to create OCI layout tarball %s: %v", outputPath, err)
+	}
+	if err = tarDir(f, layoutDir, ""); err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		os.Remove(outputPath)
+		return fmt.Errorf("failed to write OCI layout tarball %s: %v", outputPath, err)
+	}
+	return nil
+}
+
+// layerContent is the content of an image layer, either the files of a dir copied to target or the given files
+type layerContent struct {
+	dir    string
+	target string
+	files  []layerFile
+}
+
+type layerFile struct {
+	name    string
+	content []byte
+	mode    int64
+}
+
+// layer returns the layer of the content, its tar is streamed from the files every time the layer is read
+// instead of being held in memory
+func (c layerContent) layer(mediaType types.MediaType) (v1.Layer, error) {
+	return tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		pr, pw := io.Pipe()
+		go func() {
+			if c.dir != "" {
+				pw.CloseWithError(tarDir(pw, c.dir, c.target))
+			} else {
+				pw.CloseWithError(tarFiles(pw, c.files))
+			}
+		}()
+		return pr, nil
+	}, tarball.WithMediaType(mediaType))
+}
+
+// tarDir archives the files of dir under target to w, with fixed owners and modification times
+func tarDir(w io.Writer, dir string, target string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimPrefix(path.Join(target, filepath.ToSlash(rel)), "/")
+		if name == "." || name == "" {
+			return nil
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(filePath); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		normalizeHeader(header)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// tarFiles archives the files to w, with fixed owners and modification times
+func tarFiles(w io.Writer, files []layerFile) error {
+	tw := tar.NewWriter(w)
+	for _, file := range files {
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     strings.TrimPrefix(file.name, "/"),
+			Size:     int64(len(file.content)),
+			Mode:     file.mode,
+		}
+		normalizeHeader(header)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(file.content); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func normalizeHeader(header *tar.Header) {
+	header.ModTime = layerEpoch
+	header.AccessTime = time.Time{}
+	header.ChangeTime = time.Time{}
+	header.Uid, header.Gid = 0, 0
+	header.Uname, header.Gname = "", ""
+	header.Format = tar.FormatPAX
+}
@@ -0,0 +1,210 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package python
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cisco-eti/wfsm/internal"
+	"github.com/cisco-eti/wfsm/internal/builder/python/source"
+	"github.com/cisco-eti/wfsm/manifests"
+)
+
+// pushBaseImage pushes a workflow server base image for linux/amd64 and linux/arm64 to the registry
+func pushBaseImage(t *testing.T, ref string) {
+	idx := v1.ImageIndex(empty.Index)
+	for _, arch := range []string{"amd64", "arm64"} {
+		img, err := random.Image(64, 1)
+		assert.NoError(t, err)
+		img, err = mutate.ConfigFile(img, &v1.ConfigFile{
+			OS:           "linux",
+			Architecture: arch,
+			Config: v1.Config{
+				Env: []string{"PATH=/usr/local/bin:/usr/bin", "AGENT_FRAMEWORK=none"},
+				Cmd: []string{"python"},
+			},
+		})
+		assert.NoError(t, err)
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{Add: img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: arch}}})
+	}
+	tag, err := name.NewTag(ref)
+	assert.NoError(t, err)
+	assert.NoError(t, remote.WriteIndex(tag, idx))
+}
+
+func agentSpec() internal.AgentSpec {
+	version := "v1.0.0"
+	return internal.AgentSpec{
+		DeploymentName: "mailcomposer",
+		Manifest: manifests.AgentManifest{
+			Name: "org.agntcy.mailcomposer",
+			Extensions: []manifests.Manifest{{
+				Name:    "schema.oasf.agntcy.org/features/runtime/manifest",
+				Version: &version,
+				Data: manifests.DeploymentManifest{
+					Deployment: manifests.AgentDeployment{
+						DeploymentOptions: []manifests.AgentDeploymentDeploymentOptionsInner{{
+							SourceCodeDeployment: &manifests.SourceCodeDeployment{
+								FrameworkConfig: manifests.SourceCodeDeploymentFrameworkConfig{
+									LangGraphConfig: &manifests.LangGraphConfig{FrameworkType: "langgraph", Graph: "mailcomposer.graph"},
+								},
+							},
+						}},
+					},
+				},
+			}},
+		},
+	}
+}
+
+// layerFiles returns the names of the files in the layer
+func layerFiles(t *testing.T, layer v1.Layer) []string {
+	rc, err := layer.Uncompressed()
+	assert.NoError(t, err)
+	defer rc.Close()
+	files := make([]string, 0)
+	tr := tar.NewReader(rc)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		if header.Typeflag == tar.TypeReg {
+			files = append(files, header.Name)
+		}
+	}
+	return files
+}
+
+func TestEnsureContainerImage_Daemonless(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+	baseImage := u.Host + "/agntcy/acp/wfsrv:0.1.0"
+	pushBaseImage(t, baseImage)
+
+	srcPath := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(srcPath, "mailcomposer"), os.ModePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(srcPath, "pyproject.toml"), []byte("[project]"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(srcPath, "mailcomposer", "graph.py"), []byte("graph = None"), 0o600))
+	wheelhouse := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(wheelhouse, "mailcomposer-0.1.0-py3-none-any.whl"), []byte("wheel"), 0o600))
+
+	opts := BuildOptions{
+		BaseImage:          baseImage,
+		DeleteBuildFolders: true,
+		Platforms:          []string{"linux/amd64", "linux/arm64"},
+		Registry:           u.Host + "/org",
+		Push:               true,
+		Daemonless:         true,
+		Wheelhouse:         wheelhouse,
+	}
+	img, err := EnsureContainerImage(context.Background(), u.Host+"/org/agntcy/wfsm-mailcomposer", &source.LocalSource{LocalPath: srcPath}, agentSpec(), opts)
+	assert.NoError(t, err)
+
+	ref, err := name.ParseReference(img)
+	assert.NoError(t, err)
+	idx, err := remote.Index(ref)
+	assert.NoError(t, err)
+	indexManifest, err := idx.IndexManifest()
+	assert.NoError(t, err)
+	assert.Len(t, indexManifest.Manifests, 2)
+
+	for _, desc := range indexManifest.Manifests {
+		agentImage, err := idx.Image(desc.Digest)
+		assert.NoError(t, err)
+
+		configFile, err := agentImage.ConfigFile()
+		assert.NoError(t, err)
+		assert.Equal(t, desc.Platform.Architecture, configFile.Architecture)
+		assert.Equal(t, []string{"/opt/start_agws.sh"}, configFile.Config.Entrypoint)
+		assert.Nil(t, configFile.Config.Cmd)
+		assert.Equal(t, []string{
+			"PATH=/usr/local/bin:/usr/bin",
+			"AGENT_FRAMEWORK=langgraph",
+			"AGENT_INSTALL_DIR=/opt/agent_src",
+			"AGENT_MANIFEST_PATH=/opt/spec/manifest.json",
+			"AGENT_OBJECT=mailcomposer.graph",
+			"AGENT_WHEELHOUSE=/opt/agent_wheels",
+			"AGWS_STORAGE_FILE=/opt/storage/agws_storage.pkl",
+		}, configFile.Config.Env)
+
+		layers, err := agentImage.Layers()
+		assert.NoError(t, err)
+		assert.Len(t, layers, 4)
+		assert.Equal(t, []string{"opt/agent_wheels/mailcomposer-0.1.0-py3-none-any.whl"}, layerFiles(t, layers[1]))
+		assert.Equal(t, []string{"opt/agent_src/mailcomposer/graph.py", "opt/agent_src/pyproject.toml"}, layerFiles(t, layers[2]))
+		assert.Equal(t, []string{"opt/spec/manifest.json", "opt/start_agws.sh"}, layerFiles(t, layers[3]))
+	}
+
+	// the pushed image is found in the registry, and the same sources give the same layers
+	again, err := EnsureContainerImage(context.Background(), u.Host+"/org/agntcy/wfsm-mailcomposer", &source.LocalSource{LocalPath: srcPath}, agentSpec(), opts)
+	assert.NoError(t, err)
+	assert.Equal(t, img, again)
+
+	// OCI layout tarball of the image of a single platform
+	opts.Push = false
+	opts.Platforms = []string{"linux/arm64"}
+	opts.OutputPath = filepath.Join(t.TempDir(), "agent.tar")
+	_, err = EnsureContainerImage(context.Background(), "agntcy/wfsm-mailcomposer", &source.LocalSource{LocalPath: srcPath}, agentSpec(), opts)
+	assert.NoError(t, err)
+
+	f, err := os.Open(opts.OutputPath)
+	assert.NoError(t, err)
+	defer f.Close()
+	layoutFiles := make([]string, 0)
+	tr := tar.NewReader(f)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		layoutFiles = append(layoutFiles, header.Name)
+	}
+	assert.Contains(t, layoutFiles, "index.json")
+	assert.Contains(t, layoutFiles, "oci-layout")
+}
+
+func TestEnsureContainerImage_DaemonlessEmptyWheelhouse(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+	baseImage := u.Host + "/agntcy/acp/wfsrv:0.1.0"
+	pushBaseImage(t, baseImage)
+
+	srcPath := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(srcPath, "mailcomposer"), os.ModePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(srcPath, "pyproject.toml"), []byte("[project]"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(srcPath, "mailcomposer", "graph.py"), []byte("graph = None"), 0o600))
+
+	opts := BuildOptions{
+		BaseImage:          baseImage,
+		DeleteBuildFolders: true,
+		Registry:           u.Host + "/org",
+		Push:               true,
+		Daemonless:         true,
+		Wheelhouse:         t.TempDir(),
+	}
+	_, err = EnsureContainerImage(context.Background(), u.Host+"/org/agntcy/wfsm-mailcomposer", &source.LocalSource{LocalPath: srcPath}, agentSpec(), opts)
+	assert.EqualError(t, err, "the wheelhouse has no wheels of the agent and its dependencies")
+}
@@ -0,0 +1,159 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package python
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/cisco-eti/wfsm/assets"
+)
+
+// installers of the dependencies of python agents, chosen by the dependency files found in the root of the agent source
+const (
+	installerUV           = "uv"
+	installerPoetry       = "poetry"
+	installerRequirements = "requirements"
+	installerPyProject    = "pyproject"
+)
+
+// CustomDockerfile is the Dockerfile in the root of the agent source the image is built from instead of the generated one
+const CustomDockerfile = "Dockerfile.wfsm"
+
+const (
+	pipCacheMount    = "--mount=type=cache,target=/root/.cache/pip "
+	poetryCacheMount = "--mount=type=cache,target=/root/.cache/pypoetry "
+)
+
+// dockerfileParams are the parameters of the agent Dockerfile template
+type dockerfileParams struct {
+	// DependencyFiles are copied to the dependency stage, relative to the agent source dir
+	DependencyFiles []string
+	// Installer installs the dependencies in the dependency stage, empty if the agent source has no dependency files
+	Installer        string
+	PipCacheMount    string
+	PoetryCacheMount string
+	// SecretMounts mount the secrets of the package index (see PackageIndex.secretMounts) into the RUN instructions installing packages
+	SecretMounts string
+}
+
+// generateDockerfile generates the multi-stage Dockerfile of the agent in agentSrcPath. The first stage installs
+// the dependencies from the dependency files, the second one installs the agent, so code changes only rebuild
+// the second stage. BuildKit cache mounts keep the downloaded packages between builds if cacheMounts is set.
+func generateDockerfile(agentSrcPath string, cacheMounts bool, secretMounts string) ([]byte, error) {
+	params, err := detectDependencies(agentSrcPath)
+	if err != nil {
+		return nil, err
+	}
+	params.SecretMounts = secretMounts
+	if cacheMounts {
+		params.PipCacheMount = pipCacheMount
+		params.PoetryCacheMount = poetryCacheMount
+	}
+
+	tmpl, err := template.New("Dockerfile").Parse(string(assets.AgentBuilderDockerfileTemplate))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse dockerfile template: %v", err)
+	}
+	if _, err := tmpl.New("entrypoint").Parse(string(assets.AgentEntrypointDockerfile)); err != nil {
+		return nil, fmt.Errorf("failed to parse dockerfile template: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, params); err != nil {
+		return nil, fmt.Errorf("failed to generate dockerfile: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// agentDockerfile returns the Dockerfile of the agent image, the custom Dockerfile of the agent if it has one,
+// otherwise the generated one. Custom Dockerfiles mount the secrets of the package index themselves.
+func agentDockerfile(agentSrcPath string, customDockerfile string, cacheMounts bool, secretMounts string) ([]byte, error) {
+	if customDockerfile == "" {
+		return generateDockerfile(agentSrcPath, cacheMounts, secretMounts)
+	}
+	return extendCustomDockerfile(path.Join(agentSrcPath, customDockerfile))
+}
+
+// findCustomDockerfile returns the path of the custom Dockerfile of the agent relative to its source: the configured one,
+// or Dockerfile.wfsm if the agent source has one. It returns an empty path if the image is built from the generated Dockerfile.
+func findCustomDockerfile(agentSrcPath string, configuredDockerfile string) (string, error) {
+	if configuredDockerfile != "" {
+		dockerfile := filepath.Clean(configuredDockerfile)
+		if !filepath.IsLocal(dockerfile) {
+			return "", fmt.Errorf("dockerfile %s must be a relative path inside the agent source", configuredDockerfile)
+		}
+		if _, err := os.Stat(path.Join(agentSrcPath, dockerfile)); err != nil {
+			return "", fmt.Errorf("dockerfile %s not found in the agent source: %v", configuredDockerfile, err)
+		}
+		return filepath.ToSlash(dockerfile), nil
+	}
+	if _, err := os.Stat(path.Join(agentSrcPath, CustomDockerfile)); err == nil {
+		return CustomDockerfile, nil
+	}
+	return "", nil
+}
+
+// extendCustomDockerfile appends the manifest and the entrypoint of agent images to the final stage of the custom Dockerfile.
+// It is built with the build args of the generated Dockerfile (BASE_IMAGE, AGENT_DIR, AGENT_FRAMEWORK, AGENT_OBJECT)
+// in the same build context, where the agent source is in $AGENT_DIR.
+func extendCustomDockerfile(dockerfilePath string) ([]byte, error) {
+	dockerFile, err := os.ReadFile(dockerfilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dockerfile: %v", err)
+	}
+	var buf bytes.Buffer
+	buf.Write(bytes.TrimRight(dockerFile, "\n"))
+	buf.WriteString("\n\n")
+	buf.Write(assets.AgentEntrypointDockerfile)
+	return buf.Bytes(), nil
+}
+
+// detectDependencies detects the installer and the dependency files of the agent,
+// lock files take precedence over requirements.txt, which takes precedence over pyproject.toml
+func detectDependencies(agentSrcPath string) (dockerfileParams, error) {
+	entries, err := os.ReadDir(agentSrcPath)
+	if err != nil {
+		return dockerfileParams{}, fmt.Errorf("failed to read agent source dir: %v", err)
+	}
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			files = append(files, entry.Name())
+		}
+	}
+	hasFile := func(name string) bool {
+		return slices.Contains(files, name)
+	}
+
+	switch {
+	case hasFile("uv.lock") && hasFile("pyproject.toml"):
+		return dockerfileParams{Installer: installerUV, DependencyFiles: []string{"pyproject.toml", "uv.lock"}}, nil
+	case hasFile("poetry.lock") && hasFile("pyproject.toml"):
+		return dockerfileParams{Installer: installerPoetry, DependencyFiles: []string{"pyproject.toml", "poetry.lock"}}, nil
+	case hasFile("requirements.txt"):
+		// all requirements files are copied as they can include each other
+		requirements := make([]string, 0)
+		for _, file := range files {
+			if matched, _ := filepath.Match("requirements*.txt", file); matched {
+				requirements = append(requirements, file)
+			}
+		}
+		return dockerfileParams{Installer: installerRequirements, DependencyFiles: requirements}, nil
+	case hasFile("pyproject.toml"):
+		pyProject, err := os.ReadFile(path.Join(agentSrcPath, "pyproject.toml"))
+		if err != nil {
+			return dockerfileParams{}, fmt.Errorf("failed to read pyproject.toml: %v", err)
+		}
+		if strings.Contains(string(pyProject), "[tool.poetry") {
+			return dockerfileParams{Installer: installerPoetry, DependencyFiles: []string{"pyproject.toml"}}, nil
+		}
+		return dockerfileParams{Installer: installerPyProject, DependencyFiles: []string{"pyproject.toml"}}, nil
+	}
+	return dockerfileParams{}, nil
+}
@@ -0,0 +1,172 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package python
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateDockerfile(t *testing.T) {
+	tests := []struct {
+		name              string
+		files             map[string]string
+		cacheMounts       bool
+		expectedParams    dockerfileParams
+		expectedFragments []string
+	}{
+		{
+			name:           "uv lock",
+			files:          map[string]string{"pyproject.toml": "[project]", "uv.lock": "", "requirements.txt": ""},
+			cacheMounts:    true,
+			expectedParams: dockerfileParams{Installer: installerUV, DependencyFiles: []string{"pyproject.toml", "uv.lock"}},
+			expectedFragments: []string{
+				"COPY $AGENT_DIR/pyproject.toml $AGENT_DIR/uv.lock /opt/agent_deps/",
+				"RUN --mount=type=cache,target=/root/.cache/pip cd /opt/agent_deps",
+				"uv export --frozen",
+			},
+		},
+		{
+			name:           "poetry lock",
+			files:          map[string]string{"pyproject.toml": "[tool.poetry]", "poetry.lock": ""},
+			cacheMounts:    true,
+			expectedParams: dockerfileParams{Installer: installerPoetry, DependencyFiles: []string{"pyproject.toml", "poetry.lock"}},
+			expectedFragments: []string{
+				"COPY $AGENT_DIR/pyproject.toml $AGENT_DIR/poetry.lock /opt/agent_deps/",
+				"RUN --mount=type=cache,target=/root/.cache/pypoetry --mount=type=cache,target=/root/.cache/pip cd /opt/agent_deps",
+				"poetry export --only main",
+			},
+		},
+		{
+			name:           "poetry without lock",
+			files:          map[string]string{"pyproject.toml": "[tool.poetry.dependencies]"},
+			expectedParams: dockerfileParams{Installer: installerPoetry, DependencyFiles: []string{"pyproject.toml"}},
+			expectedFragments: []string{
+				"RUN cd /opt/agent_deps",
+			},
+		},
+		{
+			name:           "requirements",
+			files:          map[string]string{"pyproject.toml": "[project]", "requirements.txt": "", "requirements-dev.txt": "", "setup.py": ""},
+			expectedParams: dockerfileParams{Installer: installerRequirements, DependencyFiles: []string{"requirements-dev.txt", "requirements.txt"}},
+			expectedFragments: []string{
+				"COPY $AGENT_DIR/requirements-dev.txt $AGENT_DIR/requirements.txt /opt/agent_deps/",
+				"RUN poetry run pip install -r /opt/agent_deps/requirements.txt",
+				"RUN poetry run pip install /opt/agent_src",
+			},
+		},
+		{
+			name:           "pyproject",
+			files:          map[string]string{"pyproject.toml": "[project]"},
+			cacheMounts:    true,
+			expectedParams: dockerfileParams{Installer: installerPyProject, DependencyFiles: []string{"pyproject.toml"}},
+			expectedFragments: []string{
+				"RUN --mount=type=cache,target=/root/.cache/pip poetry run python -c \"import tomllib;",
+				"RUN --mount=type=cache,target=/root/.cache/pip poetry run pip install /opt/agent_src",
+			},
+		},
+		{
+			name:           "no dependency files",
+			files:          map[string]string{"setup.py": ""},
+			expectedParams: dockerfileParams{},
+			expectedFragments: []string{
+				"FROM $BASE_IMAGE AS deps",
+				"FROM deps",
+				"COPY manifest.json /opt/spec/manifest.json",
+				"ENTRYPOINT [\"/opt/start_agws.sh\"]",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agentSrcPath := t.TempDir()
+			for name, content := range tt.files {
+				assert.NoError(t, os.WriteFile(path.Join(agentSrcPath, name), []byte(content), 0o600))
+			}
+
+			params, err := detectDependencies(agentSrcPath)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedParams, params)
+
+			dockerfile, err := generateDockerfile(agentSrcPath, tt.cacheMounts, "")
+			assert.NoError(t, err)
+			for _, fragment := range tt.expectedFragments {
+				assert.Contains(t, string(dockerfile), fragment)
+			}
+			if !tt.cacheMounts {
+				assert.NotContains(t, string(dockerfile), "--mount")
+			}
+			if tt.expectedParams.Installer == "" {
+				assert.NotContains(t, string(dockerfile), "/opt/agent_deps")
+			}
+		})
+	}
+}
+
+func TestAgentDockerfile_Custom(t *testing.T) {
+	tests := []struct {
+		name                 string
+		files                map[string]string
+		configuredDockerfile string
+		expectedDockerfile   string
+		expectedErr          string
+	}{
+		{
+			name:               "Dockerfile.wfsm",
+			files:              map[string]string{"Dockerfile.wfsm": "FROM $BASE_IMAGE\nRUN apt-get install -y ffmpeg\n"},
+			expectedDockerfile: "Dockerfile.wfsm",
+		},
+		{
+			name:                 "configured dockerfile",
+			files:                map[string]string{"Dockerfile.wfsm": "FROM $BASE_IMAGE", "docker/Dockerfile": "FROM $BASE_IMAGE\nRUN apt-get install -y ffmpeg"},
+			configuredDockerfile: "./docker/Dockerfile",
+			expectedDockerfile:   "docker/Dockerfile",
+		},
+		{
+			name:  "generated dockerfile",
+			files: map[string]string{"Dockerfile": "FROM python"},
+		},
+		{
+			name:                 "configured dockerfile not found",
+			configuredDockerfile: "Dockerfile.gpu",
+			expectedErr:          "dockerfile Dockerfile.gpu not found in the agent source",
+		},
+		{
+			name:                 "configured dockerfile outside of the agent source",
+			configuredDockerfile: "../Dockerfile",
+			expectedErr:          "dockerfile ../Dockerfile must be a relative path inside the agent source",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agentSrcPath := t.TempDir()
+			for name, content := range tt.files {
+				assert.NoError(t, os.MkdirAll(path.Dir(path.Join(agentSrcPath, name)), 0o700))
+				assert.NoError(t, os.WriteFile(path.Join(agentSrcPath, name), []byte(content), 0o600))
+			}
+
+			customDockerfile, err := findCustomDockerfile(agentSrcPath, tt.configuredDockerfile)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedDockerfile, customDockerfile)
+
+			dockerfile, err := agentDockerfile(agentSrcPath, customDockerfile, false, "")
+			assert.NoError(t, err)
+			if customDockerfile == "" {
+				assert.Contains(t, string(dockerfile), "FROM deps")
+				return
+			}
+			// the manifest and the entrypoint are appended to the final stage of the custom dockerfile
+			assert.True(t, strings.HasPrefix(string(dockerfile), "FROM $BASE_IMAGE\nRUN apt-get install -y ffmpeg\n\n"))
+			assert.True(t, strings.HasSuffix(string(dockerfile), "ENTRYPOINT [\"/opt/start_agws.sh\"]\n"))
+			assert.Contains(t, string(dockerfile), "COPY manifest.json /opt/spec/manifest.json")
+		})
+	}
+}
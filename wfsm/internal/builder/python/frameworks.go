@@ -0,0 +1,119 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package python
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/rs/zerolog"
+
+	"github.com/cisco-eti/wfsm/manifests"
+)
+
+// frameworkAdapter returns the AGENT_OBJECT of an agent from its framework config, the object the workflow server runs
+type frameworkAdapter func(frameworkConfig any) (string, error)
+
+// frameworks are the adapters of the agent frameworks by framework type, the framework type is the AGENT_FRAMEWORK of the agent
+var frameworks = map[string]frameworkAdapter{}
+
+// frameworkMinWorkflowServers are the minimum workflow server versions running the agents of the frameworks by framework type,
+// frameworks without minimum version are run by all the workflow server versions
+var frameworkMinWorkflowServers = map[string]*semver.Version{}
+
+// adaptersMinWorkflowServer is the first workflow server version with adapters for the crewai, autogen and python_callable
+// frameworks, earlier versions only run langgraph and llamaindex agents
+const adaptersMinWorkflowServer = "0.2.0"
+
+// pythonObjectRef is a reference to a python object in the form module:name, e.g. agent.main:run
+var pythonObjectRef = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*:[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+func init() {
+	RegisterFramework("langgraph", "", func(c *manifests.LangGraphConfig) (string, error) { return c.Graph, nil })
+	RegisterFramework("llamaindex", "", func(c *manifests.LlamaIndexConfig) (string, error) { return c.Path, nil })
+	RegisterFramework("crewai", adaptersMinWorkflowServer, func(c *manifests.CrewAIConfig) (string, error) { return objectRef(c.Crew) })
+	RegisterFramework("autogen", adaptersMinWorkflowServer, func(c *manifests.AutoGenConfig) (string, error) { return objectRef(c.Agent) })
+	RegisterFramework("python_callable", adaptersMinWorkflowServer, func(c *manifests.PythonCallableConfig) (string, error) { return objectRef(c.Callable) })
+}
+
+// RegisterFramework registers the agent framework of the framework type, whose agents have framework configs of type T.
+// minWorkflowServer is the first workflow server version with an adapter for the framework, empty if all versions run it.
+// The object function returns the object of the agent the workflow server runs.
+func RegisterFramework[T any](frameworkType string, minWorkflowServer string, object func(frameworkConfig *T) (string, error)) {
+	if minWorkflowServer != "" {
+		frameworkMinWorkflowServers[frameworkType] = semver.MustParse(minWorkflowServer)
+	}
+	frameworks[frameworkType] = func(frameworkConfig any) (string, error) {
+		config, ok := frameworkConfig.(*T)
+		if !ok {
+			return "", fmt.Errorf("framework config of type %T does not belong to framework %s", frameworkConfig, frameworkType)
+		}
+		return object(config)
+	}
+}
+
+// frameworkBuildArgs returns the AGENT_FRAMEWORK and AGENT_OBJECT build args of the framework config of an agent
+func frameworkBuildArgs(frameworkConfig manifests.SourceCodeDeploymentFrameworkConfig) (string, string, error) {
+	instance, ok := frameworkConfig.GetActualInstance().(interface{ GetFrameworkType() string })
+	if !ok {
+		return "", "", fmt.Errorf("unsupported framework config")
+	}
+	frameworkType := instance.GetFrameworkType()
+	adapter, ok := frameworks[frameworkType]
+	if !ok {
+		return "", "", fmt.Errorf("unsupported framework %s, supported frameworks: %s", frameworkType, strings.Join(supportedFrameworks(), ", "))
+	}
+	object, err := adapter(instance)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid %s framework config: %v", frameworkType, err)
+	}
+	return frameworkType, object, nil
+}
+
+// checkWorkflowServer checks that the workflow server of the base image runs the agents of the framework.
+// The workflow server version is the tag of base images of the workflow server repo, the check is skipped
+// with a warning for other base images (e.g. custom images based on the workflow server image).
+func checkWorkflowServer(ctx context.Context, frameworkType string, baseImage string) error {
+	log := zerolog.Ctx(ctx)
+
+	minVersion, ok := frameworkMinWorkflowServers[frameworkType]
+	if !ok {
+		return nil
+	}
+	var version *semver.Version
+	if tag, err := name.NewTag(baseImage); err == nil && tag.Context().Name() == WorkflowServerRepo {
+		version, _ = semver.NewVersion(tag.TagStr())
+	}
+	if version == nil {
+		log.Warn().Str("base_image", baseImage).Str("framework", frameworkType).
+			Msgf("the workflow server version of the base image is unknown, agents of framework %s need workflow server %s or later", frameworkType, minVersion)
+		return nil
+	}
+	if version.LessThan(minVersion) {
+		return fmt.Errorf("agents of framework %s need workflow server %s or later, base image %s has workflow server %s, set a newer base image",
+			frameworkType, minVersion, baseImage, version)
+	}
+	return nil
+}
+
+func supportedFrameworks() []string {
+	frameworkTypes := make([]string, 0, len(frameworks))
+	for frameworkType := range frameworks {
+		frameworkTypes = append(frameworkTypes, frameworkType)
+	}
+	slices.Sort(frameworkTypes)
+	return frameworkTypes
+}
+
+// objectRef checks that ref is a reference to a python object in the form module:name
+func objectRef(ref string) (string, error) {
+	if !pythonObjectRef.MatchString(ref) {
+		return "", fmt.Errorf("%s is not in the form module:name, e.g. agent.main:run", ref)
+	}
+	return ref, nil
+}
@@ -0,0 +1,129 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package python
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/cisco-eti/wfsm/manifests"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrameworkBuildArgs(t *testing.T) {
+	tests := []struct {
+		name              string
+		frameworkConfig   string
+		expectedFramework string
+		expectedObject    string
+		expectedErr       string
+	}{
+		{
+			name:              "langgraph",
+			frameworkConfig:   `{"framework_type": "langgraph", "graph": "mailcomposer.graph"}`,
+			expectedFramework: "langgraph",
+			expectedObject:    "mailcomposer.graph",
+		},
+		{
+			name:              "llamaindex",
+			frameworkConfig:   `{"framework_type": "llamaindex", "path": "agent.workflow:workflow"}`,
+			expectedFramework: "llamaindex",
+			expectedObject:    "agent.workflow:workflow",
+		},
+		{
+			name:              "crewai",
+			frameworkConfig:   `{"framework_type": "crewai", "crew": "agent.crew:crew"}`,
+			expectedFramework: "crewai",
+			expectedObject:    "agent.crew:crew",
+		},
+		{
+			name:              "autogen",
+			frameworkConfig:   `{"framework_type": "autogen", "agent": "agent.team:team"}`,
+			expectedFramework: "autogen",
+			expectedObject:    "agent.team:team",
+		},
+		{
+			name:              "python callable",
+			frameworkConfig:   `{"framework_type": "python_callable", "callable": "agent.main:run"}`,
+			expectedFramework: "python_callable",
+			expectedObject:    "agent.main:run",
+		},
+		{
+			name:            "python callable without function",
+			frameworkConfig: `{"framework_type": "python_callable", "callable": "agent.main"}`,
+			expectedErr:     "invalid python_callable framework config: agent.main is not in the form module:name, e.g. agent.main:run",
+		},
+		{
+			name:            "unsupported framework",
+			frameworkConfig: `{"framework_type": "semantic_kernel", "graph": "agent.graph"}`,
+			expectedErr:     "unsupported framework semantic_kernel, supported frameworks: autogen, crewai, langgraph, llamaindex, python_callable",
+		},
+		{
+			name:            "framework type of another config",
+			frameworkConfig: `{"framework_type": "crewai", "graph": "agent.graph"}`,
+			expectedErr:     "invalid crewai framework config: framework config of type *manifests.LangGraphConfig does not belong to framework crewai",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var frameworkConfig manifests.SourceCodeDeploymentFrameworkConfig
+			assert.NoError(t, json.Unmarshal([]byte(tt.frameworkConfig), &frameworkConfig))
+
+			framework, object, err := frameworkBuildArgs(frameworkConfig)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedFramework, framework)
+			assert.Equal(t, tt.expectedObject, object)
+
+			// the framework config is written unchanged to the manifest of the image
+			marshalled, err := json.Marshal(frameworkConfig)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.frameworkConfig, string(marshalled))
+		})
+	}
+}
+
+func TestCheckWorkflowServer(t *testing.T) {
+	tests := []struct {
+		name        string
+		framework   string
+		baseImage   string
+		expectedErr string
+	}{
+		{
+			name:      "framework run by all workflow servers",
+			framework: "langgraph",
+			baseImage: WorkflowServerRepo + ":0.1.0",
+		},
+		{
+			name:      "workflow server with the adapter",
+			framework: "crewai",
+			baseImage: WorkflowServerRepo + ":0.2.1",
+		},
+		{
+			name:        "workflow server without the adapter",
+			framework:   "python_callable",
+			baseImage:   WorkflowServerRepo + ":0.1.2",
+			expectedErr: "agents of framework python_callable need workflow server 0.2.0 or later, base image ghcr.io/agntcy/acp/wfsrv:0.1.2 has workflow server 0.1.2, set a newer base image",
+		},
+		{
+			name:      "unknown workflow server version",
+			framework: "autogen",
+			baseImage: "ghcr.io/org/agntcy/custom-wfsrv:0.1.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkWorkflowServer(context.Background(), tt.framework, tt.baseImage)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -10,21 +10,26 @@ import (
 	"io"
 	"os"
 	"path"
+	"strings"
 
 	"github.com/cisco-eti/wfsm/assets"
 	"github.com/cisco-eti/wfsm/internal"
 	"github.com/cisco-eti/wfsm/internal/builder/python/source"
+	"github.com/cisco-eti/wfsm/internal/buildreport"
 	containerclient "github.com/cisco-eti/wfsm/internal/container_client"
+	"github.com/cisco-eti/wfsm/internal/engine"
 	"github.com/cisco-eti/wfsm/internal/util"
 	"github.com/cisco-eti/wfsm/internal/wfsm/manifest"
 	"github.com/cisco-eti/wfsm/manifests"
 	"github.com/containerd/errdefs"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/rs/zerolog"
 
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/image"
 	dockerclient "github.com/docker/docker/client"
@@ -40,12 +45,18 @@ const WorkflowServerRepo = "ghcr.io/agntcy/acp/wfsrv"
 
 // EnsureContainerImage - ensure container image is available. If the image exists, it returns the name of the
 // existing  image, otherwise it builds a new image with the necessary packages installed
-// and returns its name.
-func EnsureContainerImage(ctx context.Context, img string, src source.AgentSource, inputSpec internal.AgentSpec, deleteBuildFolders bool, forceBuild bool, baseImage string) (string, error) {
+// and returns its name. Images built for explicit platforms or pushed to a registry are built with docker buildx,
+// daemonless images are assembled without a container engine, other images are built by the engine of the options.
+// Built images are labelled with the origin of the agent and its manifest, their SBOM and build report are recorded by recordBuild.
+func EnsureContainerImage(ctx context.Context, img string, src source.AgentSource, inputSpec internal.AgentSpec, opts BuildOptions) (string, error) {
 
 	log := zerolog.Ctx(ctx)
 	ctx = log.WithContext(ctx)
 
+	if err := opts.Validate(); err != nil {
+		return "", err
+	}
+
 	containerImageBuildLock.Lock(img)
 	defer containerImageBuildLock.Unlock(img)
 
@@ -62,12 +73,21 @@ func EnsureContainerImage(ctx context.Context, img string, src source.AgentSourc
 
 	log.Info().Str("agent_src_path", agentSrcPath).Msg("copying agent source to workspace")
 
-	err = src.CopyToWorkspace(agentSrcPath)
+	err = src.CopyToWorkspace(ctx, agentSrcPath)
 	if err != nil {
 		return "", fmt.Errorf("failed to copy agent source to workspace: %v", err)
 	}
 
-	if deleteBuildFolders {
+	// agents can build their images from a Dockerfile of their own
+	inputSpec.Dockerfile, err = findCustomDockerfile(agentSrcPath, inputSpec.Dockerfile)
+	if err != nil {
+		return "", err
+	}
+	if inputSpec.Dockerfile != "" && opts.Daemonless {
+		return "", fmt.Errorf("dockerfile %s of the agent cannot be built by daemonless builds", inputSpec.Dockerfile)
+	}
+
+	if opts.DeleteBuildFolders {
 		defer func() {
 			if err := os.RemoveAll(workspacePath); err != nil {
 				log.Error().Err(err).Str("path", workspacePath).Msg("failed to remove temporary workspace dir")
@@ -83,19 +103,67 @@ func EnsureContainerImage(ctx context.Context, img string, src source.AgentSourc
 	manifestFile := path.Join(workspacePath, "manifest.json")
 	err = os.WriteFile(manifestFile, manifestFileBuf, util.OwnerCanReadWrite)
 
-	// calc. hash based on agent source files and manifest file and use as image tag
-	hashCode := calculateHash(workspacePath, baseImage)
+	// the wheels of daemonless images are part of the workspace, so they change the hash
+	if opts.Daemonless && opts.Wheelhouse != "" {
+		wheelhouse := &source.LocalSource{LocalPath: opts.Wheelhouse}
+		if err = wheelhouse.CopyToWorkspace(ctx, path.Join(workspacePath, wheelhouseDir)); err != nil {
+			return "", fmt.Errorf("failed to copy wheelhouse to workspace: %v", err)
+		}
+	}
+
+	// the labels identify the source and the manifest of the agent, images are rebuilt when they change
+	origin := src.Origin(ctx)
+	labels := buildreport.Labels(inputSpec.Manifest, manifestFileBuf, origin.URL, origin.Revision)
+
+	// calc. hash based on agent source files and manifest file and use as image tag,
+	// files ignored by the .wfsmignore or .dockerignore file of the agent are not in the workspace and do not change the hash
+	hashCode := calculateHash(workspacePath, opts.BaseImage, opts.Platforms, opts.Daemonless, inputSpec.Dockerfile, labels)
 	img = fmt.Sprintf("%s:%s", img, hashCode)
 
-	dockerCli, err := util.GetDockerCLI(ctx)
+	// pushed images are looked up in the registry, pushed images and daemonless images are not stored locally
+	if opts.Push || opts.Daemonless {
+		if opts.Push && !opts.ForceBuild {
+			found, err := findRemoteImage(ctx, img, opts.Platforms)
+			if err != nil {
+				return "", err
+			}
+			if found {
+				return img, nil
+			}
+			log.Info().Str("image", img).Msg("image not found in registry")
+		}
+		baseImage, err := getBaseImage(opts.BaseImage, WorkflowServerRepo, buildPlatforms(opts))
+		if err != nil {
+			return "", fmt.Errorf("failed to get base image: %v", err)
+		}
+		log.Info().Str("image", baseImage).Msg("base image to be used for building agent image")
+		if err := checkBaseImageFramework(ctx, inputSpec, baseImage); err != nil {
+			return "", err
+		}
+		if opts.Daemonless {
+			err = assembleImage(ctx, img, workspacePath, inputSpec, agentSourceDir, baseImage, labels, opts)
+		} else {
+			err = buildImageWithBuildx(ctx, nil, img, workspacePath, inputSpec, agentSourceDir, baseImage, labels, opts)
+		}
+		if err != nil {
+			return "", err
+		}
+		return img, recordBuild(ctx, nil, img, workspacePath, agentSourceDir, baseImage, labels, opts)
+	}
+
+	containerEngine, err := engine.Get(opts.Engine)
 	if err != nil {
-		return "", fmt.Errorf("failed to initialize docker client: %v", err)
+		return "", err
+	}
+	client, err := containerEngine.NewClient(ctx)
+	if err != nil {
+		return "", err
 	}
-	defer dockerCli.Client().Close()
+	defer client.Close()
 
 	// check if image already exists unless forceBuild is set
-	if !forceBuild {
-		found, err := findImage(ctx, dockerCli.Client(), img)
+	if !opts.ForceBuild {
+		found, err := findImage(ctx, client, img)
 		if err != nil {
 			return "", err
 		}
@@ -105,21 +173,31 @@ func EnsureContainerImage(ctx context.Context, img string, src source.AgentSourc
 		log.Info().Str("image", img).Msg("image not found on runtime host")
 	}
 
-	baseImage, err = getBaseImage(baseImage, WorkflowServerRepo)
+	baseImage, err := getBaseImage(opts.BaseImage, WorkflowServerRepo, opts.Platforms)
 	if err != nil {
 		return "", fmt.Errorf("failed to get base image: %v", err)
 	}
 	log.Info().Str("image", baseImage).Msg("base image to be used for building agent image")
+	if err := checkBaseImageFramework(ctx, inputSpec, baseImage); err != nil {
+		return "", err
+	}
+
+	if len(opts.Platforms) > 0 {
+		if err := buildImageWithBuildx(ctx, client, img, workspacePath, inputSpec, agentSourceDir, baseImage, labels, opts); err != nil {
+			return "", err
+		}
+		return img, recordBuild(ctx, client, img, workspacePath, agentSourceDir, baseImage, labels, opts)
+	}
 
 	// find base image and pull it if not found
-	found, err := findImage(ctx, dockerCli.Client(), baseImage)
+	found, err := findImage(ctx, client, baseImage)
 	if err != nil {
 		return "", err
 	}
 	if !found {
 		log.Info().Str("image", baseImage).Msg("base image not found on container runtime host")
 		// image not available locally, see if it can be pulled from registry
-		err = pullImage(ctx, dockerCli.Client(), baseImage)
+		err = pullImage(ctx, client, baseImage, opts.output())
 		if err != nil {
 			if !errdefs.IsNotFound(err) {
 				return "", fmt.Errorf("base image not found %s: %w", baseImage, err)
@@ -129,34 +207,55 @@ func EnsureContainerImage(ctx context.Context, img string, src source.AgentSourc
 	}
 
 	// build image
-	err = buildImage(ctx, dockerCli.Client(), img, workspacePath, inputSpec, agentSourceDir, assets.AgentBuilderDockerfile, baseImage)
+	err = buildImage(ctx, client, img, workspacePath, inputSpec, agentSourceDir, baseImage, labels, opts)
 	if err != nil {
 		return "", fmt.Errorf("failed to build image %s: %w", img, err)
 	}
 
-	return img, nil
+	return img, recordBuild(ctx, client, img, workspacePath, agentSourceDir, baseImage, labels, opts)
 }
 
-func getBaseImage(baseImage string, workflowServerRepo string) (string, error) {
-	if baseImage != "" {
-		return baseImage, nil
+// getBaseImage returns the base image, the latest workflow server image if baseImage is empty.
+// The base image must support all the platforms (e.g. linux/arm64) the agent image is built for.
+func getBaseImage(baseImage string, workflowServerRepo string, platforms []string) (string, error) {
+	if baseImage == "" {
+		repo, err := name.NewRepository(workflowServerRepo)
+		if err != nil {
+			return "", fmt.Errorf("Failed to create repo: %v", err)
+		}
+
+		// default page size is 1000
+		tags, err := remote.List(repo)
+		if err != nil {
+			return "", fmt.Errorf("Failed to list tags: %v", err)
+		}
+		tag, err := util.GetLatestTag(tags)
+		if err != nil {
+			return "", fmt.Errorf("Failed to get the most recent tag from the following tags: %v, %v", tags, err)
+		}
+		baseImage = fmt.Sprintf("%s:%s", workflowServerRepo, tag)
 	}
 
-	repo, err := name.NewRepository(workflowServerRepo)
+	if len(platforms) == 0 {
+		return baseImage, nil
+	}
+	ref, err := name.ParseReference(baseImage)
 	if err != nil {
-		return "", fmt.Errorf("Failed to create repo: %v", err)
+		return "", fmt.Errorf("invalid base image %s: %v", baseImage, err)
 	}
-
-	// default page size is 1000
-	tags, err := remote.List(repo)
+	desc, err := remote.Get(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
 	if err != nil {
-		return "", fmt.Errorf("Failed to list tags: %v", err)
+		return "", fmt.Errorf("failed to get base image %s: %v", baseImage, err)
 	}
-	tag, err := util.GetLatestTag(tags)
+	supported, err := imagePlatforms(desc)
 	if err != nil {
-		return "", fmt.Errorf("Failed to get the most recent tag from the following tags: %v, %v", tags, err)
+		return "", fmt.Errorf("failed to get the platforms of base image %s: %v", baseImage, err)
 	}
-	return fmt.Sprintf("%s:%s", workflowServerRepo, tag), nil
+	if missing := missingPlatforms(platforms, supported); len(missing) > 0 {
+		return "", fmt.Errorf("base image %s does not support platforms %s, it supports %s",
+			baseImage, strings.Join(missing, ", "), platformsString(supported))
+	}
+	return baseImage, nil
 }
 
 func findImage(ctx context.Context, client dockerclient.ImageAPIClient, img string) (bool, error) {
@@ -182,15 +281,38 @@ func findImage(ctx context.Context, client dockerclient.ImageAPIClient, img stri
 	return false, nil
 }
 
-func buildImage(ctx context.Context, client dockerclient.ImageAPIClient, img string, workspacePath string, inputSpec internal.AgentSpec, agentSourceDir string, dockerFile []byte, baseImage string) error {
+// buildImage builds the agent image, the layers of earlier builds are reused unless noCache is set
+func buildImage(ctx context.Context, client dockerclient.APIClient, img string, workspacePath string, inputSpec internal.AgentSpec, agentSourceDir string, baseImage string, labels map[string]string, opts BuildOptions) error {
 	log := zerolog.Ctx(ctx)
 	log.Info().Str("image", img).Msg("building image")
 
-	if err := os.WriteFile(path.Join(workspacePath, "Dockerfile"), dockerFile, util.OwnerCanReadWrite); err != nil {
-		return fmt.Errorf("failed to write dockerfile to temporary workspace dir for building image: %w", err)
+	// BuildKit is needed for cache mounts, the classic builder still reuses the layers of the dependency stage
+	ping, err := client.Ping(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to ping container runtime: %w", err)
 	}
-	if err := os.WriteFile(path.Join(workspacePath, "start_agws.sh"), assets.StartAGWSScript, util.OwnerCanReadWrite); err != nil {
-		return fmt.Errorf("failed to write dockerfile to temporary workspace dir for building image: %w", err)
+	builderVersion := types.BuilderV1
+	if ping.BuilderVersion == types.BuilderBuildKit {
+		builderVersion = types.BuilderBuildKit
+	}
+	log.Debug().Str("builder_version", string(builderVersion)).Msg("builder selected for building image")
+
+	// the credentials of private package indexes are passed as secrets, which only BuildKit supports
+	secretMounts := ""
+	if opts.PackageIndex.IsSet() {
+		if builderVersion != types.BuilderBuildKit {
+			return fmt.Errorf("package indexes (--indexUrl, --extraIndexUrl, --netrc or the %s, %s and %s env vars of wfsm build) require BuildKit to pass their urls and credentials as build secrets",
+				IndexURLEnv, ExtraIndexURLEnv, NetrcEnv)
+		}
+		secretMounts = opts.PackageIndex.secretMounts()
+	}
+
+	dockerFile, err := agentDockerfile(path.Join(workspacePath, agentSourceDir), inputSpec.Dockerfile, builderVersion == types.BuilderBuildKit, secretMounts)
+	if err != nil {
+		return err
+	}
+	if err := writeBuildFiles(workspacePath, dockerFile); err != nil {
+		return err
 	}
 
 	imageBuildContext, err := containerclient.CreateBuildContext(workspacePath)
@@ -204,37 +326,32 @@ func buildImage(ctx context.Context, client dockerclient.ImageAPIClient, img str
 		log.Debug().Msg("closed image build context")
 	}()
 
-	buildArgs := map[string]*string{
-		"AGENT_DIR":  &agentSourceDir,
-		"BASE_IMAGE": &baseImage,
+	buildArgs, err := getBuildArgs(inputSpec, agentSourceDir, baseImage)
+	if err != nil {
+		return err
 	}
 
-	deployment := manifest.GetDeployment(inputSpec.Manifest)
-	srcDeployment := deployment.DeploymentOptions[inputSpec.SelectedDeploymentOption].SourceCodeDeployment
-	if srcDeployment.FrameworkConfig.LangGraphConfig != nil {
-
-		buildArgs["AGENT_FRAMEWORK"] = &srcDeployment.FrameworkConfig.LangGraphConfig.FrameworkType
-		graph := srcDeployment.FrameworkConfig.LangGraphConfig.Graph
-		buildArgs["AGENT_OBJECT"] = &graph
-
-	} else if srcDeployment.FrameworkConfig.LlamaIndexConfig != nil {
-
-		buildArgs["AGENT_FRAMEWORK"] = &srcDeployment.FrameworkConfig.LlamaIndexConfig.FrameworkType
-		path := srcDeployment.FrameworkConfig.LlamaIndexConfig.Path
-		buildArgs["AGENT_OBJECT"] = &path
-
-	} else {
-		return fmt.Errorf("unsupported framework config")
+	var sessionID string
+	if opts.PackageIndex.IsSet() {
+		sess, err := startSecretsSession(ctx, client, opts.PackageIndex)
+		if err != nil {
+			return err
+		}
+		defer sess.Close()
+		sessionID = sess.ID()
 	}
 
 	buildResp, err := client.ImageBuild(ctx, imageBuildContext, types.ImageBuildOptions{
 		Dockerfile: "Dockerfile",
 		Tags:       []string{img},
 		BuildArgs:  buildArgs,
-		NoCache:    true,
+		Labels:     labels,
+		NoCache:    opts.ForceBuild,
 		Remove:     true,
+		Version:    builderVersion,
 		PullParent: false,
 		Platform:   util.CurrentArchToDockerPlatform(),
+		SessionID:  sessionID,
 	})
 	if err != nil {
 		return err
@@ -246,16 +363,95 @@ func buildImage(ctx context.Context, client dockerclient.ImageAPIClient, img str
 		log.Debug().Msg("closed image build response body")
 	}()
 
-	err = displayDockerLogs(buildResp.Body)
+	err = displayDockerLogs(buildResp.Body, opts.output())
 	if err != nil {
 		return err
 	}
 
+	if inputSpec.Dockerfile != "" {
+		if err := validateWorkflowServer(ctx, client, img); err != nil {
+			return fmt.Errorf("image built from %s is invalid: %w", inputSpec.Dockerfile, err)
+		}
+	}
+
 	log.Info().Msg("successfully built image")
 	return nil
 }
 
-func pullImage(ctx context.Context, client dockerclient.ImageAPIClient, img string) error {
+// workflowServerCheck imports the workflow server in the python env the entrypoint runs it in
+var workflowServerCheck = []string{"poetry", "run", "python", "-c", "import agent_workflow_server"}
+
+// validateWorkflowServer checks that the workflow server can be started in the image by running workflowServerCheck in a container
+func validateWorkflowServer(ctx context.Context, client dockerclient.ContainerAPIClient, img string) error {
+	log := zerolog.Ctx(ctx)
+	log.Info().Str("image", img).Msg("checking the workflow server in the image")
+
+	resp, err := client.ContainerCreate(ctx, &container.Config{Image: img, Entrypoint: workflowServerCheck}, nil, nil, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to create container: %w", err)
+	}
+	defer func() {
+		if err := client.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true}); err != nil {
+			log.Error().Err(err).Msg("failed to remove container checking the workflow server")
+		}
+	}()
+	if err := client.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+
+	statusCh, errCh := client.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("failed to wait for container: %w", err)
+	case status := <-statusCh:
+		if status.StatusCode != 0 {
+			return fmt.Errorf("the workflow server cannot be imported with poetry in the working dir of the image (exit code %d), "+
+				"the final stage must be based on $BASE_IMAGE", status.StatusCode)
+		}
+	}
+	return nil
+}
+
+// writeBuildFiles writes the Dockerfile and the entrypoint of the agent image to the workspace
+func writeBuildFiles(workspacePath string, dockerFile []byte) error {
+	if err := os.WriteFile(path.Join(workspacePath, "Dockerfile"), dockerFile, util.OwnerCanReadWrite); err != nil {
+		return fmt.Errorf("failed to write dockerfile to temporary workspace dir for building image: %w", err)
+	}
+	if err := os.WriteFile(path.Join(workspacePath, "start_agws.sh"), assets.StartAGWSScript, util.OwnerCanReadWrite); err != nil {
+		return fmt.Errorf("failed to write dockerfile to temporary workspace dir for building image: %w", err)
+	}
+	return nil
+}
+
+// checkBaseImageFramework checks that the workflow server of the base image runs the framework of the agent
+func checkBaseImageFramework(ctx context.Context, inputSpec internal.AgentSpec, baseImage string) error {
+	buildArgs, err := getBuildArgs(inputSpec, "", baseImage)
+	if err != nil {
+		return err
+	}
+	return checkWorkflowServer(ctx, *buildArgs["AGENT_FRAMEWORK"], baseImage)
+}
+
+// getBuildArgs returns the build args of the agent Dockerfile
+func getBuildArgs(inputSpec internal.AgentSpec, agentSourceDir string, baseImage string) (map[string]*string, error) {
+	buildArgs := map[string]*string{
+		"AGENT_DIR":  &agentSourceDir,
+		"BASE_IMAGE": &baseImage,
+	}
+
+	// the framework args are set by the adapter registered for the framework of the agent, see RegisterFramework
+	deployment := manifest.GetDeployment(inputSpec.Manifest)
+	srcDeployment := deployment.DeploymentOptions[inputSpec.SelectedDeploymentOption].SourceCodeDeployment
+	framework, object, err := frameworkBuildArgs(srcDeployment.FrameworkConfig)
+	if err != nil {
+		return nil, err
+	}
+	buildArgs["AGENT_FRAMEWORK"] = &framework
+	buildArgs["AGENT_OBJECT"] = &object
+	return buildArgs, nil
+}
+
+func pullImage(ctx context.Context, client dockerclient.ImageAPIClient, img string, out io.Writer) error {
 	log := zerolog.Ctx(ctx)
 	log.Info().Msgf("pulling image: %s", img)
 
@@ -270,7 +466,7 @@ func pullImage(ctx context.Context, client dockerclient.ImageAPIClient, img stri
 		}
 	}()
 
-	err = displayDockerLogs(reader)
+	err = displayDockerLogs(reader, out)
 	if err != nil {
 		return err
 	}
@@ -278,10 +474,12 @@ func pullImage(ctx context.Context, client dockerclient.ImageAPIClient, img stri
 	return nil
 }
 
-func displayDockerLogs(reader io.ReadCloser) error {
+// displayDockerLogs writes the messages of an image build or pull to out, progress is rendered in place on stdout only
+func displayDockerLogs(reader io.ReadCloser, out io.Writer) error {
 	rd := bufio.NewReader(reader)
 	var logLine []byte
 	var imageBuildLogLine jsonmessage.JSONMessage
+	trace := newBuildKitTrace(out)
 	for {
 		line, isPrefix, err := rd.ReadLine()
 		if err != nil {
@@ -293,10 +491,18 @@ func displayDockerLogs(reader io.ReadCloser) error {
 		}
 		logLine = append(logLine, line...)
 		if !isPrefix {
+			imageBuildLogLine = jsonmessage.JSONMessage{}
 			if err = json.Unmarshal(logLine, &imageBuildLogLine); err != nil {
 				return fmt.Errorf("failed to unmarshal image build log line: %w", err)
 			}
-			err = imageBuildLogLine.Display(os.Stdout, true)
+			if imageBuildLogLine.ID == buildKitTraceID && imageBuildLogLine.Aux != nil {
+				if err = trace.display(*imageBuildLogLine.Aux); err != nil {
+					return err
+				}
+				logLine = logLine[:0]
+				continue
+			}
+			err = imageBuildLogLine.Display(out, out == os.Stdout)
 			if err != nil {
 				return err
 			}
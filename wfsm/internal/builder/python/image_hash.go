@@ -6,13 +6,21 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/cisco-eti/wfsm/assets"
 )
 
 // CalculateHash calculates a hash code for the given path by iterating over all files and folders
 // recursively and using the size of each file.
-func calculateHash(path string, baseImage string) string {
+// Images built for explicit platforms have a different hash than the ones built for the platform of the host,
+// daemonless images a different hash than the ones built by a container engine.
+// The labels are hashed, so images are rebuilt when e.g. the revision of their source changes.
+func calculateHash(path string, baseImage string, platforms []string, daemonless bool, customDockerfile string, labels map[string]string) string {
 	hasher := sha256.New()
 
 	// Walk through the directory recursively
@@ -39,6 +47,21 @@ func calculateHash(path string, baseImage string) string {
 	}
 
 	hasher.Write([]byte(baseImage))
+	if len(platforms) > 0 {
+		hasher.Write([]byte(strings.Join(platforms, ",")))
+	}
+	if daemonless {
+		hasher.Write([]byte("daemonless"))
+	}
+	for _, label := range slices.Sorted(maps.Keys(labels)) {
+		hasher.Write([]byte(label + "=" + labels[label]))
+	}
+	// the custom Dockerfile is part of the agent source, only its path is hashed
+	hasher.Write([]byte(customDockerfile))
+	// images are rebuilt when the Dockerfile or the entrypoint of the agent image change
+	hasher.Write(assets.AgentBuilderDockerfileTemplate)
+	hasher.Write(assets.AgentEntrypointDockerfile)
+	hasher.Write(assets.StartAGWSScript)
 
 	// Get the final hash sum
 	hashSum := hasher.Sum(nil)
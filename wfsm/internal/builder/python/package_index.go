@@ -0,0 +1,141 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package python
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strings"
+
+	dockerclient "github.com/docker/docker/client"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/rs/zerolog"
+)
+
+// ids of the BuildKit secrets of the package index, they are mounted by the RUN instructions installing packages
+// and never stored in image layers, custom Dockerfiles can mount them as well
+const (
+	pipConfSecret = "pip_conf"
+	netrcSecret   = "netrc"
+)
+
+// PackageIndex configures the python package indexes the dependencies of the agents are installed from,
+// e.g. an internal PyPI. The index urls may contain credentials, so they are passed as BuildKit secrets like the netrc file.
+type PackageIndex struct {
+	// IndexURL replaces PyPI, e.g. https://pypi.example.com/simple
+	IndexURL string
+	// ExtraIndexURLs are searched in addition to the index
+	ExtraIndexURLs []string
+	// NetrcPath is the path of a netrc file with the credentials of the indexes
+	NetrcPath string
+}
+
+// env vars of pip setting the package index
+const (
+	IndexURLEnv      = "PIP_INDEX_URL"
+	ExtraIndexURLEnv = "PIP_EXTRA_INDEX_URL"
+	NetrcEnv         = "NETRC"
+)
+
+// PackageIndexFromEnv returns the package index set by the PIP_INDEX_URL, PIP_EXTRA_INDEX_URL and NETRC env vars of the host
+func PackageIndexFromEnv() PackageIndex {
+	return PackageIndex{
+		IndexURL:       os.Getenv(IndexURLEnv),
+		ExtraIndexURLs: strings.Fields(os.Getenv(ExtraIndexURLEnv)),
+		NetrcPath:      os.Getenv(NetrcEnv),
+	}
+}
+
+// IsSet returns true if the dependencies are not only installed from PyPI without credentials
+func (p PackageIndex) IsSet() bool {
+	return p.IndexURL != "" || len(p.ExtraIndexURLs) > 0 || p.NetrcPath != ""
+}
+
+// secrets returns the BuildKit secrets of the package index: a pip.conf with the index urls and the netrc file
+func (p PackageIndex) secrets() (map[string][]byte, error) {
+	secrets := make(map[string][]byte)
+	if p.IndexURL != "" || len(p.ExtraIndexURLs) > 0 {
+		var pipConf strings.Builder
+		pipConf.WriteString("[global]\n")
+		if p.IndexURL != "" {
+			fmt.Fprintf(&pipConf, "index-url = %s\n", p.IndexURL)
+		}
+		if len(p.ExtraIndexURLs) > 0 {
+			fmt.Fprintf(&pipConf, "extra-index-url = %s\n", strings.Join(p.ExtraIndexURLs, " "))
+		}
+		secrets[pipConfSecret] = []byte(pipConf.String())
+	}
+	if p.NetrcPath != "" {
+		netrc, err := os.ReadFile(p.NetrcPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read netrc file: %v", err)
+		}
+		secrets[netrcSecret] = netrc
+	}
+	return secrets, nil
+}
+
+// secretMounts returns the mounts of the secrets of the package index of the RUN instructions installing packages,
+// pip reads the index urls from /etc/pip.conf and the credentials from ~/.netrc
+func (p PackageIndex) secretMounts() string {
+	var mounts strings.Builder
+	if p.IndexURL != "" || len(p.ExtraIndexURLs) > 0 {
+		fmt.Fprintf(&mounts, "--mount=type=secret,id=%s,target=/etc/pip.conf ", pipConfSecret)
+	}
+	if p.NetrcPath != "" {
+		fmt.Fprintf(&mounts, "--mount=type=secret,id=%s,target=/root/.netrc ", netrcSecret)
+	}
+	return mounts.String()
+}
+
+// startSecretsSession starts the BuildKit session serving the secrets of the package index to image builds of the client,
+// the returned session must be closed once the build finished
+func startSecretsSession(ctx context.Context, client dockerclient.APIClient, packageIndex PackageIndex) (*session.Session, error) {
+	log := zerolog.Ctx(ctx)
+
+	secrets, err := packageIndex.secrets()
+	if err != nil {
+		return nil, err
+	}
+	sess, err := session.NewSession(ctx, "wfsm")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create build session: %v", err)
+	}
+	sess.Allow(secretsprovider.FromMap(secrets))
+
+	dialSession := func(ctx context.Context, proto string, meta map[string][]string) (net.Conn, error) {
+		return client.DialHijack(ctx, "/session", proto, meta)
+	}
+	go func() {
+		if err := sess.Run(ctx, dialSession); err != nil {
+			log.Error().Err(err).Msg("build session failed")
+		}
+	}()
+	return sess, nil
+}
+
+// writeSecretFiles writes the secrets of the package index to files of dir for docker buildx,
+// it returns the --secret flags of the files
+func writeSecretFiles(dir string, packageIndex PackageIndex) ([]string, error) {
+	secrets, err := packageIndex.secrets()
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, 0, 2*len(secrets))
+	for _, id := range []string{pipConfSecret, netrcSecret} {
+		secret, ok := secrets[id]
+		if !ok {
+			continue
+		}
+		secretFile := path.Join(dir, id)
+		if err := os.WriteFile(secretFile, secret, 0600); err != nil {
+			return nil, fmt.Errorf("failed to write build secret %s: %v", id, err)
+		}
+		args = append(args, "--secret", fmt.Sprintf("id=%s,src=%s", id, secretFile))
+	}
+	return args, nil
+}
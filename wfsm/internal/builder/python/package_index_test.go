@@ -0,0 +1,93 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package python
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackageIndex(t *testing.T) {
+	netrcPath := path.Join(t.TempDir(), ".netrc")
+	assert.NoError(t, os.WriteFile(netrcPath, []byte("machine pypi.example.com login user password secret\n"), 0600))
+
+	tests := []struct {
+		name                 string
+		packageIndex         PackageIndex
+		expectedSecrets      map[string]string
+		expectedSecretMounts string
+	}{
+		{
+			name:            "PyPI",
+			expectedSecrets: map[string]string{},
+		},
+		{
+			name:         "index urls",
+			packageIndex: PackageIndex{IndexURL: "https://pypi.example.com/simple", ExtraIndexURLs: []string{"https://a.example.com/simple", "https://b.example.com/simple"}},
+			expectedSecrets: map[string]string{
+				pipConfSecret: "[global]\nindex-url = https://pypi.example.com/simple\nextra-index-url = https://a.example.com/simple https://b.example.com/simple\n",
+			},
+			expectedSecretMounts: "--mount=type=secret,id=pip_conf,target=/etc/pip.conf ",
+		},
+		{
+			name:         "extra index url with netrc",
+			packageIndex: PackageIndex{ExtraIndexURLs: []string{"https://pypi.example.com/simple"}, NetrcPath: netrcPath},
+			expectedSecrets: map[string]string{
+				pipConfSecret: "[global]\nextra-index-url = https://pypi.example.com/simple\n",
+				netrcSecret:   "machine pypi.example.com login user password secret\n",
+			},
+			expectedSecretMounts: "--mount=type=secret,id=pip_conf,target=/etc/pip.conf --mount=type=secret,id=netrc,target=/root/.netrc ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secrets, err := tt.packageIndex.secrets()
+			assert.NoError(t, err)
+			assert.Len(t, secrets, len(tt.expectedSecrets))
+			for id, expected := range tt.expectedSecrets {
+				assert.Equal(t, expected, string(secrets[id]))
+			}
+			assert.Equal(t, tt.expectedSecretMounts, tt.packageIndex.secretMounts())
+			assert.Equal(t, len(tt.expectedSecrets) > 0, tt.packageIndex.IsSet())
+
+			// buildx reads the secrets from files outside of the build context
+			secretsDir := t.TempDir()
+			args, err := writeSecretFiles(secretsDir, tt.packageIndex)
+			assert.NoError(t, err)
+			assert.Len(t, args, 2*len(tt.expectedSecrets))
+			for id, expected := range tt.expectedSecrets {
+				assert.Contains(t, args, "id="+id+",src="+path.Join(secretsDir, id))
+				secret, err := os.ReadFile(path.Join(secretsDir, id))
+				assert.NoError(t, err)
+				assert.Equal(t, expected, string(secret))
+			}
+		})
+	}
+}
+
+func TestPackageIndexFromEnv(t *testing.T) {
+	t.Setenv("PIP_INDEX_URL", "https://pypi.example.com/simple")
+	t.Setenv("PIP_EXTRA_INDEX_URL", "https://a.example.com/simple  https://b.example.com/simple")
+	t.Setenv("NETRC", "/home/user/.netrc")
+
+	assert.Equal(t, PackageIndex{
+		IndexURL:       "https://pypi.example.com/simple",
+		ExtraIndexURLs: []string{"https://a.example.com/simple", "https://b.example.com/simple"},
+		NetrcPath:      "/home/user/.netrc",
+	}, PackageIndexFromEnv())
+}
+
+func TestGenerateDockerfile_SecretMounts(t *testing.T) {
+	agentSrcPath := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(agentSrcPath, "requirements.txt"), nil, 0o600))
+
+	packageIndex := PackageIndex{IndexURL: "https://pypi.example.com/simple"}
+	dockerfile, err := generateDockerfile(agentSrcPath, true, packageIndex.secretMounts())
+	assert.NoError(t, err)
+	assert.Contains(t, string(dockerfile), "RUN --mount=type=cache,target=/root/.cache/pip --mount=type=secret,id=pip_conf,target=/etc/pip.conf poetry run pip install -r /opt/agent_deps/requirements.txt")
+	assert.Contains(t, string(dockerfile), "RUN --mount=type=cache,target=/root/.cache/pip --mount=type=secret,id=pip_conf,target=/etc/pip.conf poetry run pip install /opt/agent_src")
+	assert.NotContains(t, string(dockerfile), "pypi.example.com")
+}
@@ -0,0 +1,168 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package python
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/docker/docker/api/types/container"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/rs/zerolog"
+
+	"github.com/cisco-eti/wfsm/internal/buildreport"
+)
+
+// pipList lists the python packages installed in the python env the entrypoint runs the workflow server in
+var pipList = []string{"poetry", "run", "pip", "list", "--format", "json", "--disable-pip-version-check"}
+
+// installedPackages returns the python packages installed in the image by running pipList in a container
+func installedPackages(ctx context.Context, client dockerclient.ContainerAPIClient, img string) ([]buildreport.Package, error) {
+	log := zerolog.Ctx(ctx)
+	log.Info().Str("image", img).Msg("listing the python packages installed in the image")
+
+	resp, err := client.ContainerCreate(ctx, &container.Config{Image: img, Entrypoint: pipList}, nil, nil, nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create container: %w", err)
+	}
+	defer func() {
+		if err := client.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true}); err != nil {
+			log.Error().Err(err).Msg("failed to remove container listing the python packages")
+		}
+	}()
+	if err := client.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to start container: %w", err)
+	}
+
+	statusCh, errCh := client.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		return nil, fmt.Errorf("failed to wait for container: %w", err)
+	case status := <-statusCh:
+		if status.StatusCode != 0 {
+			return nil, fmt.Errorf("pip list failed in the image (exit code %d)", status.StatusCode)
+		}
+	}
+
+	logs, err := client.ContainerLogs(ctx, resp.ID, container.LogsOptions{ShowStdout: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the output of pip list: %w", err)
+	}
+	defer logs.Close()
+	var stdout bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &bytes.Buffer{}, logs); err != nil {
+		return nil, fmt.Errorf("failed to read the output of pip list: %w", err)
+	}
+	var packages []buildreport.Package
+	if err := json.Unmarshal(stdout.Bytes(), &packages); err != nil {
+		return nil, fmt.Errorf("failed to parse the output of pip list: %w", err)
+	}
+	return packages, nil
+}
+
+// lockedPackages returns the python packages pinned by the dependency files of the agent in agentSrcPath,
+// or the wheels of the wheelhouse if it is not empty. Dependencies of pyproject.toml files without lock file are not pinned.
+func lockedPackages(agentSrcPath string, wheelhousePath string) ([]buildreport.Package, error) {
+	if wheelhousePath != "" {
+		return wheelhousePackages(wheelhousePath)
+	}
+	params, err := detectDependencies(agentSrcPath)
+	if err != nil {
+		return nil, err
+	}
+	switch params.Installer {
+	case installerUV:
+		return lockFilePackages(path.Join(agentSrcPath, "uv.lock"))
+	case installerPoetry:
+		if _, err := os.Stat(path.Join(agentSrcPath, "poetry.lock")); err == nil {
+			return lockFilePackages(path.Join(agentSrcPath, "poetry.lock"))
+		}
+	case installerRequirements:
+		var packages []buildreport.Package
+		for _, requirementsFile := range params.DependencyFiles {
+			requirements, err := requirementsPackages(path.Join(agentSrcPath, requirementsFile))
+			if err != nil {
+				return nil, err
+			}
+			packages = append(packages, requirements...)
+		}
+		return packages, nil
+	}
+	return nil, nil
+}
+
+// lockFilePackages returns the packages of a uv.lock or poetry.lock file, except the project itself
+func lockFilePackages(lockFilePath string) ([]buildreport.Package, error) {
+	var lockFile struct {
+		Package []struct {
+			Name    string `toml:"name"`
+			Version string `toml:"version"`
+			Source  struct {
+				Editable string `toml:"editable"`
+				Virtual  string `toml:"virtual"`
+			} `toml:"source"`
+		} `toml:"package"`
+	}
+	if _, err := toml.DecodeFile(lockFilePath, &lockFile); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", filepath.Base(lockFilePath), err)
+	}
+	packages := make([]buildreport.Package, 0, len(lockFile.Package))
+	for _, pkg := range lockFile.Package {
+		if pkg.Source.Editable != "" || pkg.Source.Virtual != "" {
+			continue
+		}
+		packages = append(packages, buildreport.Package{Name: pkg.Name, Version: pkg.Version})
+	}
+	return packages, nil
+}
+
+// pinnedRequirement is a requirement pinned to a version, e.g. langgraph[all]==0.2.60 ; python_version >= "3.11"
+var pinnedRequirement = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)\s*(\[[^\]]*\])?\s*===?\s*([^\s;#]+)`)
+
+// requirementsPackages returns the pinned requirements of a requirements file
+func requirementsPackages(requirementsPath string) ([]buildreport.Package, error) {
+	file, err := os.Open(requirementsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", filepath.Base(requirementsPath), err)
+	}
+	defer file.Close()
+
+	var packages []buildreport.Package
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if match := pinnedRequirement.FindStringSubmatch(strings.TrimSpace(scanner.Text())); match != nil {
+			packages = append(packages, buildreport.Package{Name: match[1], Version: match[3]})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", filepath.Base(requirementsPath), err)
+	}
+	return packages, nil
+}
+
+// wheelhousePackages returns the packages of the wheels in the wheelhouse, wheel file names start with {name}-{version}-
+func wheelhousePackages(wheelhousePath string) ([]buildreport.Package, error) {
+	wheels, err := filepath.Glob(path.Join(wheelhousePath, "*.whl"))
+	if err != nil {
+		return nil, err
+	}
+	packages := make([]buildreport.Package, 0, len(wheels))
+	for _, wheel := range wheels {
+		parts := strings.Split(strings.TrimSuffix(filepath.Base(wheel), ".whl"), "-")
+		if len(parts) < 5 {
+			continue
+		}
+		packages = append(packages, buildreport.Package{Name: parts[0], Version: parts[1]})
+	}
+	return packages, nil
+}
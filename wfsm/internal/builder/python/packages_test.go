@@ -0,0 +1,96 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package python
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cisco-eti/wfsm/internal/buildreport"
+)
+
+func TestLockedPackages(t *testing.T) {
+	tests := []struct {
+		name             string
+		files            map[string]string
+		wheels           []string
+		expectedPackages []buildreport.Package
+	}{
+		{
+			name: "uv.lock",
+			files: map[string]string{
+				"pyproject.toml": "[project]\nname = \"mailcomposer\"\n",
+				"uv.lock": `version = 1
+requires-python = ">=3.11"
+
+[[package]]
+name = "langgraph"
+version = "0.2.60"
+source = { registry = "https://pypi.org/simple" }
+
+[[package]]
+name = "mailcomposer"
+version = "0.1.0"
+source = { editable = "." }
+`,
+			},
+			expectedPackages: []buildreport.Package{{Name: "langgraph", Version: "0.2.60"}},
+		},
+		{
+			name: "poetry.lock",
+			files: map[string]string{
+				"pyproject.toml": "[tool.poetry]\nname = \"mailcomposer\"\n",
+				"poetry.lock": `[[package]]
+name = "langchain-core"
+version = "0.3.29"
+description = "Building applications with LLMs through composability"
+optional = false
+
+[metadata]
+lock-version = "2.0"
+`,
+			},
+			expectedPackages: []buildreport.Package{{Name: "langchain-core", Version: "0.3.29"}},
+		},
+		{
+			name: "pinned requirements",
+			files: map[string]string{
+				"requirements.txt":     "# agent\nlanggraph[all]==0.2.60 ; python_version >= \"3.11\"\nrequests>=2.0\n-r requirements-llm.txt\n",
+				"requirements-llm.txt": "openai === 1.59.7 # pinned\n",
+			},
+			expectedPackages: []buildreport.Package{{Name: "openai", Version: "1.59.7"}, {Name: "langgraph", Version: "0.2.60"}},
+		},
+		{
+			name:  "pyproject.toml without lock file",
+			files: map[string]string{"pyproject.toml": "[project]\ndependencies = [\"langgraph>=0.2\"]\n"},
+		},
+		{
+			name:             "wheelhouse",
+			files:            map[string]string{"requirements.txt": "langgraph==0.2.60\n"},
+			wheels:           []string{"langgraph-0.2.60-py3-none-any.whl", "pydantic_core-2.27.2-cp312-cp312-manylinux_2_17_x86_64.whl", "README.md"},
+			expectedPackages: []buildreport.Package{{Name: "langgraph", Version: "0.2.60"}, {Name: "pydantic_core", Version: "2.27.2"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agentSrcPath := t.TempDir()
+			for name, content := range tt.files {
+				assert.NoError(t, os.WriteFile(path.Join(agentSrcPath, name), []byte(content), 0644))
+			}
+			wheelhousePath := ""
+			if tt.wheels != nil {
+				wheelhousePath = t.TempDir()
+				for _, wheel := range tt.wheels {
+					assert.NoError(t, os.WriteFile(path.Join(wheelhousePath, wheel), nil, 0644))
+				}
+			}
+
+			packages, err := lockedPackages(agentSrcPath, wheelhousePath)
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, tt.expectedPackages, packages)
+		})
+	}
+}
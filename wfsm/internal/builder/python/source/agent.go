@@ -3,20 +3,26 @@
 package source
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"path/filepath"
 	"strings"
 
+	"github.com/cisco-eti/wfsm/internal/cache"
 	"github.com/cisco-eti/wfsm/manifests"
 )
 
 // AgentSource interface with CopyToWorkspace method
 type AgentSource interface {
-	CopyToWorkspace(workspacePath string) error
+	CopyToWorkspace(ctx context.Context, workspacePath string) error
+	// Origin returns where the source comes from
+	Origin(ctx context.Context) Origin
 }
 
-func GetAgentSource(deployment *manifests.SourceCodeDeployment, manifestPath string) (AgentSource, error) {
+// GetAgentSource returns the source of the agent. Remote sources are verified against the matching locator
+// of the manifest and stored in srcCache if it is not nil.
+func GetAgentSource(deployment *manifests.SourceCodeDeployment, manifestPath string, locators []manifests.Locator, srcCache *cache.Cache) (AgentSource, error) {
 	parsedURL, err := url.Parse(deployment.Url)
 	if err != nil {
 		return nil, err
@@ -25,7 +31,9 @@ func GetAgentSource(deployment *manifests.SourceCodeDeployment, manifestPath str
 	case "http", "https", "":
 		if parsedURL.Host == "github.com" || strings.HasPrefix(deployment.Url, "github.com") {
 			return &GoGetSource{
-				URL: "git::" + deployment.Url,
+				URL:     "git::" + deployment.Url,
+				Locator: findLocator(locators, deployment.Url),
+				Cache:   srcCache,
 			}, nil
 		}
 		if isLocalPath(deployment.Url) && !isZipOrTarball(deployment.Url) {
@@ -35,14 +43,18 @@ func GetAgentSource(deployment *manifests.SourceCodeDeployment, manifestPath str
 			}, nil
 		}
 		return &GoGetSource{
-			URL: deployment.Url,
+			URL:     deployment.Url,
+			Locator: findLocator(locators, deployment.Url),
+			Cache:   srcCache,
 		}, nil
 	case "file":
 		// remove file:// prefix from deployment URL
 		deploymentUrl := strings.TrimPrefix(deployment.Url, "file://")
 		if isZipOrTarball(deployment.Url) {
 			return &GoGetSource{
-				URL: deployment.Url,
+				URL:     deployment.Url,
+				Locator: findLocator(locators, deployment.Url),
+				Cache:   srcCache,
 			}, nil
 		}
 		if isLocalPath(deploymentUrl) {
@@ -57,6 +69,16 @@ func GetAgentSource(deployment *manifests.SourceCodeDeployment, manifestPath str
 	return nil, fmt.Errorf("unsupported source code deployment URL: %s", deployment.Url)
 }
 
+// findLocator returns the locator pointing to the same url as the deployment option
+func findLocator(locators []manifests.Locator, url string) *manifests.Locator {
+	for i := range locators {
+		if strings.TrimSpace(locators[i].Url) == strings.TrimSpace(url) {
+			return &locators[i]
+		}
+	}
+	return nil
+}
+
 func isZipOrTarball(url string) bool {
 	// Implement logic to check if the URL is a zip or tarball file
 	return strings.HasSuffix(url, ".zip") || strings.HasSuffix(url, ".tar") || strings.HasSuffix(url, ".tar.gz")
@@ -2,15 +2,180 @@
 // SPDX-License-Identifier: Apache-2.0
 package source
 
-import "github.com/hashicorp/go-getter"
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/go-getter"
+	"github.com/rs/zerolog"
+
+	"github.com/cisco-eti/wfsm/internal/cache"
+	"github.com/cisco-eti/wfsm/manifests"
+)
 
 // GoGetSource struct implementing AgentSource interface
 type GoGetSource struct {
 	URL string
+	// Locator of the source in the agent manifest, its digest and size are verified if set
+	Locator *manifests.Locator
+	// Cache stores fetched sources, if nil sources are fetched on every build
+	Cache *cache.Cache
+}
+
+// CopyToWorkspace copies the files of the source to workspacePath, except the ones ignored by the
+// .wfsmignore (or .dockerignore) file of the source
+func (ls *GoGetSource) CopyToWorkspace(ctx context.Context, workspacePath string) error {
+	if ls.Cache == nil {
+		tmp, err := os.MkdirTemp("", "wfsm_source_")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary dir for agent source: %v", err)
+		}
+		defer os.RemoveAll(tmp)
+
+		srcPath := filepath.Join(tmp, "src")
+		if err = ls.fetch(srcPath); err != nil {
+			return err
+		}
+		return copyDir(srcPath, workspacePath)
+	}
+	treePath, err := ls.fetchCached(ctx)
+	if err != nil {
+		return err
+	}
+	return copyDir(treePath, workspacePath)
+}
+
+// fetchCached returns the path of the source tree in the cache, fetching it if needed.
+// Sources pinned by a locator digest are served from the cache when present, and they never fall back
+// to the tree the URL last resolved to: that tree was not verified against the digest.
+func (ls *GoGetSource) fetchCached(ctx context.Context) (string, error) {
+	log := zerolog.Ctx(ctx)
+
+	locatorDigest := ls.locatorDigest()
+	if locatorDigest != "" {
+		if treePath, found := ls.lookup(locatorDigest); found {
+			log.Debug().Str("url", ls.URL).Msg("agent source served from cache")
+			return treePath, nil
+		}
+	}
+	if ls.Cache.Offline() {
+		if treePath, found := ls.lookup(ls.URL); found && locatorDigest == "" {
+			log.Debug().Str("url", ls.URL).Msg("agent source served from cache")
+			return treePath, nil
+		}
+		return "", fmt.Errorf("%w (offline mode): %s", cache.ErrNotCached, ls.URL)
+	}
+
+	tmp, err := ls.Cache.TempDir()
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	srcPath := filepath.Join(tmp, "src")
+	if err = ls.fetch(srcPath); err != nil {
+		if treePath, found := ls.lookup(ls.URL); found && locatorDigest == "" {
+			log.Warn().Err(err).Str("url", ls.URL).Msg("failed to fetch agent source, using cached copy")
+			return treePath, nil
+		}
+		return "", err
+	}
+
+	digest, _, err := ls.Cache.CommitTree(srcPath)
+	if err != nil {
+		return "", err
+	}
+	if err = ls.Cache.SetRef(ls.URL, digest); err != nil {
+		return "", err
+	}
+	if locatorDigest != "" {
+		if err = ls.Cache.SetRef(locatorDigest, digest); err != nil {
+			return "", err
+		}
+	}
+	treePath, _ := ls.Cache.TreePath(digest)
+	return treePath, nil
+}
+
+func (ls *GoGetSource) lookup(ref string) (string, bool) {
+	digest, err := ls.Cache.ResolveRef(ref)
+	if err != nil {
+		return "", false
+	}
+	return ls.Cache.TreePath(digest)
+}
+
+// fetch downloads the source into dst and verifies it against the locator.
+// Archives are verified before unpacking, other sources (e.g. git repositories) are verified by their tree digest.
+func (ls *GoGetSource) fetch(dst string) error {
+	ext := archiveExtension(ls.URL)
+	if ext == "" {
+		if err := getter.Get(dst, ls.URL); err != nil {
+			return err
+		}
+		if ls.Locator == nil {
+			return nil
+		}
+		digest, size, err := cache.TreeDigest(dst)
+		if err != nil {
+			return err
+		}
+		return ls.verify(digest, size)
+	}
+
+	archiveDir, err := os.MkdirTemp("", "wfsm_archive_")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary dir for source archive: %v", err)
+	}
+	defer os.RemoveAll(archiveDir)
+
+	archivePath := filepath.Join(archiveDir, "source."+ext)
+	if err = getter.GetFile(archivePath, withoutUnpacking(ls.URL)); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to read source archive: %v", err)
+	}
+	if err = ls.verify(cache.Digest(data), int64(len(data))); err != nil {
+		return err
+	}
+	return getter.Decompressors[ext].Decompress(dst, archivePath, true, 0)
+}
+
+func (ls *GoGetSource) verify(digest string, size int64) error {
+	if ls.Locator == nil {
+		return nil
+	}
+	if err := cache.Verify(digest, size, ls.Locator.Digest, ls.Locator.Size); err != nil {
+		return fmt.Errorf("agent source %s failed verification: %v", ls.URL, err)
+	}
+	return nil
+}
+
+func (ls *GoGetSource) locatorDigest() string {
+	if ls.Locator == nil || ls.Locator.Digest == nil || *ls.Locator.Digest == "" {
+		return ""
+	}
+	return cache.NormalizeDigest(*ls.Locator.Digest)
+}
+
+func archiveExtension(url string) string {
+	for _, ext := range []string{"tar.gz", "zip", "tar"} {
+		if strings.HasSuffix(url, "."+ext) {
+			return ext
+		}
+	}
+	return ""
 }
 
-// CopyToWorkspace copies all files from sourcePath to workspacePath
-func (ls *GoGetSource) CopyToWorkspace(workspacePath string) error {
-	err := getter.Get(workspacePath, ls.URL)
-	return err
+// withoutUnpacking instructs go-getter to download the archive as is
+func withoutUnpacking(url string) string {
+	if strings.Contains(url, "?") {
+		return url + "&archive=false"
+	}
+	return url + "?archive=false"
 }
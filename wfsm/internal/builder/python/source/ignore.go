@@ -0,0 +1,47 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package source
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/moby/patternmatcher"
+	"github.com/moby/patternmatcher/ignorefile"
+)
+
+// ignoreFiles are read from the root of the agent source in this order, the first one found is used.
+// They have the syntax of .dockerignore files.
+var ignoreFiles = []string{".wfsmignore", ".dockerignore"}
+
+// defaultIgnorePatterns are never copied to the workspace, they are excluded from the image build context as well
+var defaultIgnorePatterns = []string{"**/.env", "**/.venv", "**/.git", "**/.github", "**/.idea", "**/.vscode"}
+
+// loadIgnorePatterns returns the matcher of the files in the agent source at root which are not copied to the workspace
+func loadIgnorePatterns(root string) (*patternmatcher.PatternMatcher, error) {
+	patterns := append([]string{}, defaultIgnorePatterns...)
+	for _, ignoreFile := range ignoreFiles {
+		f, err := os.Open(filepath.Join(root, ignoreFile))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %v", ignoreFile, err)
+		}
+		filePatterns, err := ignorefile.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", ignoreFile, err)
+		}
+		patterns = append(patterns, filePatterns...)
+		break
+	}
+
+	pm, err := patternmatcher.New(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ignore patterns: %v", err)
+	}
+	return pm, nil
+}
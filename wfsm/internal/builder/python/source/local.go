@@ -3,8 +3,12 @@
 package source
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/moby/patternmatcher"
 )
 
 // LocalSource struct implementing AgentSource interface
@@ -13,39 +17,64 @@ type LocalSource struct {
 	ManifestPath string
 }
 
-// CopyToWorkspace copies all files from sourcePath to workspacePath
-func (ls *LocalSource) CopyToWorkspace(workspacePath string) error {
+// CopyToWorkspace copies the files of the source to workspacePath, except the ones ignored by the
+// .wfsmignore (or .dockerignore) file of the source
+func (ls *LocalSource) CopyToWorkspace(ctx context.Context, workspacePath string) error {
 	// Copy all files from sourcePath to workspacePath
 	return copyDir(ls.ResolveSourcePath(), workspacePath)
 }
 
+// copyDir copies the agent source in src to dest, except the files matching the ignore patterns of the source
 func copyDir(src string, dest string) error {
-	// Read all files and directories from src
-	entries, err := os.ReadDir(src)
+	pm, err := loadIgnorePatterns(src)
 	if err != nil {
 		return err
 	}
-
 	// Ensure dest exists
-	err = os.MkdirAll(dest, os.ModePerm)
+	if err = os.MkdirAll(dest, os.ModePerm); err != nil {
+		return err
+	}
+	return copyTree(src, dest, "", pm, patternmatcher.MatchInfo{})
+}
+
+// copyTree copies the dir rel of the agent source at root to dest
+func copyTree(root string, dest string, rel string, pm *patternmatcher.PatternMatcher, parentMatchInfo patternmatcher.MatchInfo) error {
+	// Read all files and directories from src
+	entries, err := os.ReadDir(filepath.Join(root, rel))
 	if err != nil {
 		return err
 	}
 
 	// Copy each file and directory to dest
 	for _, entry := range entries {
-		srcPath := filepath.Join(src, entry.Name())
-		destPath := filepath.Join(dest, entry.Name())
+		entryPath := filepath.Join(rel, entry.Name())
+		ignored, matchInfo, err := pm.MatchesUsingParentResults(entryPath, parentMatchInfo)
+		if err != nil {
+			return fmt.Errorf("failed to match %s against the ignore patterns: %v", entryPath, err)
+		}
 
 		if entry.IsDir() {
+			// ignored directories are only walked if exclusion patterns (!pattern) can include some of their files
+			if ignored && !pm.Exclusions() {
+				continue
+			}
+			if !ignored {
+				if err = os.MkdirAll(filepath.Join(dest, entryPath), os.ModePerm); err != nil {
+					return err
+				}
+			}
 			// Recursively copy directory
-			err = copyDir(srcPath, destPath)
+			err = copyTree(root, dest, entryPath, pm, matchInfo)
 			if err != nil {
 				return err
 			}
-		} else {
+		} else if !ignored {
+			// the parent of files included by exclusion patterns may be ignored
+			if err = os.MkdirAll(filepath.Join(dest, rel), os.ModePerm); err != nil {
+				return err
+			}
 			// Copy file
-			err = copyFile(srcPath, destPath)
+			err = copyFile(filepath.Join(root, entryPath), filepath.Join(dest, entryPath))
 			if err != nil {
 				return err
 			}
@@ -1,7 +1,13 @@
 package source
 
 import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestLocalSource_ResolveSourcePath(t *testing.T) {
@@ -67,3 +73,72 @@ func TestLocalSource_ResolveSourcePath(t *testing.T) {
 		})
 	}
 }
+
+func TestLocalSource_CopyToWorkspace(t *testing.T) {
+	files := []string{
+		"pyproject.toml",
+		"agent/main.py",
+		"agent/__pycache__/main.cpython-312.pyc",
+		"data/train.csv",
+		"data/keep.csv",
+		"node_modules/pkg/index.js",
+		".env",
+		"agent/.env",
+		".git/HEAD",
+	}
+	tests := []struct {
+		name        string
+		ignoreFiles map[string]string
+		want        []string
+	}{
+		{
+			name: "no ignore file",
+			want: []string{"agent/__pycache__/main.cpython-312.pyc", "agent/main.py", "data/keep.csv", "data/train.csv", "node_modules/pkg/index.js", "pyproject.toml"},
+		},
+		{
+			name:        "dockerignore",
+			ignoreFiles: map[string]string{".dockerignore": "node_modules\n**/__pycache__\n"},
+			want:        []string{".dockerignore", "agent/main.py", "data/keep.csv", "data/train.csv", "pyproject.toml"},
+		},
+		{
+			name: "wfsmignore takes precedence over dockerignore",
+			ignoreFiles: map[string]string{
+				".wfsmignore":   "# datasets\ndata\n!data/keep.csv\n.*ignore\n",
+				".dockerignore": "node_modules\n",
+			},
+			want: []string{"agent/__pycache__/main.cpython-312.pyc", "agent/main.py", "data/keep.csv", "node_modules/pkg/index.js", "pyproject.toml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcPath := t.TempDir()
+			for _, file := range files {
+				writeFile(t, filepath.Join(srcPath, file), "content")
+			}
+			for file, content := range tt.ignoreFiles {
+				writeFile(t, filepath.Join(srcPath, file), content)
+			}
+
+			workspacePath := filepath.Join(t.TempDir(), "agent_src")
+			ls := &LocalSource{LocalPath: srcPath}
+			assert.NoError(t, ls.CopyToWorkspace(context.Background(), workspacePath))
+
+			got := make([]string, 0)
+			err := filepath.WalkDir(workspacePath, func(path string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				rel, err := filepath.Rel(workspacePath, path)
+				got = append(got, filepath.ToSlash(rel))
+				return err
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
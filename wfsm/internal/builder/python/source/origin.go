@@ -0,0 +1,64 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package source
+
+import (
+	"context"
+	"net/url"
+	"os/exec"
+	"strings"
+)
+
+// Origin is where the source of an agent comes from, recorded in the labels of the agent image
+type Origin struct {
+	// URL of the repository or archive of the source without credentials, empty if unknown
+	URL string
+	// Revision of the source, the ref of git urls or the commit of local git checkouts, empty if unknown
+	Revision string
+}
+
+// Origin returns the url of the source and the git ref it is fetched at
+func (ls *GoGetSource) Origin(_ context.Context) Origin {
+	rawURL := strings.TrimPrefix(ls.URL, "git::")
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return Origin{}
+	}
+	revision := parsedURL.Query().Get("ref")
+	if parsedURL.Scheme == "" {
+		// github.com/org/repo
+		parsedURL.Scheme = "https"
+		parsedURL.Host, parsedURL.Path, _ = strings.Cut(parsedURL.Path, "/")
+		parsedURL.Path = "/" + parsedURL.Path
+	}
+	// the subdir of the source in the repository (repo.git//agent) is not part of the repository url
+	parsedURL.Path, _, _ = strings.Cut(parsedURL.Path, "//")
+	return Origin{URL: withoutCredentials(parsedURL), Revision: revision}
+}
+
+// Origin returns the remote url and the commit of the git checkout the source is part of, if any
+func (ls *LocalSource) Origin(ctx context.Context) Origin {
+	srcPath := ls.ResolveSourcePath()
+	revision, err := exec.CommandContext(ctx, "git", "-C", srcPath, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return Origin{}
+	}
+	origin := Origin{Revision: strings.TrimSpace(string(revision))}
+	remoteURL, err := exec.CommandContext(ctx, "git", "-C", srcPath, "config", "--get", "remote.origin.url").Output()
+	if err != nil {
+		return origin
+	}
+	origin.URL = strings.TrimSpace(string(remoteURL))
+	// scp-like urls (git@github.com:org/repo.git) do not contain credentials
+	if parsedURL, err := url.Parse(origin.URL); err == nil && parsedURL.Scheme != "" {
+		origin.URL = withoutCredentials(parsedURL)
+	}
+	return origin
+}
+
+func withoutCredentials(u *url.URL) string {
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
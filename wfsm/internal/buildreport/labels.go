@@ -0,0 +1,44 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package buildreport
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/cisco-eti/wfsm/manifests"
+)
+
+// labels of agent images, the OCI labels (https://github.com/opencontainers/image-spec/blob/main/annotations.md)
+// and the ones identifying the manifest the agent was built from
+const (
+	SourceLabel          = "org.opencontainers.image.source"
+	VersionLabel         = "org.opencontainers.image.version"
+	RevisionLabel        = "org.opencontainers.image.revision"
+	TitleLabel           = "org.opencontainers.image.title"
+	ManifestNameLabel    = "org.agntcy.wfsm.manifest.name"
+	ManifestVersionLabel = "org.agntcy.wfsm.manifest.version"
+	ManifestDigestLabel  = "org.agntcy.wfsm.manifest.digest"
+)
+
+// Labels returns the labels of the image of the agent: the url and revision of its source, its version,
+// and the name, version and digest of its manifest, manifestJSON is the manifest added to the image.
+// Unknown values (e.g. the revision of sources not fetched from git) are left out.
+func Labels(agentManifest manifests.AgentManifest, manifestJSON []byte, sourceURL string, revision string) map[string]string {
+	labels := map[string]string{
+		TitleLabel:          agentManifest.Name,
+		ManifestNameLabel:   agentManifest.Name,
+		ManifestDigestLabel: fmt.Sprintf("sha256:%x", sha256.Sum256(manifestJSON)),
+	}
+	if agentManifest.Version != "" {
+		labels[VersionLabel] = agentManifest.Version
+		labels[ManifestVersionLabel] = agentManifest.Version
+	}
+	if sourceURL != "" {
+		labels[SourceLabel] = sourceURL
+	}
+	if revision != "" {
+		labels[RevisionLabel] = revision
+	}
+	return labels
+}
@@ -0,0 +1,126 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package buildreport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// PushSBOM attaches the SBOM to the pushed image as an OCI referrer, an artifact of the media type of the SBOM
+// whose subject is the image (the multi-arch index of images built for several platforms).
+// Registries without the referrers API get the referrers tag schema fallback. It returns the reference of the artifact.
+func PushSBOM(ctx context.Context, img string, format string, sbom []byte) (string, error) {
+	ref, err := name.ParseReference(img)
+	if err != nil {
+		return "", fmt.Errorf("invalid image %s: %v", img, err)
+	}
+	opts := []remote.Option{remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithContext(ctx)}
+	desc, err := remote.Head(ref, opts...)
+	if err != nil {
+		return "", fmt.Errorf("failed to get image %s: %v", img, err)
+	}
+
+	artifact, err := sbomArtifact(format, sbom, v1.Descriptor{MediaType: desc.MediaType, Digest: desc.Digest, Size: desc.Size})
+	if err != nil {
+		return "", err
+	}
+	digest, err := artifact.Digest()
+	if err != nil {
+		return "", fmt.Errorf("failed to get digest of SBOM artifact: %v", err)
+	}
+	artifactRef := ref.Context().Digest(digest.String())
+	if err := remote.Write(artifactRef, artifact, opts...); err != nil {
+		return "", fmt.Errorf("failed to push SBOM of image %s: %v", img, err)
+	}
+	return artifactRef.String(), nil
+}
+
+// sbomArtifact returns the artifact of the SBOM referring to the subject, its config media type is the artifact type
+func sbomArtifact(format string, sbom []byte, subject v1.Descriptor) (v1.Image, error) {
+	mediaType := types.MediaType(MediaType(format))
+	artifact, err := mutate.Append(mutate.MediaType(empty.Image, types.OCIManifestSchema1), mutate.Addendum{Layer: static.NewLayer(sbom, mediaType)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SBOM artifact: %v", err)
+	}
+	artifact = mutate.ConfigMediaType(artifact, mediaType)
+	return mutate.Subject(artifact, subject).(v1.Image), nil
+}
+
+// FetchSBOM returns the SBOM attached to the pushed image by PushSBOM and the reference of its artifact
+func FetchSBOM(ctx context.Context, img string) ([]byte, string, error) {
+	ref, err := name.ParseReference(img)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid image %s: %v", img, err)
+	}
+	opts := []remote.Option{remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithContext(ctx)}
+	desc, err := remote.Head(ref, opts...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get image %s: %v", img, err)
+	}
+	referrers, err := remote.Referrers(ref.Context().Digest(desc.Digest.String()), opts...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get referrers of image %s: %v", img, err)
+	}
+	referrersManifest, err := referrers.IndexManifest()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get referrers of image %s: %v", img, err)
+	}
+	for _, referrer := range referrersManifest.Manifests {
+		if !slices.Contains([]string{CycloneDXMediaType, SPDXMediaType}, referrer.ArtifactType) {
+			continue
+		}
+		artifactRef := ref.Context().Digest(referrer.Digest.String())
+		sbom, err := readSBOMArtifact(artifactRef, opts...)
+		if err != nil {
+			return nil, "", err
+		}
+		return sbom, artifactRef.String(), nil
+	}
+	return nil, "", fmt.Errorf("%w: no SBOM attached to image %s", ErrNotFound, img)
+}
+
+func readSBOMArtifact(ref name.Digest, opts ...remote.Option) ([]byte, error) {
+	artifact, err := remote.Image(ref, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SBOM artifact %s: %v", ref, err)
+	}
+	layers, err := artifact.Layers()
+	if err != nil || len(layers) != 1 {
+		return nil, fmt.Errorf("invalid SBOM artifact %s", ref)
+	}
+	reader, err := layers[0].Uncompressed()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SBOM artifact %s: %v", ref, err)
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// RemoteLabels returns the labels of the pushed image, the ones of the image of the platform for multi-arch images
+func RemoteLabels(ctx context.Context, img string, platform v1.Platform) (map[string]string, error) {
+	ref, err := name.ParseReference(img)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image %s: %v", img, err)
+	}
+	remoteImage, err := remote.Image(ref, remote.WithPlatform(platform), remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image %s: %v", img, err)
+	}
+	configFile, err := remoteImage.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config of image %s: %v", img, err)
+	}
+	return configFile.Config.Labels, nil
+}
@@ -0,0 +1,118 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package buildreport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cisco-eti/wfsm/internal/util"
+)
+
+// ErrNotFound is returned when there is no build report of an image
+var ErrNotFound = errors.New("build report not found")
+
+// packages sources of the SBOM
+const (
+	// InstalledPackages are listed by pip in a container of the image
+	InstalledPackages = "installed"
+	// LockedPackages are pinned by the dependency files of the agent or the wheels of its wheelhouse,
+	// the images are not run for pushed images and OCI layout tarballs
+	LockedPackages = "locked"
+)
+
+// Report is the build report of an agent image, stored with its SBOM in the folder of the image in the reports folder
+type Report struct {
+	Image           string            `json:"image"`
+	BuiltAt         time.Time         `json:"builtAt"`
+	BaseImage       string            `json:"baseImage"`
+	BaseImageDigest string            `json:"baseImageDigest,omitempty"`
+	Platforms       []string          `json:"platforms,omitempty"`
+	Pushed          bool              `json:"pushed,omitempty"`
+	OutputPath      string            `json:"outputPath,omitempty"`
+	Labels          map[string]string `json:"labels"`
+	SBOMFormat      string            `json:"sbomFormat"`
+	PackagesSource  string            `json:"packagesSource"`
+	// PackagesFallback is the reason the packages are the locked ones although the image could have been listed by pip,
+	// e.g. because it was built for another platform
+	PackagesFallback string `json:"packagesFallback,omitempty"`
+	// SBOMReferrer is the SBOM artifact attached to pushed images as an OCI referrer
+	SBOMReferrer string `json:"sbomReferrer,omitempty"`
+}
+
+// Store holds the build reports and SBOMs of the images built on the host
+type Store struct {
+	root string
+}
+
+func NewStore(root string) *Store {
+	return &Store{root: root}
+}
+
+// GetReportsFolder returns the folder of the build reports, it can be overridden with WFSM_REPORTS_FOLDER env var
+func GetReportsFolder() (string, error) {
+	reportsFolder := os.Getenv("WFSM_REPORTS_FOLDER")
+	if reportsFolder != "" {
+		return reportsFolder, nil
+	}
+	homeDir, err := util.GetHomeDir()
+	if err != nil {
+		return "", errors.New("failed to get home directory")
+	}
+	return path.Join(homeDir, ".wfsm", "images"), nil
+}
+
+// Write stores the report and the SBOM of its image, replacing the ones of an earlier build of the image
+func (s *Store) Write(report Report, sbom []byte) error {
+	imageDir := s.imageDir(report.Image)
+	if err := os.MkdirAll(imageDir, 0755); err != nil {
+		return fmt.Errorf("failed to create build report folder: %v", err)
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal build report: %v", err)
+	}
+	if err := os.WriteFile(path.Join(imageDir, SBOMFileName(report.SBOMFormat)), sbom, util.OwnerCanReadWrite); err != nil {
+		return fmt.Errorf("failed to write SBOM: %v", err)
+	}
+	// the report is written last, so a report always has an SBOM
+	if err := os.WriteFile(path.Join(imageDir, "report.json"), data, util.OwnerCanReadWrite); err != nil {
+		return fmt.Errorf("failed to write build report: %v", err)
+	}
+	return nil
+}
+
+// Load returns the report of the image and the path of its SBOM
+func (s *Store) Load(img string) (Report, string, error) {
+	imageDir := s.imageDir(img)
+	data, err := os.ReadFile(path.Join(imageDir, "report.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Report{}, "", fmt.Errorf("%w: %s", ErrNotFound, img)
+		}
+		return Report{}, "", fmt.Errorf("failed to read build report: %v", err)
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return Report{}, "", fmt.Errorf("failed to parse build report of %s: %v", img, err)
+	}
+	return report, path.Join(imageDir, SBOMFileName(report.SBOMFormat)), nil
+}
+
+// SBOMFileName returns the name of the SBOM file of the format, e.g. sbom.cdx.json
+func SBOMFileName(format string) string {
+	if format == SPDX {
+		return "sbom.spdx.json"
+	}
+	return "sbom.cdx.json"
+}
+
+// imageDir returns the folder of the image, e.g. ghcr.io_org_agntcy_wfsm-mailcomposer_<tag> for ghcr.io/org/agntcy/wfsm-mailcomposer:<tag>
+func (s *Store) imageDir(img string) string {
+	return path.Join(s.root, strings.NewReplacer("/", "_", ":", "_", "@", "_").Replace(img))
+}
@@ -0,0 +1,80 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package buildreport
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	root := t.TempDir()
+	store := NewStore(root)
+
+	_, _, err := store.Load("agntcy/wfsm-mailcomposer:0123abcd")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	report := Report{
+		Image:          "localhost:5000/agntcy/wfsm-mailcomposer:0123abcd",
+		BuiltAt:        time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC),
+		BaseImage:      "ghcr.io/agntcy/acp/wfsrv:0.1.2",
+		Platforms:      []string{"linux/amd64"},
+		Labels:         map[string]string{ManifestNameLabel: "org.agntcy.mailcomposer"},
+		SBOMFormat:     SPDX,
+		PackagesSource: InstalledPackages,
+	}
+	assert.NoError(t, store.Write(report, []byte(`{"spdxVersion": "SPDX-2.3"}`)))
+
+	loaded, sbomPath, err := store.Load(report.Image)
+	assert.NoError(t, err)
+	assert.Equal(t, report, loaded)
+	assert.Equal(t, path.Join(root, "localhost_5000_agntcy_wfsm-mailcomposer_0123abcd", "sbom.spdx.json"), sbomPath)
+	sbom, err := os.ReadFile(sbomPath)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"spdxVersion": "SPDX-2.3"}`, string(sbom))
+}
+
+func TestPushSBOM(t *testing.T) {
+	server := httptest.NewServer(registry.New(registry.WithReferrersSupport(true)))
+	defer server.Close()
+	img := strings.TrimPrefix(server.URL, "http://") + "/agntcy/wfsm-mailcomposer:0123abcd"
+
+	ref, err := name.ParseReference(img)
+	assert.NoError(t, err)
+	agentImage, err := random.Image(64, 1)
+	assert.NoError(t, err)
+	agentImage, err = mutate.Config(agentImage, v1.Config{Labels: map[string]string{ManifestNameLabel: "org.agntcy.mailcomposer"}})
+	assert.NoError(t, err)
+	assert.NoError(t, remote.Write(ref, agentImage))
+
+	ctx := context.Background()
+	_, _, err = FetchSBOM(ctx, img)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	sbom, err := SBOM{Format: CycloneDX, Image: img, Packages: []Package{{Name: "langgraph", Version: "0.2.60"}}}.Generate()
+	assert.NoError(t, err)
+	referrer, err := PushSBOM(ctx, img, CycloneDX, sbom)
+	assert.NoError(t, err)
+
+	fetched, fetchedReferrer, err := FetchSBOM(ctx, img)
+	assert.NoError(t, err)
+	assert.Equal(t, referrer, fetchedReferrer)
+	assert.Equal(t, sbom, fetched)
+
+	labels, err := RemoteLabels(ctx, img, v1.Platform{OS: "linux", Architecture: "amd64"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{ManifestNameLabel: "org.agntcy.mailcomposer"}, labels)
+}
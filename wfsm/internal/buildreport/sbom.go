@@ -0,0 +1,417 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package buildreport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// formats of the SBOMs of agent images
+const (
+	CycloneDX = "cyclonedx"
+	SPDX      = "spdx"
+)
+
+// SBOMFormats are the supported SBOM formats, the first one is the default
+var SBOMFormats = []string{CycloneDX, SPDX}
+
+// media types of the SBOM documents, the artifact types of the SBOM referrers of pushed images
+const (
+	CycloneDXMediaType = "application/vnd.cyclonedx+json"
+	SPDXMediaType      = "application/spdx+json"
+)
+
+// Package is a python package installed in an agent image
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// SBOM is the content of an SBOM of an agent image: the image, the base image it is built on and its python packages
+type SBOM struct {
+	Format          string
+	Image           string
+	BaseImage       string
+	BaseImageDigest string
+	Packages        []Package
+	// PackagesSource is where the packages were taken from, InstalledPackages or LockedPackages
+	PackagesSource string
+	// PackagesFallback is the reason the packages of an image which could have been listed by pip are the locked ones
+	PackagesFallback string
+}
+
+// keys of the properties (CycloneDX) or annotations (SPDX) of the SBOM documents recording where the packages were taken from
+const (
+	packagesSourceKey   = "wfsm:packagesSource"
+	packagesFallbackKey = "wfsm:packagesFallback"
+)
+
+// ValidateSBOMFormat checks whether the format is a supported SBOM format
+func ValidateSBOMFormat(format string) error {
+	if !slices.Contains(SBOMFormats, format) {
+		return fmt.Errorf("unsupported SBOM format %s, supported formats: %s", format, strings.Join(SBOMFormats, ", "))
+	}
+	return nil
+}
+
+// MediaType returns the media type of the SBOM documents of the format
+func MediaType(format string) string {
+	if format == SPDX {
+		return SPDXMediaType
+	}
+	return CycloneDXMediaType
+}
+
+// Generate returns the SBOM document in its format, CycloneDX 1.5 or SPDX 2.3 JSON. The packages are sorted by name.
+func (s SBOM) Generate() ([]byte, error) {
+	packages := slices.Clone(s.Packages)
+	slices.SortFunc(packages, func(a, b Package) int { return strings.Compare(normalizeName(a.Name), normalizeName(b.Name)) })
+	s.Packages = packages
+
+	switch s.Format {
+	case CycloneDX:
+		return json.MarshalIndent(s.cycloneDX(), "", "  ")
+	case SPDX:
+		return json.MarshalIndent(s.spdx(), "", "  ")
+	}
+	return nil, ValidateSBOMFormat(s.Format)
+}
+
+// ParseSBOM returns the content of an SBOM document generated by Generate
+func ParseSBOM(data []byte) (SBOM, error) {
+	var doc struct {
+		BOMFormat   string `json:"bomFormat"`
+		SPDXVersion string `json:"spdxVersion"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return SBOM{}, fmt.Errorf("failed to parse SBOM: %v", err)
+	}
+	switch {
+	case doc.BOMFormat == "CycloneDX":
+		var bom cycloneDXBOM
+		if err := json.Unmarshal(data, &bom); err != nil {
+			return SBOM{}, fmt.Errorf("failed to parse CycloneDX SBOM: %v", err)
+		}
+		return bom.sbom(), nil
+	case doc.SPDXVersion != "":
+		var spdxDoc spdxDocument
+		if err := json.Unmarshal(data, &spdxDoc); err != nil {
+			return SBOM{}, fmt.Errorf("failed to parse SPDX SBOM: %v", err)
+		}
+		return spdxDoc.sbom(), nil
+	}
+	return SBOM{}, fmt.Errorf("SBOM is neither a CycloneDX nor an SPDX document")
+}
+
+type cycloneDXBOM struct {
+	BOMFormat    string                `json:"bomFormat"`
+	SpecVersion  string                `json:"specVersion"`
+	SerialNumber string                `json:"serialNumber"`
+	Version      int                   `json:"version"`
+	Metadata     cycloneDXMetadata     `json:"metadata"`
+	Components   []cycloneDXComponent  `json:"components"`
+	Dependencies []cycloneDXDependency `json:"dependencies,omitempty"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp  string              `json:"timestamp"`
+	Tools      cycloneDXTools      `json:"tools"`
+	Component  cycloneDXComponent  `json:"component"`
+	Properties []cycloneDXProperty `json:"properties,omitempty"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cycloneDXTools struct {
+	Components []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXComponent struct {
+	Type    string          `json:"type"`
+	BOMRef  string          `json:"bom-ref,omitempty"`
+	Name    string          `json:"name"`
+	Version string          `json:"version,omitempty"`
+	PURL    string          `json:"purl,omitempty"`
+	Hashes  []cycloneDXHash `json:"hashes,omitempty"`
+}
+
+type cycloneDXHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cycloneDXDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+func (s SBOM) cycloneDX() cycloneDXBOM {
+	imageName, imageVersion := splitImage(s.Image)
+	bom := cycloneDXBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + uuid.NewString(),
+		Version:      1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Tools:     cycloneDXTools{Components: []cycloneDXComponent{{Type: "application", Name: "wfsm"}}},
+			Component: cycloneDXComponent{Type: "container", BOMRef: s.Image, Name: imageName, Version: imageVersion},
+		},
+		Components: make([]cycloneDXComponent, 0, len(s.Packages)+1),
+	}
+	for _, property := range s.packagesProperties() {
+		bom.Metadata.Properties = append(bom.Metadata.Properties, cycloneDXProperty{Name: property[0], Value: property[1]})
+	}
+	dependsOn := make([]string, 0, len(s.Packages)+1)
+	if s.BaseImage != "" {
+		baseName, baseVersion := splitImage(s.BaseImage)
+		base := cycloneDXComponent{Type: "container", BOMRef: s.BaseImage, Name: baseName, Version: baseVersion, PURL: ociPURL(s.BaseImage, s.BaseImageDigest)}
+		if hash, ok := strings.CutPrefix(s.BaseImageDigest, "sha256:"); ok {
+			base.Hashes = []cycloneDXHash{{Alg: "SHA-256", Content: hash}}
+		}
+		bom.Components = append(bom.Components, base)
+		dependsOn = append(dependsOn, base.BOMRef)
+	}
+	for _, pkg := range s.Packages {
+		purl := pypiPURL(pkg)
+		bom.Components = append(bom.Components, cycloneDXComponent{Type: "library", BOMRef: purl, Name: pkg.Name, Version: pkg.Version, PURL: purl})
+		dependsOn = append(dependsOn, purl)
+	}
+	bom.Dependencies = []cycloneDXDependency{{Ref: s.Image, DependsOn: dependsOn}}
+	return bom
+}
+
+func (bom cycloneDXBOM) sbom() SBOM {
+	s := SBOM{Format: CycloneDX, Image: bom.Metadata.Component.BOMRef}
+	for _, property := range bom.Metadata.Properties {
+		s.setPackagesProperty(property.Name, property.Value)
+	}
+	for _, component := range bom.Components {
+		switch {
+		case component.Type == "container":
+			s.BaseImage = component.BOMRef
+			for _, hash := range component.Hashes {
+				if hash.Alg == "SHA-256" {
+					s.BaseImageDigest = "sha256:" + hash.Content
+				}
+			}
+		case strings.HasPrefix(component.PURL, "pkg:pypi/"):
+			s.Packages = append(s.Packages, Package{Name: component.Name, Version: component.Version})
+		}
+	}
+	return s
+}
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+	Annotations       []spdxAnnotation   `json:"annotations,omitempty"`
+}
+
+type spdxAnnotation struct {
+	AnnotationDate string `json:"annotationDate"`
+	AnnotationType string `json:"annotationType"`
+	Annotator      string `json:"annotator"`
+	Comment        string `json:"comment"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID                string            `json:"SPDXID"`
+	Name                  string            `json:"name"`
+	VersionInfo           string            `json:"versionInfo,omitempty"`
+	DownloadLocation      string            `json:"downloadLocation"`
+	PrimaryPackagePurpose string            `json:"primaryPackagePurpose"`
+	Checksums             []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs          []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+const (
+	spdxImageID     = "SPDXRef-Image"
+	spdxBaseImageID = "SPDXRef-BaseImage"
+)
+
+func (s SBOM) spdx() spdxDocument {
+	imageName, imageVersion := splitImage(s.Image)
+	created := time.Now().UTC().Format(time.RFC3339)
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              s.Image,
+		DocumentNamespace: fmt.Sprintf("https://agntcy.org/spdxdocs/wfsm/%s-%s", url.PathEscape(imageName), uuid.NewString()),
+		CreationInfo: spdxCreationInfo{
+			Created:  created,
+			Creators: []string{"Tool: wfsm"},
+		},
+		Packages: []spdxPackage{{
+			SPDXID:                spdxImageID,
+			Name:                  imageName,
+			VersionInfo:           imageVersion,
+			DownloadLocation:      "NOASSERTION",
+			PrimaryPackagePurpose: "CONTAINER",
+		}},
+		Relationships: []spdxRelationship{{SPDXElementID: "SPDXRef-DOCUMENT", RelationshipType: "DESCRIBES", RelatedSPDXElement: spdxImageID}},
+	}
+	for _, property := range s.packagesProperties() {
+		doc.Annotations = append(doc.Annotations, spdxAnnotation{
+			AnnotationDate: created,
+			AnnotationType: "OTHER",
+			Annotator:      "Tool: wfsm",
+			Comment:        property[0] + "=" + property[1],
+		})
+	}
+	if s.BaseImage != "" {
+		baseName, baseVersion := splitImage(s.BaseImage)
+		base := spdxPackage{
+			SPDXID:                spdxBaseImageID,
+			Name:                  baseName,
+			VersionInfo:           baseVersion,
+			DownloadLocation:      "NOASSERTION",
+			PrimaryPackagePurpose: "CONTAINER",
+		}
+		if hash, ok := strings.CutPrefix(s.BaseImageDigest, "sha256:"); ok {
+			base.Checksums = []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: hash}}
+			base.ExternalRefs = []spdxExternalRef{{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: ociPURL(s.BaseImage, s.BaseImageDigest)}}
+		}
+		doc.Packages = append(doc.Packages, base)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{SPDXElementID: spdxImageID, RelationshipType: "DESCENDANT_OF", RelatedSPDXElement: spdxBaseImageID})
+	}
+	for _, pkg := range s.Packages {
+		id := "SPDXRef-pypi-" + normalizeName(pkg.Name)
+		doc.Packages = append(doc.Packages, spdxPackage{
+			SPDXID:                id,
+			Name:                  pkg.Name,
+			VersionInfo:           pkg.Version,
+			DownloadLocation:      "NOASSERTION",
+			PrimaryPackagePurpose: "LIBRARY",
+			ExternalRefs:          []spdxExternalRef{{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: pypiPURL(pkg)}},
+		})
+		doc.Relationships = append(doc.Relationships, spdxRelationship{SPDXElementID: spdxImageID, RelationshipType: "CONTAINS", RelatedSPDXElement: id})
+	}
+	return doc
+}
+
+func (doc spdxDocument) sbom() SBOM {
+	s := SBOM{Format: SPDX, Image: doc.Name}
+	for _, annotation := range doc.Annotations {
+		if name, value, ok := strings.Cut(annotation.Comment, "="); ok {
+			s.setPackagesProperty(name, value)
+		}
+	}
+	for _, pkg := range doc.Packages {
+		switch {
+		case pkg.SPDXID == spdxBaseImageID:
+			s.BaseImage = pkg.Name
+			if pkg.VersionInfo != "" {
+				s.BaseImage = joinImage(pkg.Name, pkg.VersionInfo)
+			}
+			for _, checksum := range pkg.Checksums {
+				if checksum.Algorithm == "SHA256" {
+					s.BaseImageDigest = "sha256:" + checksum.ChecksumValue
+				}
+			}
+		case pkg.PrimaryPackagePurpose == "LIBRARY":
+			s.Packages = append(s.Packages, Package{Name: pkg.Name, Version: pkg.VersionInfo})
+		}
+	}
+	return s
+}
+
+// packagesProperties returns the name and value of the properties recording where the packages were taken from
+func (s SBOM) packagesProperties() [][2]string {
+	properties := make([][2]string, 0, 2)
+	if s.PackagesSource != "" {
+		properties = append(properties, [2]string{packagesSourceKey, s.PackagesSource})
+	}
+	if s.PackagesFallback != "" {
+		properties = append(properties, [2]string{packagesFallbackKey, s.PackagesFallback})
+	}
+	return properties
+}
+
+func (s *SBOM) setPackagesProperty(name string, value string) {
+	switch name {
+	case packagesSourceKey:
+		s.PackagesSource = value
+	case packagesFallbackKey:
+		s.PackagesFallback = value
+	}
+}
+
+// splitImage splits the image into its name and its tag or digest
+func splitImage(img string) (string, string) {
+	if name, digest, ok := strings.Cut(img, "@"); ok {
+		return name, digest
+	}
+	if i := strings.LastIndex(img, ":"); i > strings.LastIndex(img, "/") {
+		return img[:i], img[i+1:]
+	}
+	return img, ""
+}
+
+func joinImage(name string, version string) string {
+	if strings.Contains(version, ":") {
+		return name + "@" + version
+	}
+	return name + ":" + version
+}
+
+// normalizedNameSeparators are the separators of python package names normalized to - (PEP 503)
+var normalizedNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+func normalizeName(name string) string {
+	return normalizedNameSeparators.ReplaceAllString(strings.ToLower(name), "-")
+}
+
+// pypiPURL returns the package url of the python package, e.g. pkg:pypi/langchain-core@0.3.0
+func pypiPURL(pkg Package) string {
+	return fmt.Sprintf("pkg:pypi/%s@%s", normalizeName(pkg.Name), url.PathEscape(pkg.Version))
+}
+
+// ociPURL returns the package url of the image with the digest, e.g. pkg:oci/wfsrv@sha256%3A...?repository_url=ghcr.io/agntcy/acp/wfsrv
+func ociPURL(img string, digest string) string {
+	if digest == "" {
+		return ""
+	}
+	name, _ := splitImage(img)
+	return fmt.Sprintf("pkg:oci/%s@%s?repository_url=%s", name[strings.LastIndex(name, "/")+1:], url.QueryEscape(digest), name)
+}
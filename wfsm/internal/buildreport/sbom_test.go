@@ -0,0 +1,97 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package buildreport
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cisco-eti/wfsm/manifests"
+)
+
+func TestSBOM(t *testing.T) {
+	sbom := SBOM{
+		Image:           "ghcr.io/org/agntcy/wfsm-mailcomposer:0123abcd",
+		BaseImage:       "ghcr.io/agntcy/acp/wfsrv:0.1.2",
+		BaseImageDigest: "sha256:4b825dc642cb6eb9a060e54bf8d69288fbee4904a0c8d4c3f5b6a0b0b5d6e7f8",
+		Packages: []Package{
+			{Name: "langgraph", Version: "0.2.60"},
+			{Name: "Agent_Workflow_Server", Version: "0.1.2"},
+		},
+		PackagesSource:   LockedPackages,
+		PackagesFallback: "image built for platforms linux/s390x, not the platform of the host",
+	}
+	expectedPackages := []Package{
+		{Name: "Agent_Workflow_Server", Version: "0.1.2"},
+		{Name: "langgraph", Version: "0.2.60"},
+	}
+
+	for _, format := range SBOMFormats {
+		t.Run(format, func(t *testing.T) {
+			sbom.Format = format
+			doc, err := sbom.Generate()
+			assert.NoError(t, err)
+			assert.True(t, json.Valid(doc))
+			assert.Contains(t, string(doc), "pkg:pypi/agent-workflow-server@0.1.2")
+			assert.Contains(t, string(doc), "pkg:oci/wfsrv@sha256%3A4b825dc642cb6eb9a060e54bf8d69288fbee4904a0c8d4c3f5b6a0b0b5d6e7f8?repository_url=ghcr.io/agntcy/acp/wfsrv")
+
+			parsed, err := ParseSBOM(doc)
+			assert.NoError(t, err)
+			assert.Equal(t, format, parsed.Format)
+			assert.Equal(t, sbom.Image, parsed.Image)
+			assert.Equal(t, sbom.BaseImage, parsed.BaseImage)
+			assert.Equal(t, sbom.BaseImageDigest, parsed.BaseImageDigest)
+			assert.Equal(t, expectedPackages, parsed.Packages)
+			assert.Equal(t, sbom.PackagesSource, parsed.PackagesSource)
+			assert.Equal(t, sbom.PackagesFallback, parsed.PackagesFallback)
+		})
+	}
+
+	_, err := SBOM{Format: "syft"}.Generate()
+	assert.EqualError(t, err, "unsupported SBOM format syft, supported formats: cyclonedx, spdx")
+	_, err = ParseSBOM([]byte(`{"name": "not an SBOM"}`))
+	assert.EqualError(t, err, "SBOM is neither a CycloneDX nor an SPDX document")
+}
+
+func TestSplitImage(t *testing.T) {
+	tests := []struct {
+		image           string
+		expectedName    string
+		expectedVersion string
+	}{
+		{image: "agntcy/wfsm-mailcomposer:0123abcd", expectedName: "agntcy/wfsm-mailcomposer", expectedVersion: "0123abcd"},
+		{image: "localhost:5000/agntcy/wfsm-mailcomposer", expectedName: "localhost:5000/agntcy/wfsm-mailcomposer"},
+		{image: "ghcr.io/agntcy/acp/wfsrv@sha256:4b82", expectedName: "ghcr.io/agntcy/acp/wfsrv", expectedVersion: "sha256:4b82"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			name, version := splitImage(tt.image)
+			assert.Equal(t, tt.expectedName, name)
+			assert.Equal(t, tt.expectedVersion, version)
+		})
+	}
+}
+
+func TestLabels(t *testing.T) {
+	agentManifest := manifests.AgentManifest{Name: "org.agntcy.mailcomposer", Version: "0.0.1"}
+	manifestJSON := []byte(`{"name": "org.agntcy.mailcomposer"}`)
+
+	assert.Equal(t, map[string]string{
+		TitleLabel:           "org.agntcy.mailcomposer",
+		SourceLabel:          "https://github.com/agntcy/acp-sdk.git",
+		RevisionLabel:        "v0.1.0",
+		VersionLabel:         "0.0.1",
+		ManifestNameLabel:    "org.agntcy.mailcomposer",
+		ManifestVersionLabel: "0.0.1",
+		ManifestDigestLabel:  "sha256:127761ce9db7baf1d5f0164c37099c2ea30cc3abc09c19533a853f65fdfe54a6",
+	}, Labels(agentManifest, manifestJSON, "https://github.com/agntcy/acp-sdk.git", "v0.1.0"))
+
+	// unknown values are left out
+	assert.Equal(t, map[string]string{
+		TitleLabel:          "org.agntcy.mailcomposer",
+		ManifestNameLabel:   "org.agntcy.mailcomposer",
+		ManifestDigestLabel: "sha256:127761ce9db7baf1d5f0164c37099c2ea30cc3abc09c19533a853f65fdfe54a6",
+	}, Labels(manifests.AgentManifest{Name: "org.agntcy.mailcomposer"}, manifestJSON, "", ""))
+}
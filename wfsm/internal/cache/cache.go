@@ -0,0 +1,339 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cisco-eti/wfsm/internal/util"
+)
+
+const (
+	blobsDir   = "blobs"
+	sourcesDir = "sources"
+	refsDir    = "refs"
+	tmpDir     = "tmp"
+
+	DigestAlgorithm = "sha256"
+)
+
+// ErrNotCached is returned when an artifact is requested in offline mode and it is not present in the cache
+var ErrNotCached = errors.New("artifact not found in cache")
+
+// Cache is a content-addressed store for agent manifests and agent sources.
+//
+// The layout of the cache folder is:
+//
+//	blobs/sha256/<hex>    raw artifacts (manifests, source archives)
+//	sources/sha256/<hex>  unpacked agent source trees
+//	refs/<hex>            maps a reference (url, digest, ...) to the digest of the artifact it last resolved to
+type Cache struct {
+	root    string
+	offline bool
+}
+
+func NewCache(root string, offline bool) *Cache {
+	return &Cache{
+		root:    root,
+		offline: offline,
+	}
+}
+
+// GetCacheFolder returns the folder of the cache, it can be overridden with WFSM_CACHE_FOLDER env var
+func GetCacheFolder() (string, error) {
+	cacheFolder := os.Getenv("WFSM_CACHE_FOLDER")
+	if cacheFolder != "" {
+		return cacheFolder, nil
+	}
+	homeDir, err := util.GetHomeDir()
+	if err != nil {
+		return "", errors.New("failed to get home directory")
+	}
+	return path.Join(homeDir, ".wfsm", "cache"), nil
+}
+
+// Offline returns true if artifacts must be served only from the cache
+func (c *Cache) Offline() bool {
+	return c != nil && c.offline
+}
+
+// Load returns the content of the artifact identified by ref.
+// References pinned by a digest are served from the cache when present and fetched content is verified against
+// the digest, other references are fetched and fall back to the cached copy when fetching fails.
+// In offline mode only the cache is used. A nil cache fetches the artifact every time.
+func (c *Cache) Load(ctx context.Context, ref string, digest string, fetch func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	if digest != "" {
+		fetch = verified(digest, fetch)
+	}
+	return c.load(ctx, ref, digest, digest != "", fetch)
+}
+
+// LoadImmutable returns the content of the artifact identified by ref, a reference whose content never changes but which is
+// not the digest of the fetched content, e.g. the digest a hub or a directory assigned to an agent. The artifact is served
+// from the cache when present, fetched content is not verified.
+func (c *Cache) LoadImmutable(ctx context.Context, ref string, fetch func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	return c.load(ctx, ref, "", true, fetch)
+}
+
+func (c *Cache) load(ctx context.Context, ref string, digest string, immutable bool, fetch func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	if c == nil {
+		return fetch(ctx)
+	}
+	log := zerolog.Ctx(ctx)
+
+	cached, cacheErr := c.loadRef(ref, digest)
+	if cacheErr == nil && (immutable || c.offline) {
+		log.Debug().Str("ref", ref).Msg("artifact served from cache")
+		return cached, nil
+	}
+	if c.offline {
+		return nil, fmt.Errorf("%w (offline mode): %s", ErrNotCached, ref)
+	}
+
+	data, err := fetch(ctx)
+	if err != nil {
+		// a pinned artifact which fails verification is never replaced by a cached copy
+		if cacheErr == nil && !immutable {
+			log.Warn().Err(err).Str("ref", ref).Msg("failed to fetch artifact, using cached copy")
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	blobDigest, err := c.PutBlob(data)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.SetRef(ref, blobDigest); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// verified wraps fetch to check the fetched content against digest
+func verified(digest string, fetch func(ctx context.Context) ([]byte, error)) func(ctx context.Context) ([]byte, error) {
+	return func(ctx context.Context) ([]byte, error) {
+		data, err := fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err = Verify(Digest(data), int64(len(data)), &digest, nil); err != nil {
+			return nil, fmt.Errorf("artifact %s failed verification: %v", digest, err)
+		}
+		return data, nil
+	}
+}
+
+// loadRef returns the cached content ref resolved to, it must match expectedDigest if set
+func (c *Cache) loadRef(ref string, expectedDigest string) ([]byte, error) {
+	digest, err := c.ResolveRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	if expectedDigest != "" && NormalizeDigest(digest) != NormalizeDigest(expectedDigest) {
+		return nil, fmt.Errorf("%w: %s", ErrNotCached, expectedDigest)
+	}
+	return c.GetBlob(digest)
+}
+
+// PutBlob stores data in the cache and returns its digest
+func (c *Cache) PutBlob(data []byte) (string, error) {
+	digest := Digest(data)
+	blobPath := c.BlobPath(digest)
+	if _, err := os.Stat(blobPath); err == nil {
+		return digest, nil
+	}
+	if err := c.writeFile(blobPath, data); err != nil {
+		return "", fmt.Errorf("failed to store artifact in cache: %w", err)
+	}
+	return digest, nil
+}
+
+// GetBlob returns the content stored in the cache for digest, the content is verified against the digest
+func (c *Cache) GetBlob(digest string) ([]byte, error) {
+	data, err := os.ReadFile(c.BlobPath(digest))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotCached, digest)
+		}
+		return nil, fmt.Errorf("failed to read artifact from cache: %w", err)
+	}
+	if Digest(data) != NormalizeDigest(digest) {
+		return nil, fmt.Errorf("cached artifact %s is corrupted", digest)
+	}
+	return data, nil
+}
+
+// BlobPath returns the location of the blob identified by digest in the cache
+func (c *Cache) BlobPath(digest string) string {
+	return path.Join(c.root, blobsDir, DigestAlgorithm, digestHex(digest))
+}
+
+// TreePath returns the location of the source tree identified by digest, and whether it is present in the cache
+func (c *Cache) TreePath(digest string) (string, bool) {
+	treePath := path.Join(c.root, sourcesDir, DigestAlgorithm, digestHex(digest))
+	info, err := os.Stat(treePath)
+	return treePath, err == nil && info.IsDir()
+}
+
+// TempDir creates a temporary folder inside the cache, trees fetched into it can be committed with CommitTree
+func (c *Cache) TempDir() (string, error) {
+	tmp := path.Join(c.root, tmpDir)
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return "", fmt.Errorf("failed to create cache folder: %w", err)
+	}
+	return os.MkdirTemp(tmp, "fetch_")
+}
+
+// CommitTree moves the tree in dir (created with TempDir) into the cache and returns its digest and size.
+// Trees are committed with a rename, concurrent builds committing the same tree are safe: the tree of the first one is kept.
+func (c *Cache) CommitTree(dir string) (string, int64, error) {
+	digest, size, err := TreeDigest(dir)
+	if err != nil {
+		return "", 0, err
+	}
+	treePath, _ := c.TreePath(digest)
+	if err := os.MkdirAll(filepath.Dir(treePath), 0755); err != nil {
+		return "", 0, fmt.Errorf("failed to create cache folder: %w", err)
+	}
+	if err := os.Rename(dir, treePath); err != nil {
+		// EEXIST or ENOTEMPTY, the tree is already cached, e.g. committed by another build of the same source
+		if errors.Is(err, fs.ErrExist) {
+			return digest, size, os.RemoveAll(dir)
+		}
+		return "", 0, fmt.Errorf("failed to store source tree in cache: %w", err)
+	}
+	return digest, size, nil
+}
+
+// SetRef records that ref resolved to the artifact with the given digest
+func (c *Cache) SetRef(ref string, digest string) error {
+	return c.writeFile(c.refPath(ref), []byte(NormalizeDigest(digest)))
+}
+
+// ResolveRef returns the digest of the artifact ref resolved to the last time it was fetched
+func (c *Cache) ResolveRef(ref string) (string, error) {
+	data, err := os.ReadFile(c.refPath(ref))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("%w: %s", ErrNotCached, ref)
+		}
+		return "", fmt.Errorf("failed to read cache reference: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (c *Cache) refPath(ref string) string {
+	return path.Join(c.root, refsDir, digestHex(Digest([]byte(ref))))
+}
+
+// writeFile writes data to a temporary file first and renames it, so readers never see partial content
+func (c *Cache) writeFile(filePath string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".tmp_")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filePath)
+}
+
+// Digest returns the digest of data in the form of sha256:<hex>
+func Digest(data []byte) string {
+	return fmt.Sprintf("%s:%x", DigestAlgorithm, sha256.Sum256(data))
+}
+
+// TreeDigest calculates a digest for the directory tree by hashing the relative path, the mode and the content
+// digest of every regular file in lexical order. It also returns the total size of the files.
+// Git metadata (.git folders) is not part of the digest, so clones of the same revision have the same digest.
+func TreeDigest(dir string) (string, int64, error) {
+	files := make([]string, 0)
+	err := filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, filePath)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to walk source tree: %w", err)
+	}
+	sort.Strings(files)
+
+	var size int64
+	hasher := sha256.New()
+	for _, filePath := range files {
+		rel, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return "", 0, err
+		}
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return "", 0, err
+		}
+		fileHasher := sha256.New()
+		f, err := os.Open(filePath)
+		if err != nil {
+			return "", 0, err
+		}
+		n, err := io.Copy(fileHasher, f)
+		f.Close()
+		if err != nil {
+			return "", 0, err
+		}
+		size += n
+		fmt.Fprintf(hasher, "%s\x00%o\x00%x\n", filepath.ToSlash(rel), info.Mode().Perm(), fileHasher.Sum(nil))
+	}
+	return fmt.Sprintf("%s:%x", DigestAlgorithm, hasher.Sum(nil)), size, nil
+}
+
+// Verify checks digest and size of an artifact against the expected values, expected values which are not set are ignored
+func Verify(digest string, size int64, expectedDigest *string, expectedSize *int32) error {
+	if expectedDigest != nil && *expectedDigest != "" && NormalizeDigest(*expectedDigest) != NormalizeDigest(digest) {
+		return fmt.Errorf("digest mismatch: expected %s, got %s", NormalizeDigest(*expectedDigest), NormalizeDigest(digest))
+	}
+	if expectedSize != nil && *expectedSize > 0 && int64(*expectedSize) != size {
+		return fmt.Errorf("size mismatch: expected %d, got %d", *expectedSize, size)
+	}
+	return nil
+}
+
+// NormalizeDigest adds the sha256: prefix to bare hex digests
+func NormalizeDigest(digest string) string {
+	digest = strings.ToLower(strings.TrimSpace(digest))
+	if !strings.Contains(digest, ":") {
+		return DigestAlgorithm + ":" + digest
+	}
+	return digest
+}
+
+func digestHex(digest string) string {
+	return strings.TrimPrefix(NormalizeDigest(digest), DigestAlgorithm+":")
+}
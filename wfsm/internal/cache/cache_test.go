@@ -0,0 +1,237 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestCache_Load(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	fetchErr := errors.New("network unreachable")
+
+	fetched := 0
+	fetchOk := func(content string) func(context.Context) ([]byte, error) {
+		return func(context.Context) ([]byte, error) {
+			fetched++
+			return []byte(content), nil
+		}
+	}
+	fetchFail := func(context.Context) ([]byte, error) {
+		fetched++
+		return nil, fetchErr
+	}
+
+	c := NewCache(root, false)
+
+	data, err := c.Load(ctx, "https://example.com/manifest.json", "", fetchOk("v1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", string(data))
+
+	// unpinned references are fetched again
+	data, err = c.Load(ctx, "https://example.com/manifest.json", "", fetchOk("v2"))
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", string(data))
+	assert.Equal(t, 2, fetched)
+
+	// cached copy is used when fetching fails
+	data, err = c.Load(ctx, "https://example.com/manifest.json", "", fetchFail)
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", string(data))
+
+	// pinned references are served from the cache without fetching
+	pinned := Digest([]byte("pinned"))
+	_, err = c.Load(ctx, pinned, pinned, fetchOk("pinned"))
+	assert.NoError(t, err)
+	fetched = 0
+	data, err = c.Load(ctx, pinned, pinned, fetchFail)
+	assert.NoError(t, err)
+	assert.Equal(t, "pinned", string(data))
+	assert.Equal(t, 0, fetched)
+
+	// fetched content of pinned references is verified, and never replaced by the copy the ref last resolved to
+	_, err = c.Load(ctx, "hub://hub.example.com/"+pinned, pinned, fetchOk("tampered"))
+	assert.ErrorContains(t, err, "digest mismatch")
+	assert.NoError(t, c.SetRef("hub://hub.example.com/"+pinned, Digest([]byte("v2"))))
+	_, err = c.Load(ctx, "hub://hub.example.com/"+pinned, pinned, fetchOk("tampered"))
+	assert.ErrorContains(t, err, "digest mismatch")
+
+	// offline mode never fetches
+	fetched = 0
+	offline := NewCache(root, true)
+	data, err = offline.Load(ctx, "https://example.com/manifest.json", "", fetchFail)
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", string(data))
+	_, err = offline.Load(ctx, "https://example.com/other.json", "", fetchOk("other"))
+	assert.ErrorIs(t, err, ErrNotCached)
+	assert.Equal(t, 0, fetched)
+
+	// nil cache always fetches
+	var nilCache *Cache
+	_, err = nilCache.Load(ctx, "https://example.com/manifest.json", "", fetchFail)
+	assert.ErrorIs(t, err, fetchErr)
+	_, err = nilCache.Load(ctx, pinned, pinned, fetchOk("tampered"))
+	assert.ErrorContains(t, err, "digest mismatch")
+}
+
+func TestCache_LoadImmutable(t *testing.T) {
+	ctx := context.Background()
+	fetchErr := errors.New("network unreachable")
+
+	fetched := 0
+	fetchOk := func(content string) func(context.Context) ([]byte, error) {
+		return func(context.Context) ([]byte, error) {
+			fetched++
+			return []byte(content), nil
+		}
+	}
+	fetchFail := func(context.Context) ([]byte, error) {
+		fetched++
+		return nil, fetchErr
+	}
+
+	c := NewCache(t.TempDir(), false)
+	// the digest assigned by the hub is not the digest of the pulled record, so the record is not verified
+	ref := "hub://hub.example.com/" + Digest([]byte("record"))
+	record := `{"name":"org.agntcy.mailcomposer","version":"0.0.1"}`
+	data, err := c.LoadImmutable(ctx, ref, fetchOk(record))
+	assert.NoError(t, err)
+	assert.Equal(t, record, string(data))
+
+	// immutable references are served from the cache without fetching
+	fetched = 0
+	data, err = c.LoadImmutable(ctx, ref, fetchFail)
+	assert.NoError(t, err)
+	assert.Equal(t, record, string(data))
+	assert.Equal(t, 0, fetched)
+
+	_, err = c.LoadImmutable(ctx, "hub://hub.example.com/other", fetchFail)
+	assert.ErrorIs(t, err, fetchErr)
+
+	offline := NewCache(t.TempDir(), true)
+	_, err = offline.LoadImmutable(ctx, ref, fetchOk(record))
+	assert.ErrorIs(t, err, ErrNotCached)
+}
+
+func TestCache_GetBlob_Corrupted(t *testing.T) {
+	c := NewCache(t.TempDir(), false)
+	digest, err := c.PutBlob([]byte("manifest"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.WriteFile(c.BlobPath(digest), []byte("tampered"), 0644))
+	_, err = c.GetBlob(digest)
+	assert.ErrorContains(t, err, "corrupted")
+}
+
+func TestCache_CommitTree(t *testing.T) {
+	c := NewCache(t.TempDir(), false)
+
+	tmp, err := c.TempDir()
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(filepath.Join(tmp, "pkg"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(tmp, "pkg", "agent.py"), []byte("print('hi')"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(tmp, "pyproject.toml"), []byte("[project]"), 0644))
+
+	expectedDigest, expectedSize, err := TreeDigest(tmp)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20), expectedSize)
+
+	digest, size, err := c.CommitTree(tmp)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedDigest, digest)
+	assert.Equal(t, expectedSize, size)
+
+	treePath, found := c.TreePath(digest)
+	assert.True(t, found)
+	content, err := os.ReadFile(filepath.Join(treePath, "pkg", "agent.py"))
+	assert.NoError(t, err)
+	assert.Equal(t, "print('hi')", string(content))
+
+	// committing a tree which is already cached keeps the cached one and removes the new one
+	tmp, err = c.TempDir()
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(filepath.Join(tmp, "pkg"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(tmp, "pkg", "agent.py"), []byte("print('hi')"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(tmp, "pyproject.toml"), []byte("[project]"), 0644))
+	digest, _, err = c.CommitTree(tmp)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedDigest, digest)
+	assert.NoDirExists(t, tmp)
+	assert.FileExists(t, filepath.Join(treePath, "pkg", "agent.py"))
+}
+
+func TestCache_CommitTree_Concurrent(t *testing.T) {
+	c := NewCache(t.TempDir(), false)
+
+	var group errgroup.Group
+	digests := make([]string, 8)
+	for i := range digests {
+		group.Go(func() error {
+			tmp, err := c.TempDir()
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(filepath.Join(tmp, "agent.py"), []byte("print('hi')"), 0644); err != nil {
+				return err
+			}
+			digests[i], _, err = c.CommitTree(tmp)
+			return err
+		})
+	}
+	assert.NoError(t, group.Wait())
+	for _, digest := range digests {
+		assert.Equal(t, digests[0], digest)
+	}
+	treePath, found := c.TreePath(digests[0])
+	assert.True(t, found)
+	assert.FileExists(t, filepath.Join(treePath, "agent.py"))
+}
+
+func TestTreeDigest_IgnoresGitMetadata(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "agent.py"), []byte("print('hi')"), 0644))
+	digest, size, err := TreeDigest(dir)
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, ".git", "refs"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".git", "index"), []byte("clone specific"), 0644))
+	gitDigest, gitSize, err := TreeDigest(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, digest, gitDigest)
+	assert.Equal(t, size, gitSize)
+}
+
+func TestVerify(t *testing.T) {
+	digest := Digest([]byte("archive"))
+	bareDigest := digest[len(DigestAlgorithm)+1:]
+	otherDigest := Digest([]byte("other"))
+	size := int32(7)
+	wrongSize := int32(8)
+
+	tests := []struct {
+		name           string
+		expectedDigest *string
+		expectedSize   *int32
+		wantErr        bool
+	}{
+		{name: "nothing to verify"},
+		{name: "digest matches", expectedDigest: &digest, expectedSize: &size},
+		{name: "digest without algorithm matches", expectedDigest: &bareDigest},
+		{name: "digest mismatch", expectedDigest: &otherDigest, wantErr: true},
+		{name: "size mismatch", expectedDigest: &digest, expectedSize: &wrongSize, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(digest, 7, tt.expectedDigest, tt.expectedSize)
+			assert.Equal(t, tt.wantErr, err != nil, "Verify() error = %v", err)
+		})
+	}
+}
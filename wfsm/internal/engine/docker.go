@@ -0,0 +1,26 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cisco-eti/wfsm/internal/util"
+	dockerclient "github.com/docker/docker/client"
+)
+
+// dockerEngine talks to the docker daemon configured by the docker CLI (DOCKER_HOST, docker context)
+type dockerEngine struct{}
+
+func (dockerEngine) Name() string {
+	return Docker
+}
+
+func (dockerEngine) NewClient(ctx context.Context) (dockerclient.APIClient, error) {
+	dockerCli, err := util.GetDockerCLI(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize docker client: %v", err)
+	}
+	return dockerCli.Client(), nil
+}
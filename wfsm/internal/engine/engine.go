@@ -0,0 +1,47 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package engine
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	dockerclient "github.com/docker/docker/client"
+)
+
+const (
+	Docker = "docker"
+	Podman = "podman"
+)
+
+// Engines are the supported container engines
+var Engines = []string{Docker, Podman}
+
+// Engine is the container engine agent images are built with and agents are run on by the docker platform
+type Engine interface {
+	// Name of the engine, which is the name of its CLI as well
+	Name() string
+	// NewClient returns a client of the Docker compatible API of the engine
+	NewClient(ctx context.Context) (dockerclient.APIClient, error)
+}
+
+// Get returns the engine of the name, docker if the name is empty
+func Get(name string) (Engine, error) {
+	switch name {
+	case Docker, "":
+		return dockerEngine{}, nil
+	case Podman:
+		return podmanEngine{}, nil
+	}
+	return nil, fmt.Errorf("unsupported container engine %s, supported engines: %s", name, strings.Join(Engines, ", "))
+}
+
+// Validate checks whether the name is a supported engine
+func Validate(name string) error {
+	if name != "" && !slices.Contains(Engines, name) {
+		return fmt.Errorf("unsupported container engine %s, supported engines: %s", name, strings.Join(Engines, ", "))
+	}
+	return nil
+}
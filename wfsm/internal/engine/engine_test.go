@@ -0,0 +1,68 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		name         string
+		engineName   string
+		expectedName string
+		expectedErr  string
+	}{
+		{name: "default", engineName: "", expectedName: Docker},
+		{name: "docker", engineName: "docker", expectedName: Docker},
+		{name: "podman", engineName: "podman", expectedName: Podman},
+		{name: "unsupported", engineName: "containerd", expectedErr: "unsupported container engine containerd, supported engines: docker, podman"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Get(tt.engineName)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedName, e.Name())
+		})
+	}
+}
+
+func TestPodmanHost(t *testing.T) {
+	runtimeDir := t.TempDir()
+	socket := filepath.Join(runtimeDir, "podman", "podman.sock")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(socket), 0700))
+	assert.NoError(t, os.WriteFile(socket, nil, 0600))
+
+	tests := []struct {
+		name          string
+		containerHost string
+		expectedHost  string
+	}{
+		{name: "rootless socket", expectedHost: "unix://" + socket},
+		{name: "CONTAINER_HOST", containerHost: "unix:///tmp/podman.sock", expectedHost: "unix:///tmp/podman.sock"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+			t.Setenv(PodmanHostEnv, tt.containerHost)
+
+			host, err := podmanHost(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedHost, host)
+
+			client, err := podmanEngine{}.NewClient(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedHost, client.DaemonHost())
+			client.Close()
+		})
+	}
+}
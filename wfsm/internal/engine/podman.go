@@ -0,0 +1,64 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	dockerclient "github.com/docker/docker/client"
+)
+
+// PodmanHostEnv is the env var of the podman CLI pointing to the API socket, e.g. unix:///run/user/1000/podman/podman.sock
+const PodmanHostEnv = "CONTAINER_HOST"
+
+// podmanEngine talks to the Docker compatible API podman serves on its API socket,
+// e.g. started by 'systemctl --user start podman.socket' or by 'podman machine start'
+type podmanEngine struct{}
+
+func (podmanEngine) Name() string {
+	return Podman
+}
+
+func (podmanEngine) NewClient(ctx context.Context) (dockerclient.APIClient, error) {
+	host, err := podmanHost(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := dockerclient.NewClientWithOpts(dockerclient.WithHost(host), dockerclient.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize podman client for %s: %v", host, err)
+	}
+	return client, nil
+}
+
+// podmanHost returns the address of the podman API socket: CONTAINER_HOST if set, the first socket found on the host,
+// or the socket podman machine forwards from its VM (macOS, Windows)
+func podmanHost(ctx context.Context) (string, error) {
+	if host := os.Getenv(PodmanHostEnv); host != "" {
+		return host, nil
+	}
+	for _, socket := range podmanSockets() {
+		if _, err := os.Stat(socket); err == nil {
+			return "unix://" + socket, nil
+		}
+	}
+	out, err := exec.CommandContext(ctx, "podman", "machine", "inspect", "--format", "{{.ConnectionInfo.PodmanSocket.Path}}").Output()
+	if socket := strings.TrimSpace(string(out)); err == nil && socket != "" {
+		return "unix://" + socket, nil
+	}
+	return "", fmt.Errorf("podman API socket not found, start it with 'systemctl --user start podman.socket' or 'podman machine start', or set %s", PodmanHostEnv)
+}
+
+// podmanSockets are the paths of the rootless and rootful podman API sockets
+func podmanSockets() []string {
+	sockets := make([]string, 0, 3)
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		sockets = append(sockets, filepath.Join(runtimeDir, "podman", "podman.sock"))
+	}
+	return append(sockets, fmt.Sprintf("/run/user/%d/podman/podman.sock", os.Getuid()), "/run/podman/podman.sock")
+}
@@ -0,0 +1,43 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package internal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// envTemplatePattern matches template expressions in env var values, e.g. {{ deps.mailcomposer.endpoint }}
+var envTemplatePattern = regexp.MustCompile(`\{\{\s*([^{}\s]*)\s*\}\}`)
+
+// HasEnvTemplate returns true if the value contains template expressions
+func HasEnvTemplate(value string) bool {
+	return envTemplatePattern.MatchString(value)
+}
+
+// EnvTemplateExpressions returns the expressions of the templates in the value, e.g. deps.mailcomposer.endpoint
+func EnvTemplateExpressions(value string) []string {
+	var expressions []string
+	for _, match := range envTemplatePattern.FindAllStringSubmatch(value, -1) {
+		expressions = append(expressions, match[1])
+	}
+	return expressions
+}
+
+// InterpolateEnvTemplate replaces the template expressions in the value with the values returned by lookup,
+// the first lookup error is returned
+func InterpolateEnvTemplate(value string, lookup func(expression string) (string, error)) (string, error) {
+	var sb strings.Builder
+	last := 0
+	for _, match := range envTemplatePattern.FindAllStringSubmatchIndex(value, -1) {
+		resolved, err := lookup(value[match[2]:match[3]])
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(value[last:match[0]])
+		sb.WriteString(resolved)
+		last = match[1]
+	}
+	sb.WriteString(value[last:])
+	return sb.String(), nil
+}
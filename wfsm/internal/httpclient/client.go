@@ -0,0 +1,207 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Client fetches documents over http(s) using the per host settings of the credentials file.
+// Failed requests (timeouts, transient network errors, 429 and 5xx responses) are retried with exponential backoff.
+type Client struct {
+	credentials CredentialsFile
+	retryDelay  time.Duration
+}
+
+// StatusError is returned when the server responds with a non 2xx status code
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response status: %s", e.Status)
+}
+
+// NewClient creates a client configured from the credentials file
+func NewClient() (*Client, error) {
+	credentialsFilePath, err := GetCredentialsFilePath()
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := LoadCredentialsFile(credentialsFilePath)
+	if err != nil {
+		return nil, err
+	}
+	return NewClientWithCredentials(credentials), nil
+}
+
+func NewClientWithCredentials(credentials CredentialsFile) *Client {
+	return &Client{
+		credentials: credentials,
+		retryDelay:  DefaultRetryDelay,
+	}
+}
+
+// Get fetches the document at rawURL and returns its content
+func (c *Client) Get(ctx context.Context, rawURL string) ([]byte, error) {
+	log := zerolog.Ctx(ctx)
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	hostConfig := c.credentials.HostConfig(u.Host)
+	httpClient, err := newHTTPClient(hostConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	retries := c.credentials.RetryCount()
+	for attempt := 0; ; attempt++ {
+		data, retryAfter, err := c.get(ctx, httpClient, hostConfig, rawURL)
+		if err == nil {
+			return data, nil
+		}
+		if attempt >= retries || !isRetryable(err) || ctx.Err() != nil {
+			return nil, err
+		}
+
+		delay := backoff(c.retryDelay, attempt)
+		// a server asking to retry much later (e.g. in hours) does not stall the build, the wait is capped at the request timeout
+		if retryAfter > delay {
+			delay = min(retryAfter, max(hostConfig.Timeout, delay))
+		}
+		log.Debug().Err(err).Str("url", rawURL).Msgf("request failed, retrying in %s", delay)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (c *Client) get(ctx context.Context, httpClient *http.Client, hostConfig HostConfig, rawURL string) ([]byte, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, hostConfig.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, value := range hostConfig.Headers {
+		req.Header.Set(name, value)
+	}
+	if hostConfig.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+hostConfig.Token)
+	} else if hostConfig.Username != "" {
+		req.SetBasicAuth(hostConfig.Username, hostConfig.Password)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, parseRetryAfter(resp.Header.Get("Retry-After")), &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return data, 0, nil
+}
+
+func newHTTPClient(hostConfig HostConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if hostConfig.Proxy != "" {
+		proxyURL, err := url.Parse(hostConfig.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if hostConfig.CAFile != "" {
+		pem, err := os.ReadFile(hostConfig.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil || rootCAs == nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle: %s", hostConfig.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:    rootCAs,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// isRetryable returns true for errors which may go away by retrying: 429 and 5xx responses, timeouts and
+// transient network errors. Other errors (DNS names which do not exist, invalid urls, certificate problems,
+// cancelled requests, ...) are returned right away.
+func isRetryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	// the attempt timed out, the caller's context is checked separately
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func backoff(base time.Duration, attempt int) time.Duration {
+	delay := base * time.Duration(1<<attempt)
+	// add up to 20% jitter so that parallel clients do not retry in lockstep
+	return delay + time.Duration(rand.Int64N(int64(delay)/5+1))
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
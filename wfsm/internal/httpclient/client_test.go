@@ -0,0 +1,226 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Get_Auth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Tenant") != "team-a" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if user, pass, ok := r.BasicAuth(); ok && user == "user" && pass == "secret" {
+			w.Write([]byte("basic"))
+			return
+		}
+		if r.Header.Get("Authorization") == "Bearer token" {
+			w.Write([]byte("bearer"))
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	host := hostOf(t, server.URL)
+
+	tests := []struct {
+		name        string
+		credentials CredentialsFile
+		env         map[string]string
+		want        string
+		wantStatus  int
+	}{
+		{
+			name: "bearer token from credentials file",
+			credentials: CredentialsFile{Hosts: map[string]HostConfig{
+				host: {Token: "token", Headers: map[string]string{"X-Tenant": "team-a"}},
+			}},
+			want: "bearer",
+		},
+		{
+			name: "basic auth from env",
+			credentials: CredentialsFile{Hosts: map[string]HostConfig{
+				"127.0.0.1": {Headers: map[string]string{"X-Tenant": "team-a"}},
+			}},
+			env: map[string]string{
+				"WFSM_HTTP_USERNAME_" + envNameOf(host): "user",
+				"WFSM_HTTP_PASSWORD_" + envNameOf(host): "secret",
+			},
+			want: "basic",
+		},
+		{
+			name: "credentials of other hosts are not sent",
+			credentials: CredentialsFile{Hosts: map[string]HostConfig{
+				"example.com": {Token: "token", Headers: map[string]string{"X-Tenant": "team-a"}},
+			}},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			client := NewClientWithCredentials(tt.credentials)
+			data, err := client.Get(context.Background(), server.URL)
+			if tt.wantStatus != 0 {
+				assert.ErrorContains(t, err, http.StatusText(tt.wantStatus))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+		})
+	}
+}
+
+func TestClient_Get_Retries(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := NewClientWithCredentials(CredentialsFile{})
+	client.retryDelay = time.Millisecond
+	data, err := client.Get(context.Background(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", string(data))
+	assert.Equal(t, 3, calls)
+
+	// client errors are not retried
+	calls = 0
+	notFound := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer notFound.Close()
+	_, err = client.Get(context.Background(), notFound.URL)
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestClient_Get_RetryAfter(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 2 {
+			w.Header().Set("Retry-After", "86400")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	// the day long Retry-After is capped at the request timeout
+	client := NewClientWithCredentials(CredentialsFile{Timeout: 50 * time.Millisecond})
+	client.retryDelay = time.Millisecond
+	start := time.Now()
+	data, err := client.Get(context.Background(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", string(data))
+	assert.Equal(t, 2, calls)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "service unavailable", err: &StatusError{StatusCode: http.StatusServiceUnavailable}, want: true},
+		{name: "too many requests", err: &StatusError{StatusCode: http.StatusTooManyRequests}, want: true},
+		{name: "not found", err: &StatusError{StatusCode: http.StatusNotFound}},
+		{name: "attempt timeout", err: &url.Error{Op: "Get", URL: "https://example.com", Err: context.DeadlineExceeded}, want: true},
+		{name: "connection reset", err: &url.Error{Op: "Get", URL: "https://example.com", Err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}}, want: true},
+		{name: "temporary dns failure", err: &url.Error{Op: "Get", URL: "https://example.com", Err: &net.DNSError{Name: "example.com", IsTemporary: true}}, want: true},
+		{name: "unknown host", err: &url.Error{Op: "Get", URL: "https://example.com", Err: &net.DNSError{Name: "example.com", IsNotFound: true}}},
+		{name: "cancelled", err: &url.Error{Op: "Get", URL: "https://example.com", Err: context.Canceled}},
+		{name: "certificate", err: &url.Error{Op: "Get", URL: "https://example.com", Err: &tls.CertificateVerificationError{}}},
+		{name: "malformed url", err: fmt.Errorf("failed to create request: %w", &url.Error{Op: "parse", URL: "https://exa mple.com", Err: url.InvalidHostError(" ")})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isRetryable(tt.err))
+		})
+	}
+}
+
+func TestClient_Get_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	retries := 0
+	client := NewClientWithCredentials(CredentialsFile{Timeout: 50 * time.Millisecond, Retries: &retries})
+	_, err := client.Get(context.Background(), server.URL)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClient_Get_CustomCA(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tls"))
+	}))
+	defer server.Close()
+
+	retries := 0
+	_, err := NewClientWithCredentials(CredentialsFile{Retries: &retries}).Get(context.Background(), server.URL)
+	assert.Error(t, err, "server certificate should not be trusted without the CA bundle")
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.NoError(t, os.WriteFile(caFile, caPem, 0600))
+
+	credentialsFile := filepath.Join(filepath.Dir(caFile), "credentials.yaml")
+	assert.NoError(t, os.WriteFile(credentialsFile, []byte("caFile: ca.pem\n"), 0600))
+	credentials, err := LoadCredentialsFile(credentialsFile)
+	assert.NoError(t, err)
+
+	data, err := NewClientWithCredentials(credentials).Get(context.Background(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "tls", string(data))
+}
+
+func TestCredentialsFile_HostConfig_Wildcard(t *testing.T) {
+	credentials := CredentialsFile{Hosts: map[string]HostConfig{
+		"*.example.com":          {Token: "generic"},
+		"*.internal.example.com": {Token: "internal"},
+		"repo.example.com:8443":  {Token: "exact"},
+	}}
+	assert.Equal(t, "generic", credentials.HostConfig("repo.example.com").Token)
+	assert.Equal(t, "internal", credentials.HostConfig("repo.internal.example.com").Token)
+	assert.Equal(t, "exact", credentials.HostConfig("repo.example.com:8443").Token)
+	assert.Equal(t, "", credentials.HostConfig("example.org").Token)
+	assert.Equal(t, "", credentials.HostConfig("example.com").Token)
+	assert.Equal(t, "internal", credentials.HostConfig("repo.internal.example.com:8443").Token)
+	assert.Equal(t, DefaultTimeout, credentials.HostConfig("example.org").Timeout)
+}
+
+func hostOf(t *testing.T, rawURL string) string {
+	u, err := url.Parse(rawURL)
+	assert.NoError(t, err)
+	return u.Host
+}
@@ -0,0 +1,179 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package httpclient
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/cisco-eti/wfsm/internal/util"
+)
+
+const (
+	DefaultTimeout    = 30 * time.Second
+	DefaultRetries    = 3
+	DefaultRetryDelay = 500 * time.Millisecond
+
+	credentialsFileEnv = "WFSM_CREDENTIALS_FILE"
+	tokenEnvPrefix     = "WFSM_HTTP_TOKEN_"
+	usernameEnvPrefix  = "WFSM_HTTP_USERNAME_"
+	passwordEnvPrefix  = "WFSM_HTTP_PASSWORD_"
+)
+
+// CredentialsFile is the format of the credentials file (~/.wfsm/credentials.yaml by default)
+//
+// Example:
+//
+//	timeout: 30s
+//	retries: 3
+//	hosts:
+//	  artifacts.example.com:
+//	    token: <bearer token>
+//	    caFile: /etc/ssl/private-ca.pem
+//	    headers:
+//	      X-Tenant: my-team
+//	  "*.internal.example.com":
+//	    username: user
+//	    password: secret
+type CredentialsFile struct {
+	Timeout time.Duration         `yaml:"timeout,omitempty"`
+	Retries *int                  `yaml:"retries,omitempty"`
+	CAFile  string                `yaml:"caFile,omitempty"`
+	Hosts   map[string]HostConfig `yaml:"hosts,omitempty"`
+}
+
+// HostConfig holds the settings used for requests sent to a host
+type HostConfig struct {
+	Token    string            `yaml:"token,omitempty"`
+	Username string            `yaml:"username,omitempty"`
+	Password string            `yaml:"password,omitempty"`
+	Headers  map[string]string `yaml:"headers,omitempty"`
+	CAFile   string            `yaml:"caFile,omitempty"`
+	Proxy    string            `yaml:"proxy,omitempty"`
+	Timeout  time.Duration     `yaml:"timeout,omitempty"`
+}
+
+// GetCredentialsFilePath returns the location of the credentials file, it can be overridden with WFSM_CREDENTIALS_FILE env var
+func GetCredentialsFilePath() (string, error) {
+	credentialsFile := os.Getenv(credentialsFileEnv)
+	if credentialsFile != "" {
+		return credentialsFile, nil
+	}
+	homeDir, err := util.GetHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return path.Join(homeDir, ".wfsm", "credentials.yaml"), nil
+}
+
+// LoadCredentialsFile parses the credentials file, a missing file results in an empty config
+func LoadCredentialsFile(filePath string) (CredentialsFile, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return CredentialsFile{}, nil
+		}
+		return CredentialsFile{}, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	var credentials CredentialsFile
+	if err := yaml.Unmarshal(data, &credentials); err != nil {
+		return CredentialsFile{}, fmt.Errorf("failed to unmarshal credentials file: %w", err)
+	}
+
+	// relative CA files are resolved relative to the credentials file
+	credentials.CAFile = resolvePath(filePath, credentials.CAFile)
+	for host, hostConfig := range credentials.Hosts {
+		hostConfig.CAFile = resolvePath(filePath, hostConfig.CAFile)
+		credentials.Hosts[host] = hostConfig
+	}
+	return credentials, nil
+}
+
+// HostConfig returns the settings for host. Entries of the credentials file are matched by exact host (with or without port)
+// or by wildcard (*.example.com), credentials set in WFSM_HTTP_TOKEN_<HOST>, WFSM_HTTP_USERNAME_<HOST> and
+// WFSM_HTTP_PASSWORD_<HOST> env vars take precedence.
+func (c CredentialsFile) HostConfig(hostPort string) HostConfig {
+	hostConfig := c.matchHost(hostPort)
+
+	envSuffix := envNameOf(hostPort)
+	if token := os.Getenv(tokenEnvPrefix + envSuffix); token != "" {
+		hostConfig.Token = token
+	}
+	if username := os.Getenv(usernameEnvPrefix + envSuffix); username != "" {
+		hostConfig.Username = username
+		hostConfig.Password = os.Getenv(passwordEnvPrefix + envSuffix)
+	}
+	if hostConfig.CAFile == "" {
+		hostConfig.CAFile = c.CAFile
+	}
+	if hostConfig.Timeout == 0 {
+		hostConfig.Timeout = c.Timeout
+	}
+	if hostConfig.Timeout == 0 {
+		hostConfig.Timeout = DefaultTimeout
+	}
+	return hostConfig
+}
+
+func (c CredentialsFile) matchHost(hostPort string) HostConfig {
+	if hostConfig, ok := c.Hosts[hostPort]; ok {
+		return hostConfig
+	}
+	host := hostPort
+	if h, _, err := net.SplitHostPort(hostPort); err == nil {
+		host = h
+	}
+	if hostConfig, ok := c.Hosts[host]; ok {
+		return hostConfig
+	}
+	// the most specific wildcard wins
+	matched := ""
+	for pattern := range c.Hosts {
+		if !strings.HasPrefix(pattern, "*.") {
+			continue
+		}
+		if matchWildcard(pattern, host) || matchWildcard(pattern, hostPort) {
+			if len(pattern) > len(matched) {
+				matched = pattern
+			}
+		}
+	}
+	if matched != "" {
+		return c.Hosts[matched]
+	}
+	return HostConfig{}
+}
+
+// matchWildcard returns true if host is a single label followed by the domain of the pattern, e.g. *.example.com
+// matches repo.example.com but neither example.com nor a.repo.example.com
+func matchWildcard(pattern string, host string) bool {
+	label, found := strings.CutSuffix(host, strings.TrimPrefix(pattern, "*"))
+	return found && label != "" && !strings.Contains(label, ".")
+}
+
+// RetryCount returns the number of retries after a failed request
+func (c CredentialsFile) RetryCount() int {
+	if c.Retries == nil {
+		return DefaultRetries
+	}
+	return *c.Retries
+}
+
+// envNameOf converts host[:port] to the suffix of the credential env vars, e.g. repo.example.com:8443 -> REPO_EXAMPLE_COM_8443
+func envNameOf(hostPort string) string {
+	return strings.TrimSuffix(util.CalculateEnvVarPrefix(hostPort), "_")
+}
+
+func resolvePath(basePath string, filePath string) string {
+	if filePath == "" || filepath.IsAbs(filePath) {
+		return filePath
+	}
+	return filepath.Join(filepath.Dir(basePath), filePath)
+}
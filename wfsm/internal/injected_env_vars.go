@@ -0,0 +1,53 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package internal
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/cisco-eti/wfsm/internal/util"
+)
+
+// APIHost is the address the workflow server of the agents listens on
+const APIHost = "0.0.0.0"
+
+// InjectedEnvVars returns the env vars set by the runner for the agent: its API host, port, key and ID,
+// and the API key, ID and endpoint of each of its dependencies, unless disabled for the dependency in the config of the agent.
+// endpoint returns the url the agents reach a dependency at on the platform of the runner.
+func InjectedEnvVars(agentName string, agentDeploymentSpecs map[string]AgentDeploymentBuildSpec, dependencies map[string][]string,
+	endpoint func(AgentDeploymentBuildSpec) string) map[string]string {
+	agSpec := agentDeploymentSpecs[agentName]
+	envVars := map[string]string{
+		"API_HOST": APIHost,
+		"API_PORT": strconv.Itoa(DEFAULT_API_PORT),
+		"API_KEY":  agSpec.ApiKey,
+		"AGENT_ID": agSpec.AgentID,
+	}
+	for _, depName := range dependencies[agentName] {
+		if !agSpec.InjectsDependencyEnvVars(depName) {
+			continue
+		}
+		apiKeyName, idName, endpointName := dependencyEnvVarNames(depName)
+		depSpec := agentDeploymentSpecs[depName]
+		envVars[apiKeyName] = fmt.Sprintf("{\"x-api-key\": \"%s\"}", depSpec.ApiKey)
+		envVars[idName] = depSpec.AgentID
+		envVars[endpointName] = endpoint(depSpec)
+	}
+	return envVars
+}
+
+// InjectedDependencyEnvVarNames returns the names of the env vars the runners set in the agent for the dependency
+// (<DEP>_API_KEY, <DEP>_ID and <DEP>_ENDPOINT), none if they are disabled in the config of the agent
+func (s AgentSpec) InjectedDependencyEnvVarNames(dependencyName string) []string {
+	if !s.InjectsDependencyEnvVars(dependencyName) {
+		return nil
+	}
+	apiKeyName, idName, endpointName := dependencyEnvVarNames(dependencyName)
+	return []string{apiKeyName, idName, endpointName}
+}
+
+func dependencyEnvVarNames(dependencyName string) (string, string, string) {
+	prefix := util.CalculateEnvVarPrefix(dependencyName)
+	return prefix + "API_KEY", prefix + "ID", prefix + "ENDPOINT"
+}
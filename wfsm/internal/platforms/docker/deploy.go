@@ -7,11 +7,12 @@ import (
 	"fmt"
 	"os"
 	"path"
-	"strconv"
+	"sort"
 	"strings"
 
 	"github.com/cisco-eti/wfsm/internal"
 	containerClient "github.com/cisco-eti/wfsm/internal/container_client"
+	"github.com/cisco-eti/wfsm/internal/engine"
 	"github.com/cisco-eti/wfsm/internal/util"
 	"github.com/compose-spec/compose-go/v2/types"
 	"github.com/docker/compose/v2/cmd/formatter"
@@ -23,7 +24,16 @@ import (
 	"github.com/rs/zerolog"
 )
 
-const APIHost = "0.0.0.0"
+const (
+	// SecretsModeFile stores secret env vars in files of the host storage folder, which are mounted as compose secrets
+	// into /run/secrets and exported as env vars by the entrypoint of the agent image
+	SecretsModeFile = "file"
+	// SecretsModeEnv injects secret env vars into the compose environment like other env vars
+	SecretsModeEnv = "env"
+)
+
+// secretFileMode allows only the owner to read the secret files
+const secretFileMode = 0600
 
 // Deploy if externalPort is 0, will try to find the port of already running container or find next available port
 func (r *runner) Deploy(ctx context.Context,
@@ -35,23 +45,12 @@ func (r *runner) Deploy(ctx context.Context,
 	log := zerolog.Ctx(ctx)
 
 	// insert api keys, agent IDs and service names as host into the deployment specs
-	for agName, deps := range dependencies {
-		agSpec := agentDeploymentSpecs[agName]
-		for _, depName := range deps {
-			depAgPrefix := util.CalculateEnvVarPrefix(depName)
-			depSpec := agentDeploymentSpecs[depName]
-			agSpec.EnvVars[depAgPrefix+"API_KEY"] = fmt.Sprintf("{\"x-api-key\": \"%s\"}", depSpec.ApiKey)
-			agSpec.EnvVars[depAgPrefix+"ID"] = depSpec.AgentID
-			agSpec.EnvVars[depAgPrefix+"ENDPOINT"] = fmt.Sprintf("http://%s:%d", depSpec.ServiceName, internal.DEFAULT_API_PORT)
+	for agName, agSpec := range agentDeploymentSpecs {
+		for name, value := range internal.InjectedEnvVars(agName, agentDeploymentSpecs, dependencies, Endpoint) {
+			agSpec.EnvVars[name] = value
 		}
 	}
 
-	dockerCli, err := util.GetDockerCLI(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize docker client: %v", err)
-	}
-	defer dockerCli.Client().Close()
-
 	project := &types.Project{
 		Name: mainAgentName,
 	}
@@ -60,16 +59,16 @@ func (r *runner) Deploy(ctx context.Context,
 	// only the main agent will be exposed to the outside world
 	mainAgentSpec := agentDeploymentSpecs[mainAgentName]
 
-	port := mainAgentSpec.Port
-	//if port == 0 {
-	//	port, err = r.getMainAgentPublicPort(ctx, dockerCli.Client(), mainAgentName, mainAgentSpec)
-	//	if err != nil {
-	//		return nil, err
-	//	}
-	//}
-
-	mainAgentID := mainAgentSpec.AgentID
-	mainAgentAPiKey := mainAgentSpec.ApiKey
+	secretsMode, err := getSecretsMode()
+	if err != nil {
+		return nil, err
+	}
+	if secretsMode == SecretsModeFile {
+		// secret files of previous deployments are replaced
+		if err := os.RemoveAll(r.secretsFolder(mainAgentName)); err != nil {
+			return nil, fmt.Errorf("failed to remove secrets folder: %v", err)
+		}
+	}
 
 	// generate service configs for dependencies
 	for _, deploymentSpec := range agentDeploymentSpecs {
@@ -77,10 +76,16 @@ func (r *runner) Deploy(ctx context.Context,
 		if err != nil {
 			return nil, fmt.Errorf("failed to create service config: %v", err)
 		}
+		// images not built by wfsm can't read /run/secrets, they get the secret env vars in the compose environment
+		if secretsMode == SecretsModeFile && deploymentSpec.SecretFiles {
+			if err := r.createServiceSecrets(project, mainAgentName, deploymentSpec, sc); err != nil {
+				return nil, fmt.Errorf("failed to create secrets: %v", err)
+			}
+		}
 		project.Services[deploymentSpec.ServiceName] = *sc
 	}
 
-	composeFilePath := path.Join(r.hostStorageFolder, fmt.Sprintf("compose-%s.yaml", mainAgentName))
+	composeFilePath := r.composeFilePath(mainAgentName)
 	prjOpts := cmdcmp.ProjectOptions{
 		ConfigPaths: []string{
 			composeFilePath,
@@ -92,10 +97,39 @@ func (r *runner) Deploy(ctx context.Context,
 		return nil, fmt.Errorf("failed to marshal compose config: %v", err)
 	}
 
+	// the compose file keeps the secrets, only the returned artifact is redacted
+	artifact := projectYaml
+	if !r.revealSecrets {
+		artifact, err = redactProject(project, agentDeploymentSpecs).MarshalYAML()
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal compose config: %v", err)
+		}
+	}
+
 	err = os.WriteFile(composeFilePath, projectYaml, util.OwnerCanReadWrite)
 	if err != nil {
 		return nil, fmt.Errorf("failed to write compose config: %v", err)
 	}
+
+	if r.engine == engine.Podman {
+		log.Info().Msgf("Compose file generated at: %s", composeFilePath)
+		log.Info().Msgf("You can deploy the agent running `wfsm deploy` with `--dryRun=false` option or `podman-compose -f %v up`", composeFilePath)
+		if dryRun {
+			return artifact, nil
+		}
+		if err := r.podmanCompose(ctx, mainAgentName, "up", "--detach", "--remove-orphans").Run(); err != nil {
+			return nil, fmt.Errorf("failed to deploy with podman-compose: %v", err)
+		}
+		r.logDeploymentSummary(ctx, mainAgentName, mainAgentSpec)
+		return nil, r.podmanComposeLogs(ctx, mainAgentName, nil)
+	}
+
+	dockerCli, err := util.GetDockerCLI(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize docker client: %v", err)
+	}
+	defer dockerCli.Client().Close()
+
 	project, _, err = prjOpts.ToProject(ctx, dockerCli, []string{
 		//deploymentSpec.ServiceName
 	})
@@ -105,22 +139,16 @@ func (r *runner) Deploy(ctx context.Context,
 	log.Info().Msgf("Compose file generated at: %s", composeFilePath)
 	log.Info().Msgf("You can deploy the agent running `wfsm deploy` with `--dryRun=false` option or `docker compose -f %v up`", composeFilePath)
 	if dryRun {
-		return projectYaml, nil
+		return artifact, nil
 	}
 
 	backend := compose.NewComposeService(dockerCli) //.(commands.Backend)
-	err = backend.Up(ctx, project, api.UpOptions{api.CreateOptions{RemoveOrphans: true}, api.StartOptions{}})
+	err = backend.Up(ctx, project, api.UpOptions{Create: api.CreateOptions{RemoveOrphans: true}, Start: api.StartOptions{}})
 	if err != nil {
 		return nil, err
 	}
 
-	log.Info().Msg("---------------------------------------------------------------------")
-	log.Info().Msgf("ACP agent deployment name: %s", mainAgentName)
-	log.Info().Msgf("ACP agent running in container: %s, listening for ACP requests on: http://127.0.0.1:%d", mainAgentName, port)
-	log.Info().Msgf("Agent ID: %s", mainAgentID)
-	log.Info().Msgf("API Key: %s", mainAgentAPiKey)
-	log.Info().Msgf("API Docs: http://127.0.0.1:%d/agents/%s/docs", port, mainAgentID)
-	log.Info().Msg("---------------------------------------------------------------------\n\n\n")
+	r.logDeploymentSummary(ctx, mainAgentName, mainAgentSpec)
 
 	logConsumer := formatter.NewLogConsumer(ctx, os.Stdout, os.Stderr, true, true, true)
 	err = backend.Logs(ctx, project.Name, logConsumer, api.LogOptions{
@@ -136,6 +164,26 @@ func (r *runner) Deploy(ctx context.Context,
 	return nil, nil
 }
 
+// logDeploymentSummary logs the endpoint, ID and API key of the main agent of the deployment
+func (r *runner) logDeploymentSummary(ctx context.Context, mainAgentName string, mainAgentSpec internal.AgentDeploymentBuildSpec) {
+	log := zerolog.Ctx(ctx)
+	port := mainAgentSpec.Port
+	mainAgentID := mainAgentSpec.AgentID
+
+	log.Info().Msg("---------------------------------------------------------------------")
+	log.Info().Msgf("ACP agent deployment name: %s", mainAgentName)
+	log.Info().Msgf("ACP agent running in container: %s, listening for ACP requests on: http://127.0.0.1:%d", mainAgentName, port)
+	log.Info().Msgf("Agent ID: %s", mainAgentID)
+	log.Info().Msgf("API Key: %s", internal.Redact(mainAgentSpec.ApiKey, r.revealSecrets))
+	log.Info().Msgf("API Docs: http://127.0.0.1:%d/agents/%s/docs", port, mainAgentID)
+	log.Info().Msg("---------------------------------------------------------------------\n\n\n")
+}
+
+// Endpoint returns the url other agents of the deployment reach the agent at
+func Endpoint(agentDeploymentSpec internal.AgentDeploymentBuildSpec) string {
+	return fmt.Sprintf("http://%s:%d", agentDeploymentSpec.ServiceName, internal.DEFAULT_API_PORT)
+}
+
 func (r *runner) getMainAgentPublicPort(ctx context.Context, cntClient dockerClient.ContainerAPIClient, mainAgentName string, mainAgentSpec internal.AgentDeploymentBuildSpec) (int, error) {
 	log := zerolog.Ctx(ctx)
 
@@ -160,12 +208,6 @@ func (r *runner) createServiceConfig(projectName string, deploymentSpec internal
 
 	envVars := deploymentSpec.EnvVars
 
-	envVars["API_HOST"] = APIHost
-	envVars["API_PORT"] = strconv.Itoa(internal.DEFAULT_API_PORT)
-
-	envVars["API_KEY"] = deploymentSpec.ApiKey
-	envVars["AGENT_ID"] = deploymentSpec.AgentID
-
 	agDeploymentFolder := path.Join(r.hostStorageFolder, deploymentSpec.DeploymentName)
 	// make sure the folder exists
 	if _, err := os.Stat(agDeploymentFolder); os.IsNotExist(err) {
@@ -204,6 +246,94 @@ func (r *runner) createServiceConfig(projectName string, deploymentSpec internal
 	return &sc, nil
 }
 
+// createServiceSecrets moves the secret env vars of the service into files mounted as compose secrets
+func (r *runner) createServiceSecrets(project *types.Project, mainAgentName string, deploymentSpec internal.AgentDeploymentBuildSpec, sc *types.ServiceConfig) error {
+	secretNames := make([]string, 0)
+	for name := range sc.Environment {
+		if deploymentSpec.IsSecretEnvVar(name) {
+			secretNames = append(secretNames, name)
+		}
+	}
+	if len(secretNames) == 0 {
+		return nil
+	}
+	sort.Strings(secretNames)
+	if project.Secrets == nil {
+		project.Secrets = make(types.Secrets)
+	}
+
+	secretsFolder := path.Join(r.secretsFolder(mainAgentName), deploymentSpec.DeploymentName)
+	if err := os.MkdirAll(secretsFolder, 0700); err != nil {
+		return fmt.Errorf("failed to create secrets folder: %v", err)
+	}
+	for _, name := range secretNames {
+		secretFile := path.Join(secretsFolder, name)
+		if err := os.WriteFile(secretFile, []byte(*sc.Environment[name]), secretFileMode); err != nil {
+			return fmt.Errorf("failed to write secret file: %v", err)
+		}
+		delete(sc.Environment, name)
+
+		secretName := fmt.Sprintf("%s_%s", deploymentSpec.ServiceName, name)
+		project.Secrets[secretName] = types.SecretConfig{
+			File: secretFile,
+		}
+		// mounted as /run/secrets/<env var name>
+		sc.Secrets = append(sc.Secrets, types.ServiceSecretConfig{
+			Source: secretName,
+			Target: name,
+		})
+	}
+	return nil
+}
+
+// composeFilePath is the path of the compose file generated for the deployment
+func (r *runner) composeFilePath(deploymentName string) string {
+	return path.Join(r.hostStorageFolder, fmt.Sprintf("compose-%s.yaml", deploymentName))
+}
+
+// secretsFolder is the folder of the secret files of the deployment, named after its compose project name
+// so deploy and stop find the same folder whatever form of the agent name they are given
+func (r *runner) secretsFolder(deploymentName string) string {
+	return path.Join(r.hostStorageFolder, fmt.Sprintf("secrets-%s", util.GetDockerComposeProjectName(deploymentName)))
+}
+
+// getSecretsMode returns how secret env vars are passed to the containers, set by WFSM_DOCKER_SECRETS
+func getSecretsMode() (string, error) {
+	secretsMode := os.Getenv("WFSM_DOCKER_SECRETS")
+	switch secretsMode {
+	case "":
+		return SecretsModeFile, nil
+	case SecretsModeFile, SecretsModeEnv:
+		return secretsMode, nil
+	}
+	return "", fmt.Errorf("invalid WFSM_DOCKER_SECRETS value %q, supported values: %s, %s", secretsMode, SecretsModeFile, SecretsModeEnv)
+}
+
+// redactProject returns a copy of the project with the values of secret env vars redacted
+func redactProject(project *types.Project, agentDeploymentSpecs map[string]internal.AgentDeploymentBuildSpec) *types.Project {
+	redacted := *project
+	redacted.Services = make(types.Services, len(project.Services))
+	for name, sc := range project.Services {
+		redacted.Services[name] = sc
+	}
+	for _, deploymentSpec := range agentDeploymentSpecs {
+		sc, ok := redacted.Services[deploymentSpec.ServiceName]
+		if !ok {
+			continue
+		}
+		environment := make(types.MappingWithEquals, len(sc.Environment))
+		for name, value := range sc.Environment {
+			if deploymentSpec.IsSecretEnvVar(name) && value != nil {
+				value = getStringPtr(internal.RedactedValue)
+			}
+			environment[name] = value
+		}
+		sc.Environment = environment
+		redacted.Services[deploymentSpec.ServiceName] = sc
+	}
+	return &redacted
+}
+
 func getStringPtr(s string) *string {
 	return &s
 }
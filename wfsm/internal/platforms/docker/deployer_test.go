@@ -5,6 +5,7 @@ package docker
 import (
 	"context"
 	"os"
+	"path"
 	"testing"
 
 	"github.com/cisco-eti/wfsm/internal"
@@ -54,6 +55,7 @@ func TestRunner_Deploy_DryRun(t *testing.T) {
 			},
 			Image:       "test-agent-a-image",
 			ServiceName: "test-agent-a-service",
+			SecretFiles: true,
 		},
 		"test-agent-B": {
 			AgentSpec: internal.AgentSpec{
@@ -89,6 +91,7 @@ func TestRunner_Deploy_DryRun(t *testing.T) {
 			},
 			Image:       "test-agent-b-image",
 			ServiceName: "test-agent-b-service",
+			SecretFiles: true,
 		},
 	}
 
@@ -108,6 +111,7 @@ func TestRunner_Deploy_DryRun(t *testing.T) {
 	// Create a runner instance
 	r := &runner{
 		hostStorageFolder: hostStorageFolder,
+		revealSecrets:     true,
 	}
 
 	// Call the Deploy function with dryRun = true
@@ -134,3 +138,137 @@ func TestRunner_Deploy_DryRun(t *testing.T) {
 	// Compare the actual artifact to the expected artifact
 	assert.Equal(t, expectedArtifactData, actualArtifactData, "The actual artifact should match the expected artifact")
 }
+
+func TestRunner_Deploy_DryRun_RedactsSecrets(t *testing.T) {
+	// secrets are passed as env vars, so they are redacted in the compose environment
+	t.Setenv("WFSM_DOCKER_SECRETS", SecretsModeEnv)
+	hostStorageFolder := t.TempDir()
+	agentDeploymentSpecs := map[string]internal.AgentDeploymentBuildSpec{
+		"test-agent-A": {
+			AgentSpec: internal.AgentSpec{
+				AgentID:        "d8084dc6-52c4-4316-8460-8f43b64db17a",
+				ApiKey:         "4a69e02d-b03a-47e4-99ab-f0782be35f62",
+				DeploymentName: "test-agent-A",
+				EnvVars: map[string]string{
+					"OPENAI_API_KEY":  "sk-openai",
+					"DB_PASSWORD":     "db-secret",
+					"ENV_VAR_AGENT_A": "valueA",
+				},
+				SecretEnvVars: []string{"DB_PASSWORD"},
+			},
+			Image:       "test-agent-a-image",
+			ServiceName: "test-agent-a-service",
+		},
+	}
+
+	r := NewDockerComposeRunner(hostStorageFolder, false)
+	artifact, err := r.Deploy(context.Background(), "test-agent-A", agentDeploymentSpecs, map[string][]string{}, true)
+	assert.NoError(t, err, "Deploy should not return an error")
+
+	var compose struct {
+		Services map[string]struct {
+			Environment map[string]string `yaml:"environment"`
+		} `yaml:"services"`
+	}
+	assert.NoError(t, yaml.Unmarshal(artifact, &compose))
+	environment := compose.Services["test-agent-a-service"].Environment
+	assert.Equal(t, internal.RedactedValue, environment["API_KEY"])
+	assert.Equal(t, internal.RedactedValue, environment["OPENAI_API_KEY"])
+	assert.Equal(t, internal.RedactedValue, environment["DB_PASSWORD"])
+	assert.Equal(t, "valueA", environment["ENV_VAR_AGENT_A"])
+
+	// the compose file used by docker compose keeps the secrets
+	composeFile, err := os.ReadFile(hostStorageFolder + "/compose-test-agent-A.yaml")
+	assert.NoError(t, err)
+	assert.Contains(t, string(composeFile), "db-secret")
+}
+
+func TestRunner_Deploy_SecretFiles(t *testing.T) {
+	hostStorageFolder := t.TempDir()
+	agentDeploymentSpecs := map[string]internal.AgentDeploymentBuildSpec{
+		"test-agent-A": {
+			AgentSpec: internal.AgentSpec{
+				AgentID:        "d8084dc6-52c4-4316-8460-8f43b64db17a",
+				ApiKey:         "4a69e02d-b03a-47e4-99ab-f0782be35f62",
+				DeploymentName: "test-agent-A",
+				EnvVars: map[string]string{
+					"DB_PASSWORD":     "db-secret",
+					"ENV_VAR_AGENT_A": "valueA",
+				},
+				SecretEnvVars: []string{"DB_PASSWORD"},
+			},
+			Image:       "test-agent-a-image",
+			ServiceName: "test-agent-a-service",
+			SecretFiles: true,
+		},
+		// prebuilt image which can't read /run/secrets
+		"test-agent-B": {
+			AgentSpec: internal.AgentSpec{
+				AgentID:        "2c2cdbd6-6cd0-4d4e-9b0f-34a1e9c2e1a5",
+				ApiKey:         "6c7fbb47-1c43-4d3c-a1f4-5a1b0e3c8f0d",
+				DeploymentName: "test-agent-B",
+				EnvVars: map[string]string{
+					"DB_PASSWORD": "db-secret-b",
+				},
+				SecretEnvVars: []string{"DB_PASSWORD"},
+			},
+			Image:       "test-agent-b-image",
+			ServiceName: "test-agent-b-service",
+		},
+	}
+
+	// a stale secret of a previous deployment is removed
+	staleSecret := path.Join(hostStorageFolder, "secrets-test-agent-a", "test-agent-A", "OLD_SECRET")
+	assert.NoError(t, os.MkdirAll(path.Dir(staleSecret), 0700))
+	assert.NoError(t, os.WriteFile(staleSecret, []byte("old"), 0600))
+
+	r := NewDockerComposeRunner(hostStorageFolder, true)
+	artifact, err := r.Deploy(context.Background(), "test-agent-A", agentDeploymentSpecs, map[string][]string{}, true)
+	assert.NoError(t, err, "Deploy should not return an error")
+
+	var compose struct {
+		Services map[string]struct {
+			Environment map[string]string `yaml:"environment"`
+			Secrets     []struct {
+				Source string `yaml:"source"`
+				Target string `yaml:"target"`
+			} `yaml:"secrets"`
+		} `yaml:"services"`
+		Secrets map[string]struct {
+			File string `yaml:"file"`
+		} `yaml:"secrets"`
+	}
+	assert.NoError(t, yaml.Unmarshal(artifact, &compose))
+	service := compose.Services["test-agent-a-service"]
+	assert.Equal(t, map[string]string{
+		"AGENT_ID":        "d8084dc6-52c4-4316-8460-8f43b64db17a",
+		"API_HOST":        "0.0.0.0",
+		"API_PORT":        "8000",
+		"ENV_VAR_AGENT_A": "valueA",
+	}, service.Environment)
+	assert.Len(t, service.Secrets, 2)
+	assert.Equal(t, "DB_PASSWORD", service.Secrets[1].Target)
+
+	secrets := map[string]string{
+		"API_KEY":     "4a69e02d-b03a-47e4-99ab-f0782be35f62",
+		"DB_PASSWORD": "db-secret",
+	}
+	for name, value := range secrets {
+		secretFile := compose.Secrets["test-agent-a-service_"+name].File
+		assert.Equal(t, path.Join(hostStorageFolder, "secrets-test-agent-a", "test-agent-A", name), secretFile)
+		info, err := os.Stat(secretFile)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+		data, err := os.ReadFile(secretFile)
+		assert.NoError(t, err)
+		assert.Equal(t, value, string(data))
+	}
+	assert.NoFileExists(t, staleSecret)
+
+	// secret env vars of images not built by wfsm are kept in the compose environment
+	serviceB := compose.Services["test-agent-b-service"]
+	assert.Empty(t, serviceB.Secrets)
+	assert.Equal(t, "db-secret-b", serviceB.Environment["DB_PASSWORD"])
+	assert.Equal(t, "6c7fbb47-1c43-4d3c-a1f4-5a1b0e3c8f0d", serviceB.Environment["API_KEY"])
+	assert.NoDirExists(t, path.Join(hostStorageFolder, "secrets-test-agent-a", "test-agent-B"))
+}
@@ -0,0 +1,42 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/cisco-eti/wfsm/internal/util"
+)
+
+// podmanComposeCommand returns podman-compose if it is on the PATH,
+// otherwise `podman compose`, which runs the compose provider configured for podman
+func podmanComposeCommand() (string, []string) {
+	if _, err := exec.LookPath("podman-compose"); err == nil {
+		return "podman-compose", nil
+	}
+	return "podman", []string{"compose"}
+}
+
+// podmanCompose returns the podman-compose command running args on the compose project of the deployment
+func (r *runner) podmanCompose(ctx context.Context, deploymentName string, args ...string) *exec.Cmd {
+	name, composeArgs := podmanComposeCommand()
+	composeArgs = append(composeArgs,
+		"--project-name", util.GetDockerComposeProjectName(deploymentName),
+		"--file", r.composeFilePath(deploymentName))
+	cmd := exec.CommandContext(ctx, name, append(composeArgs, args...)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// podmanComposeLogs follows the logs of the agents of the deployment, all of them if agentNames is empty
+func (r *runner) podmanComposeLogs(ctx context.Context, deploymentName string, agentNames []string) error {
+	args := append([]string{"logs", "--follow", "--tail", "100"}, agentNames...)
+	if err := r.podmanCompose(ctx, deploymentName, args...).Run(); err != nil {
+		return fmt.Errorf("failed to show logs with podman-compose: %v", err)
+	}
+	return nil
+}
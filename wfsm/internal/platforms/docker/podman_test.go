@@ -0,0 +1,84 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package docker
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/cisco-eti/wfsm/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPodmanComposeRunner(t *testing.T) {
+	hostStorageFolder := t.TempDir()
+
+	// a fake podman-compose records its args
+	binDir := t.TempDir()
+	argsFile := path.Join(binDir, "args")
+	script := "#!/bin/sh\necho \"$@\" >> " + argsFile + "\n"
+	assert.NoError(t, os.WriteFile(path.Join(binDir, "podman-compose"), []byte(script), 0755))
+	t.Setenv("PATH", binDir)
+
+	agentDeploymentSpecs := map[string]internal.AgentDeploymentBuildSpec{
+		"test-agent-A": {
+			AgentSpec: internal.AgentSpec{
+				AgentID:        "d8084dc6-52c4-4316-8460-8f43b64db17a",
+				ApiKey:         "4a69e02d-b03a-47e4-99ab-f0782be35f62",
+				DeploymentName: "test-agent-A",
+				EnvVars:        map[string]string{},
+			},
+			Image:       "test-agent-a-image",
+			ServiceName: "test-agent-a-service",
+		},
+	}
+
+	r := NewPodmanComposeRunner(hostStorageFolder, false)
+	_, err := r.Deploy(context.Background(), "test-agent-A", agentDeploymentSpecs, map[string][]string{}, false)
+	assert.NoError(t, err)
+	assert.NoError(t, r.List(context.Background(), "test-agent-A"))
+	assert.NoError(t, r.Remove(context.Background(), "test-agent-A"))
+
+	composeFile := path.Join(hostStorageFolder, "compose-test-agent-A.yaml")
+	assert.FileExists(t, composeFile)
+	args, err := os.ReadFile(argsFile)
+	assert.NoError(t, err)
+	prefix := "--project-name test-agent-a --file " + composeFile
+	assert.Equal(t, prefix+" up --detach --remove-orphans\n"+
+		prefix+" logs --follow --tail 100\n"+
+		prefix+" ps\n"+
+		prefix+" down\n", string(args))
+}
+
+func TestPodmanComposeRunner_RemoveSecrets(t *testing.T) {
+	hostStorageFolder := t.TempDir()
+
+	binDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(binDir, "podman-compose"), []byte("#!/bin/sh\n"), 0755))
+	t.Setenv("PATH", binDir)
+
+	agentDeploymentSpecs := map[string]internal.AgentDeploymentBuildSpec{
+		"org.agntcy.mailcomposer": {
+			AgentSpec: internal.AgentSpec{
+				AgentID:        "d8084dc6-52c4-4316-8460-8f43b64db17a",
+				ApiKey:         "4a69e02d-b03a-47e4-99ab-f0782be35f62",
+				DeploymentName: "org.agntcy.mailcomposer",
+				EnvVars:        map[string]string{},
+			},
+			Image:       "mailcomposer-image",
+			ServiceName: "mailcomposer",
+			SecretFiles: true,
+		},
+	}
+
+	r := NewPodmanComposeRunner(hostStorageFolder, false)
+	_, err := r.Deploy(context.Background(), "org.agntcy.mailcomposer", agentDeploymentSpecs, map[string][]string{}, false)
+	assert.NoError(t, err)
+	secretsFolder := path.Join(hostStorageFolder, "secrets-orgagntcymailcomposer")
+	assert.FileExists(t, path.Join(secretsFolder, "org.agntcy.mailcomposer", "API_KEY"))
+
+	assert.NoError(t, r.Remove(context.Background(), "org.agntcy.mailcomposer"))
+	assert.NoDirExists(t, secretsFolder)
+}
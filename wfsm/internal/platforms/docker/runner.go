@@ -8,6 +8,7 @@ import (
 	"os"
 
 	"github.com/cisco-eti/wfsm/internal"
+	"github.com/cisco-eti/wfsm/internal/engine"
 	"github.com/cisco-eti/wfsm/internal/util"
 	"github.com/docker/compose/v2/cmd/formatter"
 	"github.com/docker/compose/v2/pkg/api"
@@ -18,15 +19,37 @@ import (
 // DockerComposeRunner implementation of AgentDeploymentRunner
 type runner struct {
 	hostStorageFolder string
+	// revealSecrets disables the redaction of secret env vars in the dry run output and deployment summary
+	revealSecrets bool
+	// engine runs the compose project, docker with the docker compose libraries or podman with podman-compose
+	engine string
 }
 
-func NewDockerComposeRunner(hostStorageFolder string) internal.AgentDeploymentRunner {
+func NewDockerComposeRunner(hostStorageFolder string, revealSecrets bool) internal.AgentDeploymentRunner {
 	return &runner{
 		hostStorageFolder: hostStorageFolder,
+		revealSecrets:     revealSecrets,
+		engine:            engine.Docker,
+	}
+}
+
+// NewPodmanComposeRunner returns the runner deploying the compose project with podman-compose
+func NewPodmanComposeRunner(hostStorageFolder string, revealSecrets bool) internal.AgentDeploymentRunner {
+	return &runner{
+		hostStorageFolder: hostStorageFolder,
+		revealSecrets:     revealSecrets,
+		engine:            engine.Podman,
 	}
 }
 
 func (r *runner) Remove(ctx context.Context, deploymentName string) error {
+	if r.engine == engine.Podman {
+		if err := r.podmanCompose(ctx, deploymentName, "down").Run(); err != nil {
+			return fmt.Errorf("failed to remove deployment with podman-compose: %v", err)
+		}
+		return r.removeSecrets(deploymentName)
+	}
+
 	dockerCli, err := util.GetDockerCLI(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to initialize docker client: %v", err)
@@ -42,10 +65,21 @@ func (r *runner) Remove(ctx context.Context, deploymentName string) error {
 		return err
 	}
 
+	return r.removeSecrets(deploymentName)
+}
+
+func (r *runner) removeSecrets(deploymentName string) error {
+	if err := os.RemoveAll(r.secretsFolder(deploymentName)); err != nil {
+		return fmt.Errorf("failed to remove secrets folder: %v", err)
+	}
 	return nil
 }
 
 func (r *runner) Logs(ctx context.Context, deploymentName string, agentNames []string) error {
+	if r.engine == engine.Podman {
+		return r.podmanComposeLogs(ctx, deploymentName, agentNames)
+	}
+
 	dockerCli, err := util.GetDockerCLI(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to initialize docker client: %v", err)
@@ -70,6 +104,13 @@ func (r *runner) Logs(ctx context.Context, deploymentName string, agentNames []s
 func (r *runner) List(ctx context.Context, deploymentName string) error {
 	log := zerolog.Ctx(ctx)
 
+	if r.engine == engine.Podman {
+		if err := r.podmanCompose(ctx, deploymentName, "ps").Run(); err != nil {
+			return fmt.Errorf("failed to list deployment with podman-compose: %v", err)
+		}
+		return nil
+	}
+
 	dockerCli, err := util.GetDockerCLI(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to initialize docker client: %v", err)
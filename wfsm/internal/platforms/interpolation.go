@@ -0,0 +1,137 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package platforms
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cisco-eti/wfsm/internal"
+	"github.com/cisco-eti/wfsm/internal/platforms/docker"
+	"github.com/cisco-eti/wfsm/internal/platforms/k8s"
+	"github.com/cisco-eti/wfsm/internal/util"
+)
+
+// Endpoint returns the url other agents of the deployment reach the agent at on the platform
+func Endpoint(platform string, agentDeploymentSpec internal.AgentDeploymentBuildSpec) string {
+	switch platform {
+	case internal.KUBERNETES:
+		return k8s.Endpoint(agentDeploymentSpec)
+	case internal.DOCKER:
+		return docker.Endpoint(agentDeploymentSpec)
+	}
+	return ""
+}
+
+// InterpolateEnvVars replaces the template expressions in the env var values of the agents:
+//
+//	{{ deps.<dependency>.endpoint }}  url of the dependency, as in <DEP>_ENDPOINT
+//	{{ deps.<dependency>.apiKey }}    API key of the dependency (the key only, not the x-api-key json of <DEP>_API_KEY)
+//	{{ deps.<dependency>.id }}        agent ID of the dependency
+//	{{ deps.<dependency>.host }}      host name of the dependency
+//	{{ deps.<dependency>.port }}      port of the dependency
+//	{{ self.<field> }}                the same fields of the agent itself, port is the port its server listens on
+//
+// Only the dependencies of an agent can be referenced. Env vars referencing an API key are marked secret.
+// Errors are collected for all agents.
+func InterpolateEnvVars(platform string, agentDeploymentSpecs map[string]internal.AgentDeploymentBuildSpec, dependencies map[string][]string) error {
+	agentNames := make([]string, 0, len(agentDeploymentSpecs))
+	for agentName := range agentDeploymentSpecs {
+		agentNames = append(agentNames, agentName)
+	}
+	sort.Strings(agentNames)
+
+	var errs []error
+	for _, agentName := range agentNames {
+		agSpec := agentDeploymentSpecs[agentName]
+		lookup := func(expression string) (string, error) {
+			return lookupTemplateValue(platform, agentName, expression, agentDeploymentSpecs, dependencies)
+		}
+		for envVarName, value := range agSpec.EnvVars {
+			if !internal.HasEnvTemplate(value) {
+				continue
+			}
+			interpolated, err := internal.InterpolateEnvTemplate(value, lookup)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("env var %s of agent %s: %v", envVarName, agentName, err))
+				continue
+			}
+			agSpec.EnvVars[envVarName] = interpolated
+			if referencesAPIKey(value) && !slices.Contains(agSpec.SecretEnvVars, envVarName) {
+				agSpec.SecretEnvVars = append(slices.Clone(agSpec.SecretEnvVars), envVarName)
+			}
+		}
+		agentDeploymentSpecs[agentName] = agSpec
+	}
+	return errors.Join(errs...)
+}
+
+// PreviewDeploymentSpecs returns the deployment specs of the agents as created by the agent builders, without building the agents,
+// e.g. to check the template expressions in env var values before the agents are built. The env vars of the agents are copied.
+func PreviewDeploymentSpecs(agentSpecs map[string]internal.AgentSpec) map[string]internal.AgentDeploymentBuildSpec {
+	agentDeploymentSpecs := make(map[string]internal.AgentDeploymentBuildSpec, len(agentSpecs))
+	for name, spec := range agentSpecs {
+		spec.EnvVars = maps.Clone(spec.EnvVars)
+		// service names are the deployment names, the same as set by the agent builders
+		agentDeploymentSpecs[name] = internal.AgentDeploymentBuildSpec{AgentSpec: spec, ServiceName: spec.DeploymentName}
+	}
+	return agentDeploymentSpecs
+}
+
+func lookupTemplateValue(platform string, agentName string, expression string, agentDeploymentSpecs map[string]internal.AgentDeploymentBuildSpec, dependencies map[string][]string) (string, error) {
+	var target string
+	var field string
+	switch {
+	case strings.HasPrefix(expression, "self."):
+		target, field = agentName, strings.TrimPrefix(expression, "self.")
+	case strings.HasPrefix(expression, "deps."):
+		// dependency names can contain dots, the field is the last part of the expression
+		name := strings.TrimPrefix(expression, "deps.")
+		idx := strings.LastIndex(name, ".")
+		if idx <= 0 {
+			return "", fmt.Errorf("invalid template expression {{ %s }}, expected {{ deps.<dependency>.<field> }}", expression)
+		}
+		target, field = name[:idx], name[idx+1:]
+		if !slices.Contains(dependencies[agentName], target) {
+			return "", fmt.Errorf("%s in {{ %s }} is not a dependency of the agent", target, expression)
+		}
+	default:
+		return "", fmt.Errorf("invalid template expression {{ %s }}, expected deps.<dependency>.<field> or self.<field>", expression)
+	}
+
+	spec := agentDeploymentSpecs[target]
+	switch field {
+	case "endpoint":
+		return Endpoint(platform, spec), nil
+	case "apiKey":
+		return spec.ApiKey, nil
+	case "id":
+		return spec.AgentID, nil
+	case "host":
+		if platform == internal.KUBERNETES {
+			return util.NormalizeAgentName(spec.ServiceName), nil
+		}
+		return spec.ServiceName, nil
+	case "port":
+		if target != agentName && platform == internal.KUBERNETES {
+			return strconv.Itoa(spec.Port), nil
+		}
+		return strconv.Itoa(internal.DEFAULT_API_PORT), nil
+	default:
+		return "", fmt.Errorf("unknown field %s in {{ %s }}, supported fields: endpoint, apiKey, id, host, port", field, expression)
+	}
+}
+
+func referencesAPIKey(value string) bool {
+	for _, expression := range internal.EnvTemplateExpressions(value) {
+		if strings.HasSuffix(expression, ".apiKey") {
+			return true
+		}
+	}
+	return false
+}
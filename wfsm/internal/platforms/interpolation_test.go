@@ -0,0 +1,74 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package platforms
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cisco-eti/wfsm/internal"
+)
+
+func deploymentSpecs(envVars map[string]string) map[string]internal.AgentDeploymentBuildSpec {
+	return map[string]internal.AgentDeploymentBuildSpec{
+		"mailcomposer": {
+			AgentSpec:   internal.AgentSpec{DeploymentName: "mailcomposer", AgentID: "id_m", ApiKey: "key_m", Port: 8080, EnvVars: envVars},
+			ServiceName: "mailcomposer",
+		},
+		"email_reviewer_1": {
+			AgentSpec:   internal.AgentSpec{DeploymentName: "email_reviewer_1", AgentID: "id_e", ApiKey: "key_e", Port: 8000, EnvVars: map[string]string{}},
+			ServiceName: "email_reviewer_1",
+		},
+	}
+}
+
+func TestInterpolateEnvVars(t *testing.T) {
+	dependencies := map[string][]string{"mailcomposer": {"email_reviewer_1"}}
+	tests := []struct {
+		name        string
+		platform    string
+		value       string
+		expected    string
+		expectedErr string
+	}{
+		{name: "plain value", platform: internal.DOCKER, value: "http://example.com", expected: "http://example.com"},
+		{name: "docker endpoint", platform: internal.DOCKER, value: "{{ deps.email_reviewer_1.endpoint }}", expected: "http://email_reviewer_1:8000"},
+		{name: "k8s endpoint", platform: internal.KUBERNETES, value: "{{ deps.email_reviewer_1.endpoint }}", expected: "http://email-reviewer-1:8000"},
+		{name: "several expressions", platform: internal.DOCKER, value: "{{deps.email_reviewer_1.host}}:{{deps.email_reviewer_1.port}}/{{ deps.email_reviewer_1.id }}", expected: "email_reviewer_1:8000/id_e"},
+		{name: "api key", platform: internal.DOCKER, value: `{"Authorization": "Bearer {{ deps.email_reviewer_1.apiKey }}"}`, expected: `{"Authorization": "Bearer key_e"}`},
+		{name: "self", platform: internal.KUBERNETES, value: "{{ self.id }} {{ self.port }} {{ self.endpoint }}", expected: "id_m 8000 http://mailcomposer:8080"},
+		{name: "not a dependency", platform: internal.DOCKER, value: "{{ deps.mailcomposer.endpoint }}", expectedErr: "env var ENV_VAR of agent mailcomposer: mailcomposer in {{ deps.mailcomposer.endpoint }} is not a dependency of the agent"},
+		{name: "unknown field", platform: internal.DOCKER, value: "{{ deps.email_reviewer_1.url }}", expectedErr: "env var ENV_VAR of agent mailcomposer: unknown field url in {{ deps.email_reviewer_1.url }}, supported fields: endpoint, apiKey, id, host, port"},
+		{name: "invalid expression", platform: internal.DOCKER, value: "{{ email_reviewer_1.endpoint }}", expectedErr: "env var ENV_VAR of agent mailcomposer: invalid template expression {{ email_reviewer_1.endpoint }}, expected deps.<dependency>.<field> or self.<field>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.platform+"/"+tt.name, func(t *testing.T) {
+			specs := deploymentSpecs(map[string]string{"ENV_VAR": tt.value})
+			err := InterpolateEnvVars(tt.platform, specs, dependencies)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, specs["mailcomposer"].EnvVars["ENV_VAR"])
+			assert.Equal(t, tt.name == "api key", specs["mailcomposer"].IsSecretEnvVar("ENV_VAR"), "env vars referencing API keys are secret")
+		})
+	}
+}
+
+func TestInjectedEnvVars_Disabled(t *testing.T) {
+	dependencies := map[string][]string{"mailcomposer": {"email_reviewer_1"}}
+	for _, platform := range []string{internal.DOCKER, internal.KUBERNETES} {
+		specs := deploymentSpecs(map[string]string{})
+		assert.Contains(t, InjectedEnvVars(platform, "mailcomposer", specs, dependencies), "EMAIL_REVIEWER_1_ENDPOINT")
+
+		mainSpec := specs["mailcomposer"]
+		mainSpec.InjectDependencyEnvVars = map[string]bool{"email_reviewer_1": false}
+		specs["mailcomposer"] = mainSpec
+		envVars := InjectedEnvVars(platform, "mailcomposer", specs, dependencies)
+		assert.NotContains(t, envVars, "EMAIL_REVIEWER_1_ENDPOINT")
+		assert.NotContains(t, envVars, "EMAIL_REVIEWER_1_API_KEY")
+		assert.Equal(t, "key_m", envVars["API_KEY"])
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"os"
 	"path"
 	"sort"
-	"strconv"
 	"time"
 
 	"github.com/cisco-eti/wfsm/assets"
@@ -22,7 +21,6 @@ import (
 )
 
 const ConfigCheckSum = "org.agntcy.wfsm.config.checksum"
-const APIHost = "0.0.0.0"
 
 // Deploy generates a Docker compose file from the agent deployment specs and deploys it if dryRun = false
 func (r *runner) Deploy(ctx context.Context,
@@ -35,15 +33,9 @@ func (r *runner) Deploy(ctx context.Context,
 	namespace := getK8sNamespace()
 
 	// insert api keys, agent IDs and service names as host into the deployment specs
-	for agName, deps := range dependencies {
-		agSpec := agentDeploymentSpecs[agName]
-		for _, depName := range deps {
-			depAgPrefix := util.CalculateEnvVarPrefix(depName)
-			depSpec := agentDeploymentSpecs[depName]
-			agSpec.EnvVars[depAgPrefix+"API_KEY"] = fmt.Sprintf("{\"x-api-key\": \"%s\"}", depSpec.ApiKey)
-			agSpec.EnvVars[depAgPrefix+"ID"] = depSpec.AgentID
-			// service name is the same as the deployment name but should be normalized to k8s standard
-			agSpec.EnvVars[depAgPrefix+"ENDPOINT"] = fmt.Sprintf("http://%s:%d", util.NormalizeAgentName(depSpec.ServiceName), depSpec.Port)
+	for agName, agSpec := range agentDeploymentSpecs {
+		for name, value := range internal.InjectedEnvVars(agName, agentDeploymentSpecs, dependencies, Endpoint) {
+			agSpec.EnvVars[name] = value
 		}
 	}
 
@@ -99,7 +91,15 @@ func (r *runner) Deploy(ctx context.Context,
 	log.Info().Msgf("You can deploy the agent running `wfsm deploy` with --dryRun=false` option or `helm install -n %s %s %s --values %s`", namespace, releaseName, chartUrl, valuesFilePath)
 
 	if dryRun {
-		return yamlData, nil
+		if r.revealSecrets {
+			return yamlData, nil
+		}
+		// the values file keeps the secrets, only the returned artifact is redacted
+		redactedYamlData, err := yaml.Marshal(redactChartValues(chartValues))
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal chart values: %v", err)
+		}
+		return redactedYamlData, nil
 	}
 
 	deployer := NewHelmDeployer()
@@ -117,7 +117,7 @@ func (r *runner) Deploy(ctx context.Context,
 	log.Info().Msgf("ACP agent helm chart release name: %s", releaseName)
 	log.Info().Msgf("ACP agent running in namespace: %s, listening for ACP requests on: http://%s", namespace, endpoint)
 	log.Info().Msgf("Agent ID: %s", mainAgentID)
-	log.Info().Msgf("API Key: %s", mainAgentAPiKey)
+	log.Info().Msgf("API Key: %s", internal.Redact(mainAgentAPiKey, r.revealSecrets))
 	log.Info().Msgf("API Docs: http://%s/agents/%s/docs", endpoint, mainAgentID)
 	log.Info().Msgf("\nAllow some time for the agents to start, you can check the status with: kubectl get pods -n %s", namespace)
 	log.Info().Msg("---------------------------------------------------------------------\n\n\n")
@@ -201,14 +201,16 @@ func getNodePortEndpoint(ctx context.Context, serviceName string, namespace stri
 }
 
 func (r *runner) createAgentValuesConfig(deploymentSpec internal.AgentDeploymentBuildSpec) (*AgentValues, error) {
-	envVars := deploymentSpec.EnvVars
-
-	envVars["API_HOST"] = APIHost
-	envVars["API_PORT"] = strconv.Itoa(internal.DEFAULT_API_PORT)
-	envVars["AGENT_ID"] = deploymentSpec.AgentID
-
+	// secret env vars (API_KEY, <DEP>_API_KEY, ...) are stored in the Secret of the agent, the others in its ConfigMap
+	envVars := make(map[string]string, len(deploymentSpec.EnvVars))
 	secretEnvVars := make(map[string]string, 10)
-	secretEnvVars["API_KEY"] = deploymentSpec.ApiKey
+	for name, value := range deploymentSpec.EnvVars {
+		if deploymentSpec.IsSecretEnvVar(name) {
+			secretEnvVars[name] = value
+		} else {
+			envVars[name] = value
+		}
+	}
 
 	configHash := calculateConfigHash(envVars, secretEnvVars)
 
@@ -254,6 +256,12 @@ func (r *runner) createAgentValuesConfig(deploymentSpec internal.AgentDeployment
 	return agentValues, nil
 }
 
+// Endpoint returns the url other agents of the deployment reach the agent at
+func Endpoint(agentDeploymentSpec internal.AgentDeploymentBuildSpec) string {
+	// service name is the same as the deployment name but should be normalized to k8s standard
+	return fmt.Sprintf("http://%s:%d", util.NormalizeAgentName(agentDeploymentSpec.ServiceName), agentDeploymentSpec.Port)
+}
+
 func calculateConfigHash(vars ...map[string]string) string {
 	hasher := sha256.New()
 
@@ -275,6 +283,22 @@ func calculateConfigHash(vars ...map[string]string) string {
 	return fmt.Sprintf("%x", hasher.Sum(nil))
 }
 
+// redactChartValues returns a copy of the chart values with the values of the secret env vars redacted
+func redactChartValues(chartValues ChartValues) ChartValues {
+	redacted := ChartValues{
+		Agents: make([]AgentValues, 0, len(chartValues.Agents)),
+	}
+	for _, agentValues := range chartValues.Agents {
+		secretEnvs := make([]EnvVar, 0, len(agentValues.SecretEnvs))
+		for _, env := range agentValues.SecretEnvs {
+			secretEnvs = append(secretEnvs, EnvVar{Name: env.Name, Value: internal.Redact(env.Value, false)})
+		}
+		agentValues.SecretEnvs = secretEnvs
+		redacted.Agents = append(redacted.Agents, agentValues)
+	}
+	return redacted
+}
+
 func convertEnvVars(envVars map[string]string) []EnvVar {
 	var result []EnvVar
 	for key, value := range envVars {
@@ -283,6 +307,10 @@ func convertEnvVars(envVars map[string]string) []EnvVar {
 			Value: value,
 		})
 	}
+	// keep the generated values stable
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
 	return result
 }
 
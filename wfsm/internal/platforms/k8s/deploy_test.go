@@ -12,7 +12,7 @@ import (
 
 func TestDeploy_DryRun_GeneratesExpectedOutput(t *testing.T) {
 	// Arrange
-	runner := NewK8sRunner("/tmp")
+	runner := NewK8sRunner("/tmp", true)
 	mainAgentName := "mailcomposer"
 	agentDeploymentSpecs := map[string]internal.AgentDeploymentBuildSpec{
 		"mailcomposer": {
@@ -35,6 +35,9 @@ func TestDeploy_DryRun_GeneratesExpectedOutput(t *testing.T) {
 					},
 					StatefulSet: internal.StatefulSet{
 						Replicas: 1,
+						Tolerations: []internal.Toleration{
+							{Key: "dedicated", Operator: "Equal", Value: "agents", Effect: "NoSchedule"},
+						},
 					},
 				},
 			},
@@ -160,3 +163,46 @@ func TestCalculateConfigHash(t *testing.T) {
 	differentHash := calculateConfigHash(input3, input2)
 	assert.NotEqual(t, expectedHash, differentHash, "Hashes should differ for different input maps")
 }
+
+func TestDeploy_DryRun_RedactsSecrets(t *testing.T) {
+	hostStorageFolder := t.TempDir()
+	runner := NewK8sRunner(hostStorageFolder, false)
+	agentDeploymentSpecs := map[string]internal.AgentDeploymentBuildSpec{
+		"mailcomposer": {
+			AgentSpec: internal.AgentSpec{
+				AgentID: "1141b40c-8278-495f-9d0a-680d64573bae",
+				ApiKey:  "aa15dbbe-e9c7-4d05-a750-464e7c8bfed1",
+				Port:    internal.DEFAULT_API_PORT,
+				EnvVars: map[string]string{
+					"AZURE_OPENAI_API_KEY": "sk-azure",
+					"DB_PASSWORD":          "db-secret",
+					"AZURE_OPENAI_MODEL":   "gpt-4o-mini",
+				},
+				SecretEnvVars: []string{"DB_PASSWORD"},
+			},
+			ServiceName: "mailcomposer",
+			Image:       "agntcy/wfsm-mailcomposer:latest",
+		},
+	}
+
+	output, err := runner.Deploy(context.Background(), "mailcomposer", agentDeploymentSpecs, map[string][]string{}, true)
+	assert.NoError(t, err)
+
+	var values ChartValues
+	assert.NoError(t, yaml.Unmarshal(output, &values))
+	assert.Len(t, values.Agents, 1)
+	assert.ElementsMatch(t, []EnvVar{
+		{Name: "API_KEY", Value: internal.RedactedValue},
+		{Name: "AZURE_OPENAI_API_KEY", Value: internal.RedactedValue},
+		{Name: "DB_PASSWORD", Value: internal.RedactedValue},
+	}, values.Agents[0].SecretEnvs)
+	assert.Contains(t, values.Agents[0].Env, EnvVar{Name: "AZURE_OPENAI_MODEL", Value: "gpt-4o-mini"})
+	for _, env := range values.Agents[0].Env {
+		assert.NotEqual(t, "DB_PASSWORD", env.Name, "secret env vars must not be stored in the ConfigMap")
+	}
+
+	// the values file used for the helm install keeps the secrets
+	valuesFile, err := os.ReadFile(hostStorageFolder + "/values-mailcomposer.yaml")
+	assert.NoError(t, err)
+	assert.Contains(t, string(valuesFile), "db-secret")
+}
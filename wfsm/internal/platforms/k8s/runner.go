@@ -13,11 +13,14 @@ import (
 // NewK8sRunner implementation of AgentDeploymentRunner
 type runner struct {
 	hostStorageFolder string
+	// revealSecrets disables the redaction of secret env vars in the dry run output and deployment summary
+	revealSecrets bool
 }
 
-func NewK8sRunner(hostStorageFolder string) internal.AgentDeploymentRunner {
+func NewK8sRunner(hostStorageFolder string, revealSecrets bool) internal.AgentDeploymentRunner {
 	return &runner{
 		hostStorageFolder: hostStorageFolder,
+		revealSecrets:     revealSecrets,
 	}
 }
 
@@ -4,16 +4,33 @@ package platforms
 
 import (
 	"github.com/cisco-eti/wfsm/internal"
+	"github.com/cisco-eti/wfsm/internal/engine"
 	"github.com/cisco-eti/wfsm/internal/platforms/docker"
 	"github.com/cisco-eti/wfsm/internal/platforms/k8s"
 )
 
-func GetPlatformRunner(platform string, hostStorageFolder string) internal.AgentDeploymentRunner {
+// GetPlatformRunner returns the runner of the platform, if revealSecrets is set secret env vars are not redacted in the output.
+// The docker platform runs the agents on the container engine of the name (docker or podman).
+func GetPlatformRunner(platform string, engineName string, hostStorageFolder string, revealSecrets bool) internal.AgentDeploymentRunner {
 	switch platform {
 	case internal.KUBERNETES:
-		return k8s.NewK8sRunner(hostStorageFolder)
+		return k8s.NewK8sRunner(hostStorageFolder, revealSecrets)
 	case internal.DOCKER:
-		return docker.NewDockerComposeRunner(hostStorageFolder)
+		if engineName == engine.Podman {
+			return docker.NewPodmanComposeRunner(hostStorageFolder, revealSecrets)
+		}
+		return docker.NewDockerComposeRunner(hostStorageFolder, revealSecrets)
+	}
+	return nil
+}
+
+// InjectedEnvVars returns the env vars the runner of the platform sets for the agent on deployment
+func InjectedEnvVars(platform string, agentName string, agentDeploymentSpecs map[string]internal.AgentDeploymentBuildSpec, dependencies map[string][]string) map[string]string {
+	switch platform {
+	case internal.KUBERNETES:
+		return internal.InjectedEnvVars(agentName, agentDeploymentSpecs, dependencies, k8s.Endpoint)
+	case internal.DOCKER:
+		return internal.InjectedEnvVars(agentName, agentDeploymentSpecs, dependencies, docker.Endpoint)
 	}
 	return nil
 }
@@ -0,0 +1,46 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package internal
+
+import (
+	"slices"
+	"strings"
+)
+
+// SecretEnvVarAnnotation marks an env var declared in the agent manifest as secret when set to "true"
+const SecretEnvVarAnnotation = "org.agntcy.wfsm.secret"
+
+// RedactedValue replaces the values of secret env vars in logs and printouts
+const RedactedValue = "<redacted>"
+
+// IsSecretEnvVarName returns true for the env var names which are always secret:
+// API_KEY of the agent and <DEP>_API_KEY of its dependencies (and any other *_API_KEY)
+func IsSecretEnvVarName(name string) bool {
+	return name == "API_KEY" || strings.HasSuffix(name, "_API_KEY")
+}
+
+// IsSecretEnvVar returns true if the env var of the agent is secret by name,
+// or marked secret in the agent manifest or config
+func (s AgentSpec) IsSecretEnvVar(name string) bool {
+	return IsSecretEnvVarName(name) || slices.Contains(s.SecretEnvVars, name)
+}
+
+// RedactEnvVars returns a copy of the env vars with the values of secret env vars redacted
+func (s AgentSpec) RedactEnvVars(envVars map[string]string) map[string]string {
+	redacted := make(map[string]string, len(envVars))
+	for name, value := range envVars {
+		if s.IsSecretEnvVar(name) && value != "" {
+			value = RedactedValue
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
+
+// Redact returns RedactedValue for non empty values unless reveal is set
+func Redact(value string, reveal bool) string {
+	if reveal || value == "" {
+		return value
+	}
+	return RedactedValue
+}
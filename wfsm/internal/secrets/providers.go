@@ -0,0 +1,97 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// runCommand runs an external command and returns its stdout, it is replaced in tests
+var runCommand = defaultRunCommand
+
+func defaultRunCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("%s not found in PATH", name)
+		}
+		return nil, fmt.Errorf("%s failed: %v: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// resolveFile reads file:///path references, relative paths (file://path) are resolved from the working directory
+func resolveFile(ctx context.Context, ref Reference) (string, error) {
+	if ref.Key != "" {
+		return "", errors.New("file references do not support keys")
+	}
+	data, err := os.ReadFile(ref.Path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// resolveEnv reads env://VAR references from the OS environment
+func resolveEnv(ctx context.Context, ref Reference) (string, error) {
+	value, ok := os.LookupEnv(ref.Path)
+	if !ok {
+		return "", fmt.Errorf("env var %s is not set", ref.Path)
+	}
+	return value, nil
+}
+
+// resolveSops decrypts sops://file#KEY references with the sops CLI, which finds the age key
+// (SOPS_AGE_KEY_FILE, SOPS_AGE_KEY or the default keys.txt) or uses the gpg agent for PGP keys.
+// Nested keys are separated by '/', e.g. sops://secrets.enc.yaml#openai/api_key; without a key the whole file is returned.
+func resolveSops(ctx context.Context, ref Reference) (string, error) {
+	args := []string{"--decrypt"}
+	if ref.Key != "" {
+		var extract strings.Builder
+		for _, key := range strings.Split(ref.Key, "/") {
+			extract.WriteString("[" + strconv.Quote(key) + "]")
+		}
+		args = append(args, "--extract", extract.String())
+	}
+	args = append(args, ref.Path)
+
+	out, err := runCommand(ctx, "sops", args...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// resolveKeyring reads keyring://service/key references from the OS keyring
+func resolveKeyring(ctx context.Context, ref Reference) (string, error) {
+	service, key, found := strings.Cut(ref.Path, "/")
+	if !found || service == "" || key == "" {
+		return "", errors.New("keyring references must have the form keyring://service/key")
+	}
+
+	var out []byte
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		out, err = runCommand(ctx, "security", "find-generic-password", "-s", service, "-a", key, "-w")
+	case "linux":
+		out, err = runCommand(ctx, "secret-tool", "lookup", "service", service, "username", key)
+	default:
+		return "", fmt.Errorf("keyring is not supported on %s", runtime.GOOS)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
@@ -0,0 +1,119 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// LiteralPrefix escapes values which would otherwise be resolved as secret references
+const LiteralPrefix = "literal:"
+
+// Reference is a parsed secret reference of the form <scheme>://<path>[#<key>]
+type Reference struct {
+	Scheme string
+	Path   string
+	Key    string
+}
+
+func (r Reference) String() string {
+	if r.Key == "" {
+		return r.Scheme + "://" + r.Path
+	}
+	return r.Scheme + "://" + r.Path + "#" + r.Key
+}
+
+// Provider resolves the secret references of one scheme
+type Provider interface {
+	Resolve(ctx context.Context, ref Reference) (string, error)
+}
+
+// ProviderFunc adapts a function to the Provider interface
+type ProviderFunc func(ctx context.Context, ref Reference) (string, error)
+
+func (f ProviderFunc) Resolve(ctx context.Context, ref Reference) (string, error) {
+	return f(ctx, ref)
+}
+
+// Resolver replaces secret references in env var values with the secret values.
+// Supported references:
+//
+//	file:///path/to/secret         content of the file, trailing line breaks are removed
+//	env://OTHER_VAR                value of an OS env var
+//	sops://secrets.enc.yaml#KEY    value of KEY in a sops encrypted file, decrypted with the sops CLI (age or PGP key)
+//	keyring://service/key          secret stored in the OS keyring (secret-tool on Linux, security on macOS)
+//	vault://secret/data/app#KEY    field KEY of a Vault secret, read with VAULT_ADDR and VAULT_TOKEN
+//
+// Values not starting with a registered scheme are returned unchanged. Values starting with literal: are never
+// resolved, the prefix is removed (literal:file:///data is the value file:///data). Resolved references are cached,
+// so a secret shared by several agents is read once.
+type Resolver struct {
+	providers map[string]Provider
+	resolved  map[string]string
+}
+
+// NewResolver creates a resolver with the default providers registered
+func NewResolver() *Resolver {
+	r := &Resolver{
+		providers: make(map[string]Provider),
+		resolved:  make(map[string]string),
+	}
+	r.Register("file", ProviderFunc(resolveFile))
+	r.Register("env", ProviderFunc(resolveEnv))
+	r.Register("sops", ProviderFunc(resolveSops))
+	r.Register("keyring", ProviderFunc(resolveKeyring))
+	r.Register("vault", NewVaultProvider())
+	return r
+}
+
+// Register adds or replaces the provider for the scheme
+func (r *Resolver) Register(scheme string, provider Provider) {
+	r.providers[scheme] = provider
+}
+
+// ParseReference parses value as secret reference, ok is false if the value does not start with a registered scheme
+// or is escaped with LiteralPrefix
+func (r *Resolver) ParseReference(value string) (ref Reference, ok bool) {
+	scheme, rest, found := strings.Cut(value, "://")
+	if !found {
+		return Reference{}, false
+	}
+	if _, registered := r.providers[scheme]; !registered {
+		return Reference{}, false
+	}
+	path, key, _ := strings.Cut(rest, "#")
+	return Reference{Scheme: scheme, Path: path, Key: key}, true
+}
+
+// IsReference returns true if the value is a secret reference
+func (r *Resolver) IsReference(value string) bool {
+	_, ok := r.ParseReference(value)
+	return ok
+}
+
+// Resolve returns the secret value of a reference, the value without LiteralPrefix if it is escaped,
+// or the value itself if it is not a reference
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	if literal, found := strings.CutPrefix(value, LiteralPrefix); found {
+		return literal, nil
+	}
+	ref, ok := r.ParseReference(value)
+	if !ok {
+		return value, nil
+	}
+	if resolved, ok := r.resolved[value]; ok {
+		return resolved, nil
+	}
+	if ref.Path == "" {
+		return "", fmt.Errorf("invalid secret reference %s: path is missing", ref)
+	}
+
+	resolved, err := r.providers[ref.Scheme].Resolve(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve secret reference %s: %w", ref, err)
+	}
+	r.resolved[value] = resolved
+	return resolved, nil
+}
@@ -0,0 +1,125 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newVaultServer starts a stand-in of the Vault HTTP API serving a KV v2 and a KV v1 secret
+func newVaultServer(t *testing.T, token string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != token {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/app":
+			fmt.Fprint(w, `{"data": {"data": {"OPENAI_API_KEY": "sk-from-vault", "CONFIG": {"a": 1}}, "metadata": {"version": 1}}}`)
+		case "/v1/kv/app":
+			fmt.Fprint(w, `{"data": {"OPENAI_API_KEY": "sk-from-vault-v1"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	vault := newVaultServer(t, "test-token")
+	t.Setenv("VAULT_ADDR", vault.URL)
+	t.Setenv("VAULT_TOKEN", "test-token")
+	t.Setenv("WFSM_TEST_SECRET", "sk-from-env")
+
+	var commands []string
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		return []byte("sk-from-" + name + "\n"), nil
+	}
+	t.Cleanup(func() { runCommand = defaultRunCommand })
+
+	tests := []struct {
+		value   string
+		want    string
+		wantErr string
+	}{
+		{value: "plain value", want: "plain value"},
+		{value: "https://example.com/not-a-secret", want: "https://example.com/not-a-secret"},
+		{value: "file://test/api_key.txt", want: "sk-from-file"},
+		{value: "file://test/missing.txt", wantErr: "failed to resolve secret reference file://test/missing.txt"},
+		{value: "env://WFSM_TEST_SECRET", want: "sk-from-env"},
+		{value: "env://WFSM_TEST_MISSING", wantErr: "env var WFSM_TEST_MISSING is not set"},
+		{value: "env://", wantErr: "path is missing"},
+		{value: "sops://secrets.enc.yaml#OPENAI_API_KEY", want: "sk-from-sops"},
+		{value: "vault://secret/data/app#OPENAI_API_KEY", want: "sk-from-vault"},
+		{value: "vault://secret/data/app#CONFIG", want: `{"a":1}`},
+		{value: "vault://kv/app#OPENAI_API_KEY", want: "sk-from-vault-v1"},
+		{value: "vault://secret/data/app#MISSING", wantErr: "key MISSING not found in secret secret/data/app"},
+		{value: "vault://secret/data/other#KEY", wantErr: "404 Not Found"},
+		{value: "vault://secret/data/app", wantErr: "vault references must have the form vault://path#key"},
+		{value: "keyring://service", wantErr: "keyring references must have the form keyring://service/key"},
+		{value: "literal:file:///data", want: "file:///data"},
+		{value: "literal:env://WFSM_TEST_MISSING", want: "env://WFSM_TEST_MISSING"},
+		{value: "literal:literal:x", want: "literal:x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := NewResolver().Resolve(context.Background(), tt.value)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	assert.Equal(t, []string{`sops --decrypt --extract ["OPENAI_API_KEY"] secrets.enc.yaml`}, commands)
+}
+
+func TestResolver_Keyring(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("keyring is not supported on " + runtime.GOOS)
+	}
+	var command string
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		command = name + " " + strings.Join(args, " ")
+		return []byte("sk-from-keyring\n"), nil
+	}
+	t.Cleanup(func() { runCommand = defaultRunCommand })
+
+	got, err := NewResolver().Resolve(context.Background(), "keyring://wfsm/openai")
+	assert.NoError(t, err)
+	assert.Equal(t, "sk-from-keyring", got)
+	if runtime.GOOS == "linux" {
+		assert.Equal(t, "secret-tool lookup service wfsm username openai", command)
+	} else {
+		assert.Equal(t, "security find-generic-password -s wfsm -a openai -w", command)
+	}
+}
+
+func TestResolver_Caches_Resolved_Values(t *testing.T) {
+	calls := 0
+	resolver := NewResolver()
+	resolver.Register("test", ProviderFunc(func(ctx context.Context, ref Reference) (string, error) {
+		calls++
+		return ref.Path + "/" + ref.Key, nil
+	}))
+
+	for i := 0; i < 2; i++ {
+		got, err := resolver.Resolve(context.Background(), "test://path#key")
+		assert.NoError(t, err)
+		assert.Equal(t, "path/key", got)
+	}
+	assert.Equal(t, 1, calls)
+	assert.True(t, resolver.IsReference("test://other"))
+	assert.False(t, resolver.IsReference("unknown://other"))
+}
@@ -0,0 +1,131 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const vaultRequestTimeout = 30 * time.Second
+
+// VaultProvider reads vault://path#key references with the Vault HTTP API.
+// The path is the API path of the secret below /v1, e.g. secret/data/app for the KV v2 secret app
+// in the secret mount. Both KV v1 and KV v2 responses are supported.
+type VaultProvider struct {
+	// Address of the Vault server, defaults to VAULT_ADDR
+	Address string
+	// Token used for authentication, defaults to VAULT_TOKEN or the content of ~/.vault-token
+	Token string
+	// Namespace for Vault Enterprise, defaults to VAULT_NAMESPACE
+	Namespace string
+
+	httpClient *http.Client
+}
+
+func NewVaultProvider() *VaultProvider {
+	return &VaultProvider{
+		httpClient: &http.Client{Timeout: vaultRequestTimeout},
+	}
+}
+
+type vaultResponse struct {
+	Data map[string]any `json:"data"`
+}
+
+func (v *VaultProvider) Resolve(ctx context.Context, ref Reference) (string, error) {
+	if ref.Key == "" {
+		return "", errors.New("vault references must have the form vault://path#key")
+	}
+	address := v.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if address == "" {
+		return "", errors.New("VAULT_ADDR is not set")
+	}
+	token, err := v.token()
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(address, "/")+"/v1/"+strings.TrimPrefix(ref.Path, "/"), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+	namespace := v.Namespace
+	if namespace == "" {
+		namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	if namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	httpClient := v.httpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	var secret vaultResponse
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", fmt.Errorf("invalid response: %v", err)
+	}
+	data := secret.Data
+	// KV v2 wraps the secret data and its metadata
+	if nested, ok := data["data"].(map[string]any); ok {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			data = nested
+		}
+	}
+
+	value, ok := data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found in secret %s", ref.Key, ref.Path)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+func (v *VaultProvider) token() (string, error) {
+	if v.Token != "" {
+		return v.Token, nil
+	}
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err == nil {
+		if data, err := os.ReadFile(filepath.Join(homeDir, ".vault-token")); err == nil {
+			return strings.TrimSpace(string(data)), nil
+		}
+	}
+	return "", errors.New("VAULT_TOKEN is not set")
+}
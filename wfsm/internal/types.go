@@ -24,6 +24,19 @@ type AgentSpec struct {
 	Port                     int
 	K8sConfig                K8sConfig
 	ManifestPath             string
+	// SecretEnvVars are the names of env vars marked secret in the manifest or config, see IsSecretEnvVar
+	SecretEnvVars []string
+	// InjectDependencyEnvVars disables the <DEP>_API_KEY, <DEP>_ID and <DEP>_ENDPOINT env vars of a dependency when set to false
+	InjectDependencyEnvVars map[string]bool
+	// Dockerfile is the path of the Dockerfile in the agent source the image of the agent is built from
+	// instead of the generated one, Dockerfile.wfsm is used if the agent source has one
+	Dockerfile string
+}
+
+// InjectsDependencyEnvVars returns true if the runner sets the <DEP>_* env vars of the dependency for the agent
+func (s AgentSpec) InjectsDependencyEnvVars(dependencyName string) bool {
+	inject, ok := s.InjectDependencyEnvVars[dependencyName]
+	return !ok || inject
 }
 
 type K8sConfig struct {
@@ -79,6 +92,7 @@ type MatchExpression struct {
 type Toleration struct {
 	Key      string `yaml:"key"`
 	Operator string `yaml:"operator"`
+	Value    string `yaml:"value,omitempty"`
 	Effect   string `yaml:"effect"`
 }
 
@@ -86,6 +100,9 @@ type AgentDeploymentBuildSpec struct {
 	AgentSpec
 	Image       string
 	ServiceName string
+	// SecretFiles is set if the entrypoint of the image exports the files mounted into /run/secrets as env vars,
+	// which is the case for images built by wfsm, but not for prebuilt images of docker deployments
+	SecretFiles bool
 }
 
 type DeploymentArtifact []byte
@@ -0,0 +1,226 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/cisco-eti/wfsm/internal"
+	"github.com/cisco-eti/wfsm/internal/builder"
+	"github.com/cisco-eti/wfsm/internal/builder/python"
+	"github.com/cisco-eti/wfsm/internal/buildreport"
+	"github.com/cisco-eti/wfsm/internal/util"
+	"github.com/cisco-eti/wfsm/internal/wfsm/manifest"
+)
+
+var buildLongHelp = `
+This command builds the images of an agent and its dependencies without deploying them and prints the image of each agent.
+Agents deployed from docker images are not built, their image is printed as is.
+Images are built for the platform of the host by default, with docker buildx for other platforms, or without a container engine
+with --daemonless. An SBOM and a build report of each image are stored in ~/.wfsm/images, 'wfsm inspect image' prints them.
+See the "Building images" section of docs/README.md for custom Dockerfiles, private package indexes, daemonless builds and SBOMs.
+
+Examples:
+- Build the images of an agent for the platform of the host:
+	wfsm build --manifestPath path/to/acpManifest
+- Build and push multi-arch images:
+	wfsm build --manifestPath path/to/acpManifest --platforms linux/amd64,linux/arm64 --push --registry ghcr.io/org
+- Build with a private package index:
+	wfsm build --manifestPath path/to/acpManifest --indexUrl https://pypi.example.com/simple --netrc ~/.netrc
+- Assemble an image without a container engine:
+	wfsm build --manifestPath path/to/acpManifest --daemonless --wheelhouse path/to/wheelhouse --output agent.tar
+`
+
+const buildFail = "Build Status: Failed - %s"
+const buildError string = "build failed"
+
+const imagePlatformsFlag string = "platforms"
+const pushFlag string = "push"
+const registryFlag string = "registry"
+const daemonlessFlag string = "daemonless"
+const wheelhouseFlag string = "wheelhouse"
+const indexURLFlag string = "indexUrl"
+const extraIndexURLFlag string = "extraIndexUrl"
+const netrcFlag string = "netrc"
+const buildConcurrencyFlag string = "build-concurrency"
+const sbomFormatFlag string = "sbomFormat"
+
+type BuildParams struct {
+	ManifestPath     string
+	AgentConfigPaths []string
+	Profile          string
+	DeploymentOption *string
+	Offline          bool
+	BuildConcurrency int
+	BuildOptions     python.BuildOptions
+}
+
+var buildCmd = &cobra.Command{
+	Use:   "build --manifestPath path/to/acpManifest",
+	Short: "Build the images of an ACP agent",
+	Long:  buildLongHelp,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manifestPath, _ := cmd.Flags().GetString(manifestPathFlag)
+		deploymentOption, _ := cmd.Flags().GetString(deploymentOptionFlag)
+		configPaths, _ := cmd.Flags().GetStringArray(configPathFlag)
+		profile, _ := cmd.Flags().GetString(profileFlag)
+		offline, _ := cmd.Flags().GetBool(offlineFlag)
+		baseImage, _ := cmd.Flags().GetString(baseImageFlag)
+		deleteBuildFolders, _ := cmd.Flags().GetBool(deleteBuildFoldersFlag)
+		forceBuild, _ := cmd.Flags().GetBool(forceBuild)
+		platforms, _ := cmd.Flags().GetStringSlice(imagePlatformsFlag)
+		push, _ := cmd.Flags().GetBool(pushFlag)
+		registry, _ := cmd.Flags().GetString(registryFlag)
+		daemonless, _ := cmd.Flags().GetBool(daemonlessFlag)
+		outputPath, _ := cmd.Flags().GetString(outputFlag)
+		wheelhouse, _ := cmd.Flags().GetString(wheelhouseFlag)
+		engineName, _ := cmd.Flags().GetString(engineFlag)
+		buildConcurrency, _ := cmd.Flags().GetInt(buildConcurrencyFlag)
+		sbomFormat, _ := cmd.Flags().GetString(sbomFormatFlag)
+		// daemonless images are not installed from package indexes, so the env vars of pip are not used
+		packageIndex := getPackageIndex(getContextWithLogger(cmd), cmd, !daemonless)
+
+		params := BuildParams{
+			ManifestPath:     manifestPath,
+			AgentConfigPaths: configPaths,
+			Profile:          profile,
+			DeploymentOption: &deploymentOption,
+			Offline:          offline,
+			BuildConcurrency: buildConcurrency,
+			BuildOptions: python.BuildOptions{
+				BaseImage:          baseImage,
+				DeleteBuildFolders: deleteBuildFolders,
+				ForceBuild:         forceBuild,
+				Platforms:          platforms,
+				Registry:           registry,
+				Push:               push,
+				Daemonless:         daemonless,
+				OutputPath:         outputPath,
+				Wheelhouse:         wheelhouse,
+				Engine:             engineName,
+				PackageIndex:       packageIndex,
+				SBOMFormat:         sbomFormat,
+			},
+		}
+
+		err := runBuild(getContextWithLogger(cmd), os.Stdout, params)
+		if err != nil {
+			util.OutputMessage(buildFail, err.Error())
+			return fmt.Errorf(CmdErrorHelpText, buildError)
+		}
+		return nil
+	},
+}
+
+func init() {
+	buildCmd.Flags().StringP(manifestPathFlag, "m", "", "Manifest file for the application")
+	buildCmd.Flags().StringP(deploymentOptionFlag, "o", "", "Deployment option to use from the manifest")
+	buildCmd.Flags().StringArrayP(configPathFlag, "c", nil, "User provided config file, can be repeated, later files override earlier ones")
+	buildCmd.Flags().String(profileFlag, "", "Profile to apply from the config files")
+	buildCmd.Flags().Bool(offlineFlag, false, "If set to true, remote manifests and agent sources are served only from the cache")
+	buildCmd.Flags().StringP(baseImageFlag, "b", "", "Base image to be used as the workflowserver for the agent, repo is at ghcr.io/agntcy/acp/wfsrv")
+	buildCmd.Flags().BoolP(deleteBuildFoldersFlag, "d", true, "Delete build folders after the build")
+	buildCmd.Flags().BoolP(forceBuild, "f", false, "If set to true, the build will be forced without build cache even if the image already exists")
+	buildCmd.Flags().StringSlice(imagePlatformsFlag, nil, "Platforms to build the images for with docker buildx, e.g. linux/amd64,linux/arm64, several platforms require --push")
+	buildCmd.Flags().Bool(pushFlag, false, "If set to true, the images are pushed to the registry, images already in the registry are not built again")
+	buildCmd.Flags().String(registryFlag, "", "Registry prepended to the image names, e.g. ghcr.io/org")
+	buildCmd.Flags().Bool(daemonlessFlag, false, "If set to true, the images are assembled without a container engine, they are pushed with --push or written to --output")
+	buildCmd.Flags().String(outputFlag, "", "OCI layout tarball daemonless images are written to")
+	buildCmd.Flags().String(wheelhouseFlag, "", "Dir of wheels of the agent and its dependencies added to daemonless images, required with --daemonless")
+	buildCmd.Flags().Int(buildConcurrencyFlag, builder.DefaultBuildConcurrency, "Number of agents built at the same time, the output of each agent is prefixed with its name")
+	buildCmd.Flags().String(sbomFormatFlag, buildreport.CycloneDX, "Format of the SBOMs of the images [cyclonedx, spdx], the reports folder can be overridden with WFSM_REPORTS_FOLDER env var")
+	addPackageIndexFlags(buildCmd, true)
+	buildCmd.MarkFlagRequired(manifestPathFlag)
+}
+
+func addPackageIndexFlags(cmd *cobra.Command, fromEnv bool) {
+	envDefault := func(envVar string) string {
+		if fromEnv {
+			return ", defaults to " + envVar
+		}
+		return ""
+	}
+	cmd.Flags().String(indexURLFlag, "", "Python package index the dependencies of the agents are installed from instead of PyPI"+envDefault(python.IndexURLEnv))
+	cmd.Flags().StringArray(extraIndexURLFlag, nil, "Additional python package index, can be repeated"+envDefault(python.ExtraIndexURLEnv))
+	cmd.Flags().String(netrcFlag, "", "Netrc file with the credentials of the package indexes"+envDefault(python.NetrcEnv))
+}
+
+// getPackageIndex returns the package index set by the flags, or by the env vars of pip if fromEnv is set and the flags are not set.
+// The env vars applied are logged, as they are easily set on the host for other purposes.
+func getPackageIndex(ctx context.Context, cmd *cobra.Command, fromEnv bool) python.PackageIndex {
+	log := zerolog.Ctx(ctx)
+
+	packageIndex := python.PackageIndex{}
+	if fromEnv {
+		packageIndex = python.PackageIndexFromEnv()
+	}
+	if cmd.Flags().Changed(indexURLFlag) {
+		packageIndex.IndexURL, _ = cmd.Flags().GetString(indexURLFlag)
+	} else if packageIndex.IndexURL != "" {
+		log.Info().Msgf("python package index set by %s", python.IndexURLEnv)
+	}
+	if cmd.Flags().Changed(extraIndexURLFlag) {
+		packageIndex.ExtraIndexURLs, _ = cmd.Flags().GetStringArray(extraIndexURLFlag)
+	} else if len(packageIndex.ExtraIndexURLs) > 0 {
+		log.Info().Msgf("additional python package indexes set by %s", python.ExtraIndexURLEnv)
+	}
+	if cmd.Flags().Changed(netrcFlag) {
+		packageIndex.NetrcPath, _ = cmd.Flags().GetString(netrcFlag)
+	} else if packageIndex.NetrcPath != "" {
+		log.Info().Msgf("credentials of the python package indexes read from %s set by %s", packageIndex.NetrcPath, python.NetrcEnv)
+	}
+	return packageIndex
+}
+
+func runBuild(ctx context.Context, w io.Writer, params BuildParams) error {
+	log := zerolog.Ctx(ctx)
+
+	if err := params.BuildOptions.Validate(); err != nil {
+		return err
+	}
+
+	agentSpecBuilder, err := loadAgentSpecs(ctx, DeployParams{
+		ManifestPath:     params.ManifestPath,
+		DeploymentOption: params.DeploymentOption,
+		Offline:          params.Offline,
+	})
+	if err != nil {
+		return err
+	}
+	if agentSpecBuilder.Lock.Changed() {
+		if err := agentSpecBuilder.Lock.Write(); err != nil {
+			return err
+		}
+		log.Info().Msgf("agent dependencies pinned in %s", agentSpecBuilder.Lock.Path())
+	}
+	params.BuildOptions.SrcCache = agentSpecBuilder.Cache
+	params.BuildOptions.Reports, err = getReportsStore()
+	if err != nil {
+		return err
+	}
+
+	// only the build config of the agents (e.g. their dockerfile) is used, env vars are not needed to build images
+	agentConfig, err := loadAgentConfig(agentSpecBuilder, manifest.EnvFile{}, params.AgentConfigPaths, params.Profile, internal.DOCKER)
+	if err != nil {
+		return err
+	}
+	agentSpecBuilder.LoadBuildConfig(agentConfig)
+
+	agDeploymentSpecs, err := builder.BuildAgents(ctx, agentSpecBuilder.AgentSpecs, params.BuildOptions, params.BuildConcurrency)
+	if err != nil {
+		return err
+	}
+
+	for _, depName := range slices.Sorted(maps.Keys(agDeploymentSpecs)) {
+		fmt.Fprintf(w, "%s: %s\n", depName, agDeploymentSpecs[depName].Image)
+	}
+	return nil
+}
@@ -11,17 +11,21 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 
+	"github.com/cisco-eti/wfsm/internal/engine"
 	"github.com/cisco-eti/wfsm/internal/executor"
 )
 
 const (
 	checkLongHelp = `
 This command checks the prerequisites on the host executing the wfsm command, 
-particularly for the docker and docker-compose comands
+particularly for the docker and docker-compose comands.
+With --engine podman, it checks podman and its compose provider (podman-compose) instead.
 		
 Examples:
 - Check the prerequisites for the host:
 	wfsm check
+- Check the prerequisites for deploying with podman:
+	wfsm check --engine podman
 `
 )
 
@@ -32,6 +36,7 @@ var checkCmd = &cobra.Command{
 	Long:  checkLongHelp,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		verbose, _ := cmd.Flags().GetBool(verboseChecksFlag)
+		engineName, _ := cmd.Flags().GetString(engineFlag)
 		logger := log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 		if verbose {
 			logger = logger.Level(zerolog.DebugLevel)
@@ -40,7 +45,7 @@ var checkCmd = &cobra.Command{
 		}
 
 		logger.Info().Msg("Checking prerequisites for the command...")
-		err := runChecks(verbose, logger)
+		err := runChecks(verbose, logger, engineName)
 		if err != nil {
 			logger.Error().Msg("Checking prerequisites failed")
 			return fmt.Errorf(CmdErrorHelpText, err)
@@ -50,13 +55,15 @@ var checkCmd = &cobra.Command{
 	},
 }
 
-func runChecks(verbose bool, logger zerolog.Logger) error {
+func runChecks(verbose bool, logger zerolog.Logger, engineName string) error {
 	executorService := executor.NewExecutorService(logger)
-	err := checkDocker(executorService)
-	if err != nil {
-		return err
+	switch engineName {
+	case engine.Docker, "":
+		return checkDocker(executorService)
+	case engine.Podman:
+		return checkPodman(executorService)
 	}
-	return nil
+	return engine.Validate(engineName)
 }
 
 func checkDocker(executorService executor.Executor) error {
@@ -71,3 +78,18 @@ func checkDocker(executorService executor.Executor) error {
 	}
 	return nil
 }
+
+// checkPodman checks podman and the compose provider running the agents
+func checkPodman(executorService executor.Executor) error {
+	for _, args := range [][]string{{"info"}, {"compose", "version"}} {
+		_, err := executorService.Execute(context.Background(), executor.Command{
+			WorkDir: os.TempDir(),
+			Command: "podman",
+			Args:    args,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
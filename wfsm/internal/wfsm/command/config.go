@@ -0,0 +1,231 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/cisco-eti/wfsm/internal/util"
+	"github.com/cisco-eti/wfsm/internal/wfsm/config"
+	"github.com/cisco-eti/wfsm/internal/wfsm/manifest"
+)
+
+var configValidateLongHelp = `
+This command validates config files without deploying anything.
+
+Config files are decoded strictly: unknown or misplaced fields (e.g. envVarsFromSecret outside of the k8s config of an agent) are errors.
+If a manifest is given, the config files are also merged with the default config of its agents, which fails
+if the config files configure agents which are not part of the deployment.
+
+Examples:
+- Validate layered config files with a profile against the agents of a manifest:
+	wfsm config validate --configPath config.yaml --configPath config.prod.yaml --profile prod --manifestPath path/to/acpManifest
+`
+
+var configInitLongHelp = `
+This command writes the default config of the agents of a manifest to a config file, as used by 'wfsm deploy' when no config file is given.
+The IDs, API keys and ports of the agents are taken from the env file and the OS env (e.g. MAILCOMPOSER_API_KEY), or generated.
+
+The file references the JSON Schema of config files, so editors supporting yaml-language-server modelines can validate and autocomplete it.
+
+Examples:
+- Write the default config of the agents for a k8s deployment:
+	wfsm config init --manifestPath path/to/acpManifest --output config.yaml --platform k8s
+`
+
+var configSchemaLongHelp = `
+This command prints the JSON Schema of config files, which is also published as wfsm/spec/config_schema.json in the repository.
+`
+
+const configFail = "Config Status: Failed - %s"
+const configError string = "config failed"
+
+const outputFlag string = "output"
+const forceFlag string = "force"
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage agent config files",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate --configPath path/to/configFile",
+	Short: "Validate config files",
+	Long:  configValidateLongHelp,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPaths, _ := cmd.Flags().GetStringArray(configPathFlag)
+		profile, _ := cmd.Flags().GetString(profileFlag)
+		manifestPath, _ := cmd.Flags().GetString(manifestPathFlag)
+		envFilePath, _ := cmd.Flags().GetString(envFilePathFlag)
+		deploymentOption, _ := cmd.Flags().GetString(deploymentOptionFlag)
+		platform, _ := cmd.Flags().GetString(platformsFlag)
+		offline, _ := cmd.Flags().GetBool(offlineFlag)
+
+		params := DeployParams{
+			ManifestPath:     manifestPath,
+			EnvFilePath:      envFilePath,
+			AgentConfigPaths: configPaths,
+			Profile:          profile,
+			Platform:         platform,
+			DeploymentOption: &deploymentOption,
+			Offline:          offline,
+		}
+		err := runConfigValidate(getContextWithLogger(cmd), params)
+		if err != nil {
+			util.OutputMessage(configFail, err.Error())
+			return fmt.Errorf(CmdErrorHelpText, configError)
+		}
+		return nil
+	},
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init --manifestPath path/to/acpManifest --output path/to/configFile",
+	Short: "Write the default config of the agents to a config file",
+	Long:  configInitLongHelp,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manifestPath, _ := cmd.Flags().GetString(manifestPathFlag)
+		envFilePath, _ := cmd.Flags().GetString(envFilePathFlag)
+		deploymentOption, _ := cmd.Flags().GetString(deploymentOptionFlag)
+		platform, _ := cmd.Flags().GetString(platformsFlag)
+		offline, _ := cmd.Flags().GetBool(offlineFlag)
+		output, _ := cmd.Flags().GetString(outputFlag)
+		force, _ := cmd.Flags().GetBool(forceFlag)
+
+		params := DeployParams{
+			ManifestPath:     manifestPath,
+			EnvFilePath:      envFilePath,
+			Platform:         platform,
+			DeploymentOption: &deploymentOption,
+			Offline:          offline,
+		}
+		err := runConfigInit(getContextWithLogger(cmd), params, output, force)
+		if err != nil {
+			util.OutputMessage(configFail, err.Error())
+			return fmt.Errorf(CmdErrorHelpText, configError)
+		}
+		return nil
+	},
+}
+
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of config files",
+	Long:  configSchemaLongHelp,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := runConfigSchema(os.Stdout); err != nil {
+			util.OutputMessage(configFail, err.Error())
+			return fmt.Errorf(CmdErrorHelpText, configError)
+		}
+		return nil
+	},
+}
+
+func init() {
+	configValidateCmd.Flags().StringArrayP(configPathFlag, "c", nil, "Config file to validate, can be repeated to validate layered config files")
+	configValidateCmd.Flags().String(profileFlag, "", "Profile to apply from the config files")
+	configValidateCmd.Flags().StringP(manifestPathFlag, "m", "", "Manifest file for the application, if set the agents of the config files are checked")
+	configValidateCmd.Flags().StringP(envFilePathFlag, "e", "", "User provided environment file")
+	configValidateCmd.Flags().StringP(deploymentOptionFlag, "o", "", "Deployment option to use from the manifest")
+	configValidateCmd.Flags().Bool(offlineFlag, false, "If set to true, remote manifests are served only from the cache")
+	configValidateCmd.MarkFlagRequired(configPathFlag)
+
+	configInitCmd.Flags().StringP(manifestPathFlag, "m", "", "Manifest file for the application")
+	configInitCmd.Flags().StringP(envFilePathFlag, "e", "", "User provided environment file")
+	configInitCmd.Flags().StringP(deploymentOptionFlag, "o", "", "Deployment option to use from the manifest")
+	configInitCmd.Flags().Bool(offlineFlag, false, "If set to true, remote manifests are served only from the cache")
+	configInitCmd.Flags().String(outputFlag, "config.yaml", "Config file to write")
+	configInitCmd.Flags().Bool(forceFlag, false, "If set to true, an existing config file is overwritten")
+	configInitCmd.MarkFlagRequired(manifestPathFlag)
+
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configInitCmd)
+	configCmd.AddCommand(configSchemaCmd)
+}
+
+func runConfigValidate(ctx context.Context, params DeployParams) error {
+	log := zerolog.Ctx(ctx)
+
+	if params.ManifestPath == "" {
+		if _, err := config.LoadConfig(params.AgentConfigPaths, params.Profile); err != nil {
+			return err
+		}
+		log.Info().Msg("config is valid")
+		return nil
+	}
+
+	agentSpecBuilder, err := loadAgentSpecs(ctx, params)
+	if err != nil {
+		return err
+	}
+	envFile, err := manifest.LoadEnvFile(params.EnvFilePath)
+	if err != nil {
+		return err
+	}
+	if _, err := loadAgentConfig(agentSpecBuilder, envFile, params.AgentConfigPaths, params.Profile, params.Platform); err != nil {
+		return err
+	}
+	log.Info().Msgf("config is valid for the agents of %s", agentSpecBuilder.DeploymentName)
+	return nil
+}
+
+func runConfigInit(ctx context.Context, params DeployParams, output string, force bool) error {
+	log := zerolog.Ctx(ctx)
+
+	if _, err := os.Stat(output); err == nil && !force {
+		return fmt.Errorf("config file %s already exists, use --%s to overwrite it", output, forceFlag)
+	}
+
+	agentSpecBuilder, err := loadAgentSpecs(ctx, params)
+	if err != nil {
+		return err
+	}
+	envFile, err := manifest.LoadEnvFile(params.EnvFilePath)
+	if err != nil {
+		return err
+	}
+	agentConfig, err := config.GenerateDefaultConfig(agentSpecBuilder.AgentSpecs, params.Platform, agentSpecBuilder.DeploymentName, envFile.Values)
+	if err != nil {
+		return fmt.Errorf("failed to generate default agent config: %v", err)
+	}
+	if err := config.WriteConfig(output, agentConfig); err != nil {
+		return err
+	}
+	log.Info().Msgf("config written to %s", output)
+	return nil
+}
+
+func runConfigSchema(w io.Writer) error {
+	schema, err := config.Schema()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(schema)
+	return err
+}
+
+// loadAgentSpecs resolves the agent specs of the manifest the same way as for a deployment, but the lock file is left untouched
+func loadAgentSpecs(ctx context.Context, params DeployParams) (*manifest.AgentSpecBuilder, error) {
+	artifactCache, err := getCache(params.Offline)
+	if err != nil {
+		return nil, err
+	}
+	lockFile, err := manifest.LoadLockFile(manifest.GetLockFilePath(params.ManifestPath))
+	if err != nil {
+		return nil, err
+	}
+
+	agentSpecBuilder := manifest.NewAgentSpecBuilder()
+	agentSpecBuilder.Cache = artifactCache
+	agentSpecBuilder.Lock = lockFile
+	if err := agentSpecBuilder.BuildAgentSpec(ctx, params.ManifestPath, "", params.DeploymentOption, nil); err != nil {
+		return nil, err
+	}
+	return agentSpecBuilder, nil
+}
@@ -15,8 +15,11 @@ import (
 	"github.com/cisco-eti/wfsm/internal/platforms"
 	"github.com/cisco-eti/wfsm/internal/wfsm/config"
 
-	"github.com/cisco-eti/wfsm/internal"
 	"github.com/cisco-eti/wfsm/internal/builder"
+	"github.com/cisco-eti/wfsm/internal/builder/python"
+	"github.com/cisco-eti/wfsm/internal/buildreport"
+	"github.com/cisco-eti/wfsm/internal/cache"
+	"github.com/cisco-eti/wfsm/internal/engine"
 	"github.com/cisco-eti/wfsm/internal/util"
 	"github.com/cisco-eti/wfsm/internal/wfsm/manifest"
 )
@@ -26,17 +29,70 @@ This command takes two required flags: --manifestPath path/to/acpManifest
 
 Optional flags:
 	--envFilePath path/to/envConfigFile user provided environment file
-  --configPath path/to/configFile user provided config file
+  --configPath path/to/configFile user provided config file, can be repeated to layer several config files
+  --profile name of the profile to apply from the config files (e.g. dev, staging, prod)
   --showConfig if true, prints out config (defaults and user provided values merged together)
+	--reveal if set to true, API keys and secret env vars are not redacted in the config, the dry run output and the deployment summary.
 	--baseImage can be set to determine which base image is used as the workflowserver for the agent.
 	--deleteBuildFolders can be set to true or false to determine if the build folders should be deleted after deployment.
 	--deploymentOption can be set to determine which deployment option to use from the manifest. It defaults to the first deployment option.
 	--dryRun if set to true, the deployment will not be executed, instead deployment artifacts will be printed to the console.
-	--forceBuild can be set to true or false to determine if the build should be forced even if the image already exists.
+	--forceBuild can be set to true or false to determine if the build should be forced even if the image already exists,
+	  forced builds do not reuse the cached layers of earlier builds. Otherwise the dependencies of python agents
+	  (from uv.lock, poetry.lock, requirements.txt or pyproject.toml) are installed in a layer of their own,
+	  which is reused until they change. Files matching the patterns of the .wfsmignore file in the root of the agent source
+	  (or its .dockerignore file if there is no .wfsmignore) are neither copied into the image nor trigger rebuilds.
+	  Agents with a Dockerfile.wfsm in the root of their source (or the one set by 'dockerfile' in their config)
+	  are built from it instead of the generated Dockerfile, see 'wfsm build --help'.
+	--indexUrl, --extraIndexUrl and --netrc set the private package indexes the dependencies of python agents are installed from,
+	  they are passed to the build as BuildKit secrets, see 'wfsm build --help'. Unlike 'wfsm build', deploy does not use
+	  the PIP_INDEX_URL, PIP_EXTRA_INDEX_URL and NETRC env vars of the host.
+	--build-concurrency number of agents built at the same time (default 4), the output of each build is prefixed with the name of the agent.
+	  The first failing build cancels the others.
+	--sbomFormat format of the SBOMs of the built images [cyclonedx, spdx] (default cyclonedx). Built images are labelled
+	  with the source, revision and version of the agent and the name, version and digest of its manifest,
+	  their SBOM is stored with a build report in ~/.wfsm/images, see 'wfsm build --help' and 'wfsm inspect image --help'.
 	--platform specify the platform to deploy the agent(s) to [docker, k8s].
+	--engine specify the container engine building the images and running the agent(s) on the docker platform [docker, podman].
+	  Podman builds the images through its API socket (found in $XDG_RUNTIME_DIR/podman, /run/podman, via podman machine,
+	  or set by CONTAINER_HOST) and runs the agents with podman-compose, or 'podman compose' if podman-compose is not installed.
+	--offline if set to true, remote manifests and agent sources are served only from the cache (~/.wfsm/cache).
   --namespace specify the namespace to deploy the agent(s) to. This is only used for k8s deployments.
 
-Env config file should be a yaml file in the format of 'EnvVarValues' (see manifest format).
+Manifests referenced by http(s) urls are fetched using the settings of the credentials file
+(~/.wfsm/credentials.yaml, can be overridden with WFSM_CREDENTIALS_FILE env var), where bearer tokens,
+basic auth, custom headers, CA bundles, proxies and timeouts can be set per host.
+Credentials can also be set with WFSM_HTTP_TOKEN_<HOST>, WFSM_HTTP_USERNAME_<HOST> and WFSM_HTTP_PASSWORD_<HOST> env vars
+(e.g. WFSM_HTTP_TOKEN_ARTIFACTS_EXAMPLE_COM).
+
+Remote agent dependencies are pinned by digest in the wfsm.lock file next to the manifest, which is created on the first deployment.
+Run 'wfsm deps update' to resolve the dependencies again, e.g. to pick up new versions matching the version constraints.
+
+Several config files can be given by repeating --configPath, they are deep merged in order onto the default config:
+maps (e.g. envVars, labels) are merged key by key, other values and lists replace the values of earlier files,
+except secretEnvVars which are combined. A null value clears the value set by the default config or an earlier file.
+A config file can define profiles, which override its config when selected with --profile:
+
+config:
+  mailcomposer:
+    envVars:
+      LOG_LEVEL: info
+profiles:
+  dev:
+    mailcomposer:
+      envVars:
+        LOG_LEVEL: debug
+      k8s:
+        statefulset:
+          resources: null
+
+Config files are decoded strictly, unknown or misplaced fields and agents which are not part of the deployment are errors.
+Run 'wfsm config validate' to check config files and 'wfsm config init' to write the default config of the agents as a starting point,
+the JSON Schema of config files is printed by 'wfsm config schema'.
+
+Env config files with .yaml or .yml extension are in the format of 'EnvVarValues' (see manifest format).
+Top level values apply to the main agent, values under dependencies (or env_deps) to the dependency with the given name,
+and nested dependencies to the dependencies of that agent.
 Example:
 
 values:
@@ -45,7 +101,57 @@ dependencies:
   - name: <agent_dependency_name>
     values:
       ENV_VAR_2: "sample value 2"
-		
+    env_deps:
+      - name: <dependency_of_agent_dependency_name>
+        values:
+          ENV_VAR_3: "sample value 3"
+
+Other env config files are dotenv files with KEY=VALUE lines. Values apply to every agent declaring the env var in its manifest,
+keys prefixed with the deployment name of an agent (e.g. MAILCOMPOSER_OPENAI_API_KEY) apply to that agent only.
+Values can be single or double quoted (quoted values can span multiple lines, e.g. PEM keys or JSON), lines can start with 'export ',
+and ${VAR}, ${VAR:-default} and $VAR references are expanded from earlier lines and the OS environment (not in single quotes).
+
+API_KEY, the <DEP>_API_KEY env vars of dependencies and any other *_API_KEY env var are secret, other env vars can be marked secret
+with the "org.agntcy.wfsm.secret": "true" annotation of the env var in the manifest or listed under secretEnvVars of the agent in the config file.
+Secret values are redacted in the printed config, dry run output and deployment summary unless --reveal is set,
+on k8s they are stored in the Secret of the agent instead of its ConfigMap.
+On docker, for agent images built by wfsm, they are written to files readable by the owner only (<host storage folder>/secrets-<compose project name>/),
+mounted as compose secrets into /run/secrets and exported as env vars by the entrypoint of the image when the agent starts,
+so they do not show up in 'docker inspect'. Prebuilt images of docker deployments get them in the compose environment,
+as their entrypoint does not read /run/secrets. Set WFSM_DOCKER_SECRETS=env to pass them in the compose environment for all agents.
+
+Env vars are validated after merging, before anything is built. Besides required env vars, the values of env vars
+declared in the manifest can be constrained with annotations of the env var:
+	"org.agntcy.wfsm.type": "int"        type of the value: string (default), int, bool, url or json
+	"org.agntcy.wfsm.pattern": "gpt-.*"  regular expression matching the whole value
+	"org.agntcy.wfsm.enum": "debug,info" comma separated list of allowed values
+	"org.agntcy.wfsm.min": "1"           minimum of int values, or minimum length of other values
+	"org.agntcy.wfsm.max": "10"          maximum of int values, or maximum length of other values
+Errors of all agents are reported together.
+
+Env var values and API keys in env files and config files can be secret references, resolved before the agents are deployed:
+	file:///path/to/secret         content of a file
+	env://OTHER_VAR                value of another OS env var
+	sops://secrets.enc.yaml#KEY    value of KEY in a sops encrypted file (decrypted with the sops CLI using your age or PGP key)
+	keyring://service/key          secret stored in the OS keyring
+	vault://secret/data/app#KEY    field KEY of a Vault secret (using VAULT_ADDR and VAULT_TOKEN)
+Values which look like a reference but must be passed as they are are escaped with literal:, e.g. literal:file:///data
+is passed as file:///data.
+
+Env var values can reference the direct dependencies of the agent and the agent itself with template expressions,
+interpolated for the target platform before deployment:
+	{{ deps.<dependency>.endpoint }}  url of the dependency (also apiKey, id, host and port)
+	{{ self.endpoint }}               url of the agent itself (also apiKey, id, host and port)
+Env vars referencing an apiKey are secret. The <DEP>_ENDPOINT, <DEP>_ID and <DEP>_API_KEY env vars injected for
+a dependency can be turned off in the config of the agent, e.g. to pass its values under other names:
+
+config:
+  mailcomposer:
+    envVars:
+      REVIEWER_URL: "{{ deps.email_reviewer_1.endpoint }}/api"
+    injectDependencyEnvVars:
+      email_reviewer_1: false
+
 Examples:
 - Build an agent with a manifest and environment file:
 	wfsm deploy --manifestPath path/to/acpManifest --envFilePath path/to/envConfigFile
@@ -63,11 +169,15 @@ const envFilePathFlag string = "envFilePath"
 const forceBuild string = "forceBuild"
 const manifestPathFlag string = "manifestPath"
 const configPathFlag string = "configPath"
+const profileFlag string = "profile"
+const offlineFlag string = "offline"
+const revealFlag string = "reveal"
 
 type DeployParams struct {
 	ManifestPath       string
 	EnvFilePath        string
-	AgentConfigPath    string
+	AgentConfigPaths   []string
+	Profile            string
 	Platform           string
 	DryRun             bool
 	ShowConfig         bool
@@ -75,6 +185,12 @@ type DeployParams struct {
 	ForceBuild         bool
 	BaseImage          string
 	DeploymentOption   *string
+	Offline            bool
+	Reveal             bool
+	Engine             string
+	PackageIndex       python.PackageIndex
+	BuildConcurrency   int
+	SBOMFormat         string
 }
 
 // deployCmd represents the image build and run docker commands
@@ -90,15 +206,24 @@ var deployCmd = &cobra.Command{
 		dryRun, _ := cmd.Flags().GetBool(dryRunFlag)
 		showConfig, _ := cmd.Flags().GetBool(showConfigFlag)
 		envFilePath, _ := cmd.Flags().GetString(envFilePathFlag)
-		configPathFlag, _ := cmd.Flags().GetString(configPathFlag)
+		configPaths, _ := cmd.Flags().GetStringArray(configPathFlag)
+		profile, _ := cmd.Flags().GetString(profileFlag)
 		forceBuild, _ := cmd.Flags().GetBool(forceBuild)
 		manifestPath, _ := cmd.Flags().GetString(manifestPathFlag)
 		platform, _ := cmd.Flags().GetString(platformsFlag)
+		offline, _ := cmd.Flags().GetBool(offlineFlag)
+		reveal, _ := cmd.Flags().GetBool(revealFlag)
+		engineName, _ := cmd.Flags().GetString(engineFlag)
+		buildConcurrency, _ := cmd.Flags().GetInt(buildConcurrencyFlag)
+		sbomFormat, _ := cmd.Flags().GetString(sbomFormatFlag)
+		// only the flags set the package index, the env vars of pip on the host are not used by deploy
+		packageIndex := getPackageIndex(getContextWithLogger(cmd), cmd, false)
 
 		params := DeployParams{
 			ManifestPath:       manifestPath,
 			EnvFilePath:        envFilePath,
-			AgentConfigPath:    configPathFlag,
+			AgentConfigPaths:   configPaths,
+			Profile:            profile,
 			Platform:           platform,
 			DryRun:             dryRun,
 			ShowConfig:         showConfig,
@@ -106,6 +231,12 @@ var deployCmd = &cobra.Command{
 			ForceBuild:         forceBuild,
 			BaseImage:          baseImage,
 			DeploymentOption:   &deploymentOption,
+			Offline:            offline,
+			Reveal:             reveal,
+			Engine:             engineName,
+			PackageIndex:       packageIndex,
+			BuildConcurrency:   buildConcurrency,
+			SBOMFormat:         sbomFormat,
 		}
 
 		err := runDeploy(getContextWithLogger(cmd), params)
@@ -124,9 +255,16 @@ func init() {
 	deployCmd.Flags().BoolP(dryRunFlag, "r", true, "By default set to true, meaning the deployment artifacts are generated, but not executed")
 	deployCmd.Flags().BoolP(showConfigFlag, "s", false, "If true, prints out config (defaults and user provided values merged together)")
 	deployCmd.Flags().StringP(envFilePathFlag, "e", "", "User provided environment file")
-	deployCmd.Flags().StringP(configPathFlag, "c", "", "User provided config file")
-	deployCmd.Flags().BoolP(forceBuild, "f", false, "If set to true, the build will be forced even if the image already exists")
+	deployCmd.Flags().StringArrayP(configPathFlag, "c", nil, "User provided config file, can be repeated, later files override earlier ones")
+	deployCmd.Flags().String(profileFlag, "", "Profile to apply from the config files")
+	deployCmd.Flags().BoolP(forceBuild, "f", false, "If set to true, the build will be forced without build cache even if the image already exists")
 	deployCmd.Flags().StringP(manifestPathFlag, "m", "", "Manifest file for the application")
+	deployCmd.Flags().Bool(offlineFlag, false, "If set to true, remote manifests and agent sources are served only from the cache")
+	deployCmd.Flags().Bool(revealFlag, false, "If set to true, API keys and secret env vars are shown in the output instead of being redacted")
+	deployCmd.Flags().Int(buildConcurrencyFlag, builder.DefaultBuildConcurrency, "Number of agents built at the same time")
+	deployCmd.Flags().String(sbomFormatFlag, buildreport.CycloneDX, "Format of the SBOMs of the images [cyclonedx, spdx]")
+
+	addPackageIndexFlags(deployCmd, false)
 
 	deployCmd.MarkFlagRequired(manifestPathFlag)
 }
@@ -134,11 +272,42 @@ func init() {
 func runDeploy(ctx context.Context, params DeployParams) error {
 	log := zerolog.Ctx(ctx)
 
+	if err := engine.Validate(params.Engine); err != nil {
+		return err
+	}
+	if params.SBOMFormat != "" {
+		if err := buildreport.ValidateSBOMFormat(params.SBOMFormat); err != nil {
+			return err
+		}
+	}
+
+	artifactCache, err := getCache(params.Offline)
+	if err != nil {
+		return err
+	}
+	reports, err := getReportsStore()
+	if err != nil {
+		return err
+	}
+
+	lockFile, err := manifest.LoadLockFile(manifest.GetLockFilePath(params.ManifestPath))
+	if err != nil {
+		return err
+	}
+
 	agentSpecBuilder := manifest.NewAgentSpecBuilder()
-	err := agentSpecBuilder.BuildAgentSpec(ctx, params.ManifestPath, "", params.DeploymentOption, nil)
+	agentSpecBuilder.Cache = artifactCache
+	agentSpecBuilder.Lock = lockFile
+	err = agentSpecBuilder.BuildAgentSpec(ctx, params.ManifestPath, "", params.DeploymentOption, nil)
 	if err != nil {
 		return err
 	}
+	if lockFile.Changed() {
+		if err := lockFile.Write(); err != nil {
+			return err
+		}
+		log.Info().Msgf("agent dependencies pinned in %s", lockFile.Path())
+	}
 
 	hostStorageFolder, err := getHostStorageFolder(agentSpecBuilder.DeploymentName)
 	if err != nil {
@@ -146,28 +315,18 @@ func runDeploy(ctx context.Context, params DeployParams) error {
 	}
 
 	// load env vars from env file
-	envFile, err := manifest.LoadEnvVars(params.EnvFilePath)
+	envFile, err := manifest.LoadEnvFile(params.EnvFilePath)
 	if err != nil {
 		return err
 	}
 
-	// merge default agent config with user provided config
-	agentConfig, err := config.GenerateDefaultConfig(agentSpecBuilder.AgentSpecs, params.Platform, agentSpecBuilder.DeploymentName, envFile)
+	agentConfig, err := loadAgentConfig(agentSpecBuilder, envFile, params.AgentConfigPaths, params.Profile, params.Platform)
 	if err != nil {
-		return fmt.Errorf("failed to generate default agent config: %v", err)
-	}
-
-	if params.AgentConfigPath != "" {
-		// if no config is provided, generate a default one
-		userConfig, err := config.LoadConfig(params.AgentConfigPath)
-		if err != nil {
-			return fmt.Errorf("failed to load user config: %v", err)
-		}
-		agentConfig = config.MergeConfigs(agentConfig, userConfig, params.Platform)
+		return err
 	}
 
 	if params.ShowConfig {
-		err = config.PrintConfig(ctx, agentConfig)
+		err = config.PrintConfig(ctx, agentConfig, agentSpecBuilder.AgentSpecs, params.Reveal)
 		if err != nil {
 			return fmt.Errorf("failed to print config: %v", err)
 		}
@@ -175,33 +334,49 @@ func runDeploy(ctx context.Context, params DeployParams) error {
 
 	// load spec from config
 	// env vars are merged with the ones from the manifest and env file and OS env vars
-	agentSpecBuilder.LoadFromConfig(ctx, agentConfig, envFile)
-
-	errs := agentSpecBuilder.ValidateEnvVars(ctx)
-	if len(errs) > 0 {
-		// concatenate errors
-		errStr := ""
-		for _, err := range errs {
-			errStr += fmt.Sprintf("%s\n", err.Error())
-		}
-		return fmt.Errorf("failed validating env vars: %s", errStr)
+	// secret references in env var values and API keys are resolved
+	if err := agentSpecBuilder.LoadFromConfig(ctx, agentConfig, envFile); err != nil {
+		return fmt.Errorf("failed to load agent config: %v", err)
 	}
 
-	// run agent builder
-	agDeploymentSpecs := make(map[string]internal.AgentDeploymentBuildSpec, len(agentSpecBuilder.AgentSpecs))
-	for depName, agentSpec := range agentSpecBuilder.AgentSpecs {
-		deployment := manifest.GetDeployment(agentSpec.Manifest)
-		builder := builder.GetAgentBuilder(deployment.DeploymentOptions[agentSpec.SelectedDeploymentOption],
-			params.DeleteBuildFolders, params.ForceBuild, params.BaseImage)
-		agdbSpec, err := builder.Build(ctx, agentSpec)
-		if err != nil {
-			return fmt.Errorf("failed to build agent: %v", err)
-		}
-		agDeploymentSpecs[depName] = agdbSpec
+	if err := validateEnvVars(agentSpecBuilder.ValidateEnvVars(ctx)); err != nil {
+		return err
+	}
+	// check the template expressions in env var values before building the agents
+	previewSpecs := platforms.PreviewDeploymentSpecs(agentSpecBuilder.AgentSpecs)
+	if err := platforms.InterpolateEnvVars(params.Platform, previewSpecs, agentSpecBuilder.Dependencies); err != nil {
+		return fmt.Errorf("failed to interpolate env vars: %v", err)
+	}
+
+	// run agent builder, independent agents are built concurrently
+	agDeploymentSpecs, err := builder.BuildAgents(ctx, agentSpecBuilder.AgentSpecs, python.BuildOptions{
+		BaseImage:          params.BaseImage,
+		DeleteBuildFolders: params.DeleteBuildFolders,
+		ForceBuild:         params.ForceBuild,
+		Engine:             params.Engine,
+		PackageIndex:       params.PackageIndex,
+		SBOMFormat:         params.SBOMFormat,
+		Reports:            reports,
+		SrcCache:           artifactCache,
+	}, params.BuildConcurrency)
+	if err != nil {
+		return err
+	}
+
+	// interpolate template expressions in env var values with the values of the built agents and validate the results
+	if err := platforms.InterpolateEnvVars(params.Platform, agDeploymentSpecs, agentSpecBuilder.Dependencies); err != nil {
+		return fmt.Errorf("failed to interpolate env vars: %v", err)
+	}
+	var errs []error
+	for _, agdbSpec := range agDeploymentSpecs {
+		errs = append(errs, manifest.ValidateAgentEnvVars(agdbSpec.AgentSpec)...)
+	}
+	if err := validateEnvVars(errs); err != nil {
+		return err
 	}
 
 	// run deployment of agent(s)
-	runner := platforms.GetPlatformRunner(params.Platform, hostStorageFolder)
+	runner := platforms.GetPlatformRunner(params.Platform, params.Engine, hostStorageFolder, params.Reveal)
 
 	afs, err := runner.Deploy(ctx, agentSpecBuilder.DeploymentName, agDeploymentSpecs, agentSpecBuilder.Dependencies, params.DryRun)
 	if err != nil {
@@ -213,6 +388,40 @@ func runDeploy(ctx context.Context, params DeployParams) error {
 	return nil
 }
 
+// validateEnvVars concatenates the env var validation errors of the agents
+func validateEnvVars(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	errStr := ""
+	for _, err := range errs {
+		errStr += fmt.Sprintf("%s\n", err.Error())
+	}
+	return fmt.Errorf("failed validating env vars: %s", errStr)
+}
+
+// loadAgentConfig merges the default agent config with the user provided config files
+func loadAgentConfig(agentSpecBuilder *manifest.AgentSpecBuilder, envFile manifest.EnvFile, configPaths []string, profile string, platform string) (config.ConfigFile, error) {
+	agentConfig, err := config.GenerateDefaultConfig(agentSpecBuilder.AgentSpecs, platform, agentSpecBuilder.DeploymentName, envFile.Values)
+	if err != nil {
+		return config.ConfigFile{}, fmt.Errorf("failed to generate default agent config: %v", err)
+	}
+
+	if len(configPaths) > 0 {
+		userConfig, err := config.LoadConfig(configPaths, profile)
+		if err != nil {
+			return config.ConfigFile{}, fmt.Errorf("failed to load user config: %v", err)
+		}
+		agentConfig, err = config.MergeConfigs(agentConfig, userConfig, platform)
+		if err != nil {
+			return config.ConfigFile{}, fmt.Errorf("failed to merge user config: %v", err)
+		}
+	} else if profile != "" {
+		return config.ConfigFile{}, fmt.Errorf("profile %s requires a config file", profile)
+	}
+	return agentConfig, nil
+}
+
 func getHostStorageFolder(deploymentName string) (string, error) {
 	hostStorageFolder := os.Getenv("WFSM_HOST_STORAGE_FOLDER")
 	if hostStorageFolder == "" {
@@ -232,3 +441,27 @@ func getHostStorageFolder(deploymentName string) (string, error) {
 	}
 	return hostStorageFolder, nil
 }
+
+func getReportsStore() (*buildreport.Store, error) {
+	reportsFolder, err := buildreport.GetReportsFolder()
+	if err != nil {
+		return nil, err
+	}
+	return buildreport.NewStore(reportsFolder), nil
+}
+
+func getCache(offline bool) (*cache.Cache, error) {
+	cacheFolder, err := cache.GetCacheFolder()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(cacheFolder); os.IsNotExist(err) {
+		if offline {
+			return nil, errors.New("cache folder does not exist, run without --offline first to populate it")
+		}
+		if err := os.MkdirAll(cacheFolder, 0755); err != nil {
+			return nil, errors.New("failed to create cache folder")
+		}
+	}
+	return cache.NewCache(cacheFolder, offline), nil
+}
@@ -0,0 +1,98 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package command
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/cisco-eti/wfsm/internal/util"
+	"github.com/cisco-eti/wfsm/internal/wfsm/manifest"
+)
+
+var depsUpdateLongHelp = `
+This command resolves the agent dependencies of a manifest again and writes the result to the wfsm.lock file.
+
+The lock file pins the manifests of remote dependencies (http(s), hub and directory references) by digest.
+It is created by the first deployment and stored next to the manifest (or in the current directory for remote manifests).
+Later deployments use the pinned manifests and fail if a pinned manifest has changed, until the lock file is refreshed with this command.
+
+Dependencies can be referenced with a semantic version constraint (e.g. "version": "^1.2") and without url,
+in which case the newest matching version is looked up by name in the directory (DIRECTORY_URL env var),
+or with a hub url without digest (hub://host) to look up a version by name in the hub. The hub can't list the
+versions of an agent, so hub references need an exact version (e.g. "version": "1.2.3"), version ranges are rejected.
+
+Migrating manifests with versioned dependency urls:
+The version of dependencies referenced by url used to be ignored. It is now checked against the version of the loaded
+manifest, mismatches are logged as warnings and will fail deployments in a future release. Update the version of the
+references to a constraint the dependency satisfies (e.g. "^0.0.1"), or set WFSM_STRICT_VERSIONS=true to fail on
+mismatches already.
+
+Examples:
+- Refresh the lock file of a manifest:
+	wfsm deps update --manifestPath path/to/acpManifest
+`
+
+const depsUpdateFail = "Dependency Update Status: Failed - %s"
+const depsUpdateError string = "dependency update failed"
+
+var depsCmd = &cobra.Command{
+	Use:   "deps",
+	Short: "Manage agent dependencies",
+}
+
+var depsUpdateCmd = &cobra.Command{
+	Use:   "update --manifestPath path/to/acpManifest",
+	Short: "Resolve agent dependencies and refresh the lock file",
+	Long:  depsUpdateLongHelp,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manifestPath, _ := cmd.Flags().GetString(manifestPathFlag)
+		deploymentOption, _ := cmd.Flags().GetString(deploymentOptionFlag)
+
+		err := runDepsUpdate(getContextWithLogger(cmd), manifestPath, &deploymentOption)
+		if err != nil {
+			util.OutputMessage(depsUpdateFail, err.Error())
+			return fmt.Errorf(CmdErrorHelpText, depsUpdateError)
+		}
+		return nil
+	},
+}
+
+func init() {
+	depsUpdateCmd.Flags().StringP(manifestPathFlag, "m", "", "Manifest file for the application")
+	depsUpdateCmd.Flags().StringP(deploymentOptionFlag, "o", "", "Deployment option to use from the manifest")
+	depsUpdateCmd.MarkFlagRequired(manifestPathFlag)
+
+	depsCmd.AddCommand(depsUpdateCmd)
+}
+
+func runDepsUpdate(ctx context.Context, manifestPath string, deploymentOption *string) error {
+	log := zerolog.Ctx(ctx)
+
+	artifactCache, err := getCache(false)
+	if err != nil {
+		return err
+	}
+
+	// start from an empty lock file, so that every dependency is resolved again
+	lockFile := manifest.NewLockFile(manifest.GetLockFilePath(manifestPath))
+
+	agentSpecBuilder := manifest.NewAgentSpecBuilder()
+	agentSpecBuilder.Cache = artifactCache
+	agentSpecBuilder.Lock = lockFile
+	if err := agentSpecBuilder.BuildAgentSpec(ctx, manifestPath, "", deploymentOption, nil); err != nil {
+		return err
+	}
+
+	if err := lockFile.Write(); err != nil {
+		return err
+	}
+	for key, locked := range lockFile.Dependencies {
+		log.Info().Msgf("%s: %s %s (%s)", key, locked.Name, locked.ResolvedVersion, locked.Digest)
+	}
+	log.Info().Msgf("lock file written to %s", lockFile.Path())
+	return nil
+}
@@ -0,0 +1,119 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cisco-eti/wfsm/internal/util"
+	"github.com/cisco-eti/wfsm/internal/wfsm/envreport"
+	"github.com/cisco-eti/wfsm/internal/wfsm/manifest"
+)
+
+var envLongHelp = `
+This command resolves the env vars of an agent and its dependencies the same way as 'wfsm deploy',
+without building or deploying anything, and prints the final env of each agent.
+
+For each env var the layer which set the final value is shown, followed by the values it overrode.
+Layers in order of precedence (later layers override earlier ones):
+	manifest values      env var values passed to a dependency by the manifest of the dependent agent
+	OS env               OS env vars declared in the agent manifest
+	OS env (prefixed)    OS env vars prefixed with the deployment name of the agent (e.g. MAILCOMPOSER_OPENAI_API_KEY)
+	env file             env file values declared in the agent manifest
+	env file (prefixed)  env file values prefixed with the deployment name of the agent
+	env file (yaml)      values of the agent in a YAML env file
+	config               envVars of the agent in the config files (and the selected profile)
+	manifest default     default value in the agent manifest, used only if no other layer sets a value
+	runner               API_HOST, API_PORT, API_KEY, AGENT_ID and the <DEP>_API_KEY, <DEP>_ID, <DEP>_ENDPOINT env vars of dependencies
+
+Secret references are resolved and shown next to the value. Secret values are redacted unless --reveal is set.
+Required env vars without a value are listed per agent.
+
+Supported formats:
+	text  env vars of each agent (default)
+	json  env vars of each agent with their settings
+
+Examples:
+- Print the env of an agent deployed with an env file and a config file:
+	wfsm env --manifestPath path/to/acpManifest --envFilePath path/to/envConfigFile --configPath path/to/configFile
+`
+
+const envFail = "Env Status: Failed - %s"
+const envError string = "env failed"
+
+var envCmd = &cobra.Command{
+	Use:   "env --manifestPath path/to/acpManifest",
+	Short: "Print the resolved env vars of an ACP agent and their sources",
+	Long:  envLongHelp,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manifestPath, _ := cmd.Flags().GetString(manifestPathFlag)
+		envFilePath, _ := cmd.Flags().GetString(envFilePathFlag)
+		configPaths, _ := cmd.Flags().GetStringArray(configPathFlag)
+		profile, _ := cmd.Flags().GetString(profileFlag)
+		deploymentOption, _ := cmd.Flags().GetString(deploymentOptionFlag)
+		platform, _ := cmd.Flags().GetString(platformsFlag)
+		format, _ := cmd.Flags().GetString(formatFlag)
+		reveal, _ := cmd.Flags().GetBool(revealFlag)
+		offline, _ := cmd.Flags().GetBool(offlineFlag)
+
+		params := DeployParams{
+			ManifestPath:     manifestPath,
+			EnvFilePath:      envFilePath,
+			AgentConfigPaths: configPaths,
+			Profile:          profile,
+			Platform:         platform,
+			DeploymentOption: &deploymentOption,
+			Offline:          offline,
+			Reveal:           reveal,
+		}
+		err := runEnv(getContextWithLogger(cmd), os.Stdout, params, format)
+		if err != nil {
+			util.OutputMessage(envFail, err.Error())
+			return fmt.Errorf(CmdErrorHelpText, envError)
+		}
+		return nil
+	},
+}
+
+func init() {
+	envCmd.Flags().StringP(manifestPathFlag, "m", "", "Manifest file for the application")
+	envCmd.Flags().StringP(envFilePathFlag, "e", "", "User provided environment file")
+	envCmd.Flags().StringArrayP(configPathFlag, "c", nil, "User provided config file, can be repeated, later files override earlier ones")
+	envCmd.Flags().String(profileFlag, "", "Profile to apply from the config files")
+	envCmd.Flags().StringP(deploymentOptionFlag, "o", "", "Deployment option to use from the manifest")
+	envCmd.Flags().String(formatFlag, envreport.FormatText, "Output format: ["+strings.Join(envreport.Formats, ", ")+"]")
+	envCmd.Flags().Bool(revealFlag, false, "If set to true, API keys and secret env vars are shown instead of being redacted")
+	envCmd.Flags().Bool(offlineFlag, false, "If set to true, remote manifests are served only from the cache")
+	envCmd.MarkFlagRequired(manifestPathFlag)
+}
+
+func runEnv(ctx context.Context, w io.Writer, params DeployParams, format string) error {
+	agentSpecBuilder, err := loadAgentSpecs(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	envFile, err := manifest.LoadEnvFile(params.EnvFilePath)
+	if err != nil {
+		return err
+	}
+	agentConfig, err := loadAgentConfig(agentSpecBuilder, envFile, params.AgentConfigPaths, params.Profile, params.Platform)
+	if err != nil {
+		return err
+	}
+	if err := agentSpecBuilder.LoadFromConfig(ctx, agentConfig, envFile); err != nil {
+		return fmt.Errorf("failed to load agent config: %v", err)
+	}
+
+	report, err := envreport.NewReport(agentSpecBuilder, params.Platform, params.Reveal)
+	if err != nil {
+		return err
+	}
+	return report.Render(w, format)
+}
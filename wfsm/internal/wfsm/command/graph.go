@@ -0,0 +1,106 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cisco-eti/wfsm/internal"
+	"github.com/cisco-eti/wfsm/internal/util"
+	"github.com/cisco-eti/wfsm/internal/wfsm/graph"
+	"github.com/cisco-eti/wfsm/internal/wfsm/manifest"
+)
+
+var graphLongHelp = `
+This command resolves the agent dependencies of a manifest and prints the dependency tree.
+
+For each agent the chosen deployment option, the source type (docker, source code or remote) and the image are shown,
+for each dependency the env vars injected into the dependent agent (<DEP>_API_KEY, <DEP>_ID, <DEP>_ENDPOINT)
+and the env var values passed to the dependency by the manifest.
+
+The config files (--configPath, --profile) are read to show which dependency env vars are disabled with
+injectDependencyEnvVars and which env vars are secret. Secret values are redacted unless --reveal is set.
+
+Supported formats:
+	text     dependency tree (default)
+	dot      Graphviz DOT, e.g. wfsm graph -m manifest.json --format dot | dot -Tsvg > agents.svg
+	mermaid  Mermaid flowchart, can be embedded in markdown documents
+	json     nodes and edges of the graph
+
+Examples:
+- Print the dependency tree of an agent:
+	wfsm graph --manifestPath path/to/acpManifest
+- Generate a Mermaid diagram:
+	wfsm graph --manifestPath path/to/acpManifest --format mermaid
+`
+
+const graphFail = "Graph Status: Failed - %s"
+const graphError string = "graph failed"
+
+const formatFlag string = "format"
+
+var graphCmd = &cobra.Command{
+	Use:   "graph --manifestPath path/to/acpManifest",
+	Short: "Print the dependency graph of an ACP agent",
+	Long:  graphLongHelp,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manifestPath, _ := cmd.Flags().GetString(manifestPathFlag)
+		configPaths, _ := cmd.Flags().GetStringArray(configPathFlag)
+		profile, _ := cmd.Flags().GetString(profileFlag)
+		deploymentOption, _ := cmd.Flags().GetString(deploymentOptionFlag)
+		format, _ := cmd.Flags().GetString(formatFlag)
+		reveal, _ := cmd.Flags().GetBool(revealFlag)
+		offline, _ := cmd.Flags().GetBool(offlineFlag)
+
+		params := DeployParams{
+			ManifestPath:     manifestPath,
+			AgentConfigPaths: configPaths,
+			Profile:          profile,
+			DeploymentOption: &deploymentOption,
+			Offline:          offline,
+			Reveal:           reveal,
+		}
+		err := runGraph(getContextWithLogger(cmd), os.Stdout, params, format)
+		if err != nil {
+			util.OutputMessage(graphFail, err.Error())
+			return fmt.Errorf(CmdErrorHelpText, graphError)
+		}
+		return nil
+	},
+}
+
+func init() {
+	graphCmd.Flags().StringP(manifestPathFlag, "m", "", "Manifest file for the application")
+	graphCmd.Flags().StringArrayP(configPathFlag, "c", nil, "User provided config file, can be repeated, later files override earlier ones")
+	graphCmd.Flags().String(profileFlag, "", "Profile to apply from the config files")
+	graphCmd.Flags().StringP(deploymentOptionFlag, "o", "", "Deployment option to use from the manifest")
+	graphCmd.Flags().String(formatFlag, graph.FormatText, "Output format: ["+strings.Join(graph.Formats, ", ")+"]")
+	graphCmd.Flags().Bool(revealFlag, false, "If set to true, secret env var values are shown instead of being redacted")
+	graphCmd.Flags().Bool(offlineFlag, false, "If set to true, remote manifests are served only from the cache")
+	graphCmd.MarkFlagRequired(manifestPathFlag)
+}
+
+func runGraph(ctx context.Context, w io.Writer, params DeployParams, format string) error {
+	// dependencies are resolved the same way as for a deployment, but the lock file is left untouched
+	agentSpecBuilder, err := loadAgentSpecs(ctx, params)
+	if err != nil {
+		return err
+	}
+	agentConfig, err := loadAgentConfig(agentSpecBuilder, manifest.EnvFile{}, params.AgentConfigPaths, params.Profile, internal.DOCKER)
+	if err != nil {
+		return err
+	}
+	agentSpecBuilder.LoadGraphConfig(agentConfig)
+
+	g, err := graph.NewGraph(agentSpecBuilder, params.Reveal)
+	if err != nil {
+		return err
+	}
+	return g.Render(w, format)
+}
@@ -0,0 +1,219 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/cisco-eti/wfsm/internal/buildreport"
+	"github.com/cisco-eti/wfsm/internal/engine"
+	"github.com/cisco-eti/wfsm/internal/util"
+)
+
+var inspectImageLongHelp = `
+This command prints the metadata of an agent image built by wfsm: its labels, its build report and its SBOM.
+
+The labels are read from the local image store of the container engine (--engine), or from the registry of the image
+if it is not stored locally or --remote is set. They identify the agent source (org.opencontainers.image.source,
+org.opencontainers.image.revision), the version of the agent (org.opencontainers.image.version) and the manifest
+the image was built from (org.agntcy.wfsm.manifest.name, org.agntcy.wfsm.manifest.version, org.agntcy.wfsm.manifest.digest).
+
+The build report and the SBOM are stored in ~/.wfsm/images (can be overridden with WFSM_REPORTS_FOLDER env var) by the build
+of the image. The SBOM of a pushed image built on another host is fetched from the OCI referrers of the image in its registry.
+
+Optional flags:
+	--sbom if set to true, the SBOM document (CycloneDX or SPDX JSON) is printed instead of the summary.
+	--remote if set to true, the labels are read from the registry of the image instead of the local image store.
+
+Examples:
+- Print the labels, the build report and the packages of an image:
+	wfsm inspect image agntcy/wfsm-mailcomposer:<tag>
+- Print the SBOM of a pushed image:
+	wfsm inspect image ghcr.io/org/agntcy/wfsm-mailcomposer:<tag> --sbom --remote
+`
+
+const inspectFail = "Inspect Status: Failed - %s"
+const inspectError string = "inspect failed"
+
+const sbomFlag string = "sbom"
+const remoteFlag string = "remote"
+
+type InspectImageParams struct {
+	Image  string
+	Engine string
+	// Remote reads the labels from the registry of the image
+	Remote bool
+	// SBOM prints the SBOM document instead of the summary
+	SBOM bool
+}
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect",
+	Short: "Inspect the artifacts built by wfsm",
+}
+
+var inspectImageCmd = &cobra.Command{
+	Use:   "image <image>",
+	Short: "Print the labels, the build report and the SBOM of an agent image",
+	Long:  inspectImageLongHelp,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		engineName, _ := cmd.Flags().GetString(engineFlag)
+		remote, _ := cmd.Flags().GetBool(remoteFlag)
+		sbom, _ := cmd.Flags().GetBool(sbomFlag)
+
+		err := runInspectImage(getContextWithLogger(cmd), os.Stdout, InspectImageParams{
+			Image:  args[0],
+			Engine: engineName,
+			Remote: remote,
+			SBOM:   sbom,
+		})
+		if err != nil {
+			util.OutputMessage(inspectFail, err.Error())
+			return fmt.Errorf(CmdErrorHelpText, inspectError)
+		}
+		return nil
+	},
+}
+
+func init() {
+	inspectImageCmd.Flags().Bool(sbomFlag, false, "If set to true, the SBOM document is printed instead of the summary")
+	inspectImageCmd.Flags().Bool(remoteFlag, false, "If set to true, the labels are read from the registry of the image")
+
+	inspectCmd.AddCommand(inspectImageCmd)
+}
+
+func runInspectImage(ctx context.Context, w io.Writer, params InspectImageParams) error {
+	log := zerolog.Ctx(ctx)
+
+	reports, err := getReportsStore()
+	if err != nil {
+		return err
+	}
+	report, sbomPath, err := reports.Load(params.Image)
+	hasReport := err == nil
+	if err != nil && !errors.Is(err, buildreport.ErrNotFound) {
+		return err
+	}
+
+	var sbom []byte
+	sbomSource := sbomPath
+	if hasReport {
+		if sbom, err = os.ReadFile(sbomPath); err != nil {
+			return fmt.Errorf("failed to read SBOM: %v", err)
+		}
+	} else {
+		sbom, sbomSource, err = buildreport.FetchSBOM(ctx, params.Image)
+		if err != nil {
+			log.Debug().Err(err).Msg("no SBOM found in the registry of the image")
+		}
+	}
+	if params.SBOM {
+		if sbom == nil {
+			return fmt.Errorf("no SBOM found for image %s, it was not built by this host nor pushed with an SBOM", params.Image)
+		}
+		_, err := w.Write(sbom)
+		return err
+	}
+
+	labels, err := imageLabels(ctx, params)
+	if err != nil {
+		if !hasReport {
+			return err
+		}
+		log.Warn().Err(err).Msg("image not found, printing the labels of the build report")
+		labels = report.Labels
+	}
+
+	fmt.Fprintf(w, "Image: %s\n", params.Image)
+	fmt.Fprintln(w, "Labels:")
+	for _, label := range slices.Sorted(maps.Keys(labels)) {
+		fmt.Fprintf(w, "  %s: %s\n", label, labels[label])
+	}
+	if hasReport {
+		fmt.Fprintln(w, "Build report:")
+		fmt.Fprintf(w, "  Built at: %s\n", report.BuiltAt.Format(time.RFC3339))
+		fmt.Fprintf(w, "  Base image: %s\n", strings.TrimSuffix(report.BaseImage+"@"+report.BaseImageDigest, "@"))
+		fmt.Fprintf(w, "  Platforms: %s\n", strings.Join(report.Platforms, ", "))
+		if report.Pushed {
+			fmt.Fprintf(w, "  SBOM referrer: %s\n", report.SBOMReferrer)
+		}
+		if report.OutputPath != "" {
+			fmt.Fprintf(w, "  OCI layout tarball: %s\n", report.OutputPath)
+		}
+	}
+	if sbom == nil {
+		fmt.Fprintln(w, "SBOM: not found")
+		return nil
+	}
+	content, err := buildreport.ParseSBOM(sbom)
+	if err != nil {
+		return err
+	}
+	packagesSource := ""
+	if hasReport {
+		packagesSource = fmt.Sprintf(" (%s)", report.PackagesSource)
+	} else if content.PackagesSource != "" {
+		packagesSource = fmt.Sprintf(" (%s)", content.PackagesSource)
+	}
+	fmt.Fprintf(w, "SBOM: %s, %s\n", content.Format, sbomSource)
+	if content.PackagesFallback != "" {
+		fmt.Fprintf(w, "  Locked packages listed instead of the installed ones: %s\n", content.PackagesFallback)
+	}
+	fmt.Fprintf(w, "Python packages%s:\n", packagesSource)
+	for _, pkg := range content.Packages {
+		fmt.Fprintf(w, "  %s %s\n", pkg.Name, pkg.Version)
+	}
+	return nil
+}
+
+// imageLabels returns the labels of the image in the local image store of the engine,
+// or the ones in the registry of the image if it is not stored locally or the remote param is set
+func imageLabels(ctx context.Context, params InspectImageParams) (map[string]string, error) {
+	log := zerolog.Ctx(ctx)
+
+	if !params.Remote {
+		labels, err := localImageLabels(ctx, params.Engine, params.Image)
+		if err == nil {
+			return labels, nil
+		}
+		log.Debug().Err(err).Msg("image not found in the local image store, looking it up in its registry")
+	}
+	platform, err := v1.ParsePlatform(util.CurrentArchToDockerPlatform())
+	if err != nil {
+		return nil, err
+	}
+	return buildreport.RemoteLabels(ctx, params.Image, *platform)
+}
+
+func localImageLabels(ctx context.Context, engineName string, img string) (map[string]string, error) {
+	containerEngine, err := engine.Get(engineName)
+	if err != nil {
+		return nil, err
+	}
+	client, err := containerEngine.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	inspect, err := client.ImageInspect(ctx, img)
+	if err != nil {
+		return nil, err
+	}
+	if inspect.Config == nil {
+		return nil, nil
+	}
+	return inspect.Config.Labels, nil
+}
@@ -8,7 +8,8 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"github.com/cisco-eti/wfsm/internal/platforms/docker"
+	"github.com/cisco-eti/wfsm/internal"
+	"github.com/cisco-eti/wfsm/internal/platforms"
 
 	"github.com/cisco-eti/wfsm/internal/util"
 )
@@ -19,6 +20,7 @@ Agent deployment name is the name of the agent in the manifest file.
                                       
 Optional flags:
 	--platform specify the platform to deploy the agent(s) to. Currently only 'docker' is supported.
+	--engine specify the container engine running the agent(s) [docker, podman].
 		
 Examples:
 - List all running agent containers in 'emailreviewer' deployment:
@@ -36,8 +38,9 @@ var listCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 
 		agentDeploymentName, _ := cmd.Flags().GetString(agentDeploymentNameFlag)
+		engineName, _ := cmd.Flags().GetString(engineFlag)
 
-		err := runList(getContextWithLogger(cmd), agentDeploymentName)
+		err := runList(getContextWithLogger(cmd), agentDeploymentName, engineName)
 		if err != nil {
 			util.OutputMessage(listFail, err.Error())
 			return fmt.Errorf(CmdErrorHelpText, listError)
@@ -52,13 +55,13 @@ func init() {
 	listCmd.MarkFlagRequired(agentDeploymentNameFlag)
 }
 
-func runList(ctx context.Context, agentDeploymentName string) error {
+func runList(ctx context.Context, agentDeploymentName string, engineName string) error {
 
 	hostStorageFolder, err := getHostStorageFolder(agentDeploymentName)
 	if err != nil {
 		return err
 	}
-	runner := docker.NewDockerComposeRunner(hostStorageFolder)
+	runner := platforms.GetPlatformRunner(internal.DOCKER, engineName, hostStorageFolder, false)
 
 	err = runner.List(ctx, agentDeploymentName)
 	if err != nil {
@@ -11,7 +11,8 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
 
-	"github.com/cisco-eti/wfsm/internal/platforms/docker"
+	"github.com/cisco-eti/wfsm/internal"
+	"github.com/cisco-eti/wfsm/internal/platforms"
 	"github.com/cisco-eti/wfsm/internal/util"
 )
 
@@ -21,6 +22,7 @@ Agent deployment name is the name of the agent in the manifest file.
                                       
 Optional flags:
 	--platform specify the platform to deploy the agent(s) to. Currently only 'docker' is supported.
+	--engine specify the container engine running the agent(s) [docker, podman].
 		
 Examples:
 - Shows latest logs of all running agent containers in 'emailreviewer' deployment:
@@ -38,8 +40,9 @@ var logsCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 
 		agentDeploymentName, _ := cmd.Flags().GetString(agentDeploymentNameFlag)
+		engineName, _ := cmd.Flags().GetString(engineFlag)
 
-		err := runLogs(getContextWithLogger(cmd), agentDeploymentName)
+		err := runLogs(getContextWithLogger(cmd), agentDeploymentName, engineName)
 		if err != nil {
 			util.OutputMessage(logsFail, err.Error())
 			return fmt.Errorf(CmdErrorHelpText, logsError)
@@ -54,7 +57,7 @@ func init() {
 	logsCmd.MarkFlagRequired(agentDeploymentNameFlag)
 }
 
-func runLogs(ctx context.Context, agentDeploymentName string) error {
+func runLogs(ctx context.Context, agentDeploymentName string, engineName string) error {
 	logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).With().Timestamp().Logger()
 	zerolog.DefaultContextLogger = &logger
 
@@ -62,7 +65,7 @@ func runLogs(ctx context.Context, agentDeploymentName string) error {
 	if err != nil {
 		return err
 	}
-	runner := docker.NewDockerComposeRunner(hostStorageFolder)
+	runner := platforms.GetPlatformRunner(internal.DOCKER, engineName, hostStorageFolder, false)
 
 	err = runner.Logs(ctx, agentDeploymentName, []string{})
 	if err != nil {
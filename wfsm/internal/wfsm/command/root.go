@@ -9,6 +9,8 @@ import (
 
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
+
+	"github.com/cisco-eti/wfsm/internal/engine"
 )
 
 type WorkflowServerManager interface {
@@ -31,6 +33,7 @@ const (
 	CmdErrorHelpText  = "%s.\n\nFor additional help, " + ReadTheDocsText
 	verboseChecksFlag = "verbose"
 	platformsFlag     = "platform"
+	engineFlag        = "engine"
 )
 
 // NewRootCmd constructs a base command object
@@ -56,12 +59,19 @@ func newRootCmd(version string) *cobra.Command {
 	// rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	rootCmd.PersistentFlags().BoolP(verboseChecksFlag, "v", false, "Output verbose logs for the checks")
 	rootCmd.PersistentFlags().StringP(platformsFlag, "p", "docker", "The platform to deploy the agent(s): [docker, k8s]")
+	rootCmd.PersistentFlags().String(engineFlag, engine.Docker, "The container engine building the images and running the agent(s) on the docker platform: [docker, podman]")
 
 	rootCmd.AddCommand(checkCmd)
 	rootCmd.AddCommand(deployCmd)
+	rootCmd.AddCommand(buildCmd)
 	rootCmd.AddCommand(stopCmd)
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(logsCmd)
+	rootCmd.AddCommand(depsCmd)
+	rootCmd.AddCommand(graphCmd)
+	rootCmd.AddCommand(envCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(inspectCmd)
 
 	return rootCmd
 }
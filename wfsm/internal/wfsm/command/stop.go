@@ -21,6 +21,7 @@ Agent deployment name is the name of the agent in the manifest file.
                                    
 Optional flags:
 	--platform specify the platform to deploy the agent(s) to [docker, k8s].
+	--engine specify the container engine running the agent(s) on the docker platform [docker, podman].
 		
 Examples:
 - Stops all running agents in 'emailreviewer' agent deployment:
@@ -41,8 +42,9 @@ var stopCmd = &cobra.Command{
 
 		agentDeploymentName, _ := cmd.Flags().GetString(agentDeploymentNameFlag)
 		platform, _ := cmd.Flags().GetString(platformsFlag)
+		engineName, _ := cmd.Flags().GetString(engineFlag)
 
-		err := runStop(getContextWithLogger(cmd), agentDeploymentName, platform)
+		err := runStop(getContextWithLogger(cmd), agentDeploymentName, platform, engineName)
 		if err != nil {
 			util.OutputMessage(stopFail, err.Error())
 			return fmt.Errorf(CmdErrorHelpText, stopError)
@@ -56,7 +58,7 @@ func init() {
 	stopCmd.MarkFlagRequired(agentDeploymentNameFlag)
 }
 
-func runStop(ctx context.Context, agentDeploymentName string, platform string) error {
+func runStop(ctx context.Context, agentDeploymentName string, platform string, engineName string) error {
 	logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).With().Timestamp().Logger()
 	zerolog.DefaultContextLogger = &logger
 
@@ -67,7 +69,7 @@ func runStop(ctx context.Context, agentDeploymentName string, platform string) e
 	if err != nil {
 		return err
 	}
-	runner := platforms.GetPlatformRunner(platform, hostStorageFolder)
+	runner := platforms.GetPlatformRunner(platform, engineName, hostStorageFolder, false)
 
 	err = runner.Remove(ctx, agentDeploymentName)
 	if err != nil {
@@ -1,11 +1,16 @@
 package config
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/cisco-eti/wfsm/internal"
 	"github.com/cisco-eti/wfsm/internal/util"
@@ -14,27 +19,68 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-// loadConfig parses a config.yaml file and returns an AgentConfig.
-func LoadConfig(path string) (ConfigFile, error) {
-	file, err := os.ReadFile(path)
-	if err != nil {
-		return ConfigFile{}, fmt.Errorf("failed to read config file: %v", err)
+// UserConfig is the merged config of the user provided config files. It is kept as a yaml tree of the agent configs,
+// so that null values can clear the fields of the default config when merged with MergeConfigs.
+type UserConfig struct {
+	Config map[string]any
+}
+
+// configFileLayers are the layers of a config file: the config of the agents and the profiles overriding it
+type configFileLayers struct {
+	Config   map[string]any            `yaml:"config"`
+	Profiles map[string]map[string]any `yaml:"profiles"`
+}
+
+// LoadConfig parses the config files and deep merges them in order, later files override earlier ones.
+// If a profile is given, the profile of each file overrides the config of that file, the profile must be defined in at least one file.
+func LoadConfig(paths []string, profile string) (UserConfig, error) {
+	userConfig := UserConfig{Config: make(map[string]any)}
+	profileFound := false
+	for _, path := range paths {
+		file, err := os.ReadFile(path)
+		if err != nil {
+			return UserConfig{}, fmt.Errorf("failed to read config file: %v", err)
+		}
+
+		// decode strictly first, so that unknown and misplaced fields are reported instead of being ignored
+		if err := validateConfigFile(file); err != nil {
+			return UserConfig{}, fmt.Errorf("invalid config file %s: %v", path, err)
+		}
+		var layers configFileLayers
+		if err := yaml.Unmarshal(file, &layers); err != nil {
+			return UserConfig{}, fmt.Errorf("failed to unmarshal config file %s: %v", path, err)
+		}
+		userConfig.Config = mergeTrees(userConfig.Config, layers.Config)
+		if profileConfig, ok := layers.Profiles[profile]; ok && profile != "" {
+			profileFound = true
+			userConfig.Config = mergeTrees(userConfig.Config, profileConfig)
+		}
+	}
+	if profile != "" && !profileFound {
+		return UserConfig{}, fmt.Errorf("profile %s is not defined in the config files", profile)
 	}
+	return userConfig, nil
+}
 
+// validateConfigFile decodes the config file into ConfigFile allowing known fields only
+func validateConfigFile(file []byte) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(file))
+	decoder.KnownFields(true)
 	var config ConfigFile
-	if err := yaml.Unmarshal(file, &config); err != nil {
-		return ConfigFile{}, fmt.Errorf("failed to unmarshal config file: %v", err)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return err
 	}
-
-	return config, nil
+	return nil
 }
 
 // WriteConfig writes the given AgentConfig to a config.yaml file.
+// The file references the JSON Schema of config files for editors supporting yaml-language-server modelines.
 func WriteConfig(path string, config ConfigFile) error {
 	data, err := yaml.Marshal(config)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %v", err)
 	}
+	data = append([]byte("# yaml-language-server: $schema="+SchemaID+"\n"), data...)
 
 	if err := os.WriteFile(path, data, 0644); err != nil {
 		return fmt.Errorf("failed to write config file: %v", err)
@@ -128,7 +174,11 @@ func getEnvVarValue(agentName string, envVarName string, envFile map[string]stri
 	return ""
 }
 
-func PrintConfig(ctx context.Context, file ConfigFile) error {
+// PrintConfig logs the config, API keys and values of secret env vars are redacted unless reveal is set
+func PrintConfig(ctx context.Context, file ConfigFile, agentSpecs map[string]internal.AgentSpec, reveal bool) error {
+	if !reveal {
+		file = redactConfig(file, agentSpecs)
+	}
 	data, err := yaml.Marshal(file)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %v", err)
@@ -138,65 +188,75 @@ func PrintConfig(ctx context.Context, file ConfigFile) error {
 	return nil
 }
 
-func MergeConfigs(agentConfig, userConfig ConfigFile, platform string) ConfigFile {
-	for key, userValue := range userConfig.Config {
-		if agentValue, exists := agentConfig.Config[key]; exists {
-			// Override existing fields in agentConfig with userConfig values
-			if userValue.Port != 0 {
-				agentValue.Port = userValue.Port
-			}
-			if userValue.APIKey != "" {
-				agentValue.APIKey = userValue.APIKey
-			}
-			if userValue.ID != "" {
-				agentValue.ID = userValue.ID
-			}
-			if len(userValue.EnvVars) > 0 {
-				for envKey, envValue := range userValue.EnvVars {
-					agentValue.EnvVars[envKey] = envValue
-				}
-			}
-			if platform == internal.KUBERNETES {
-				agentValue = mergeK8sConfigs(agentValue, userValue)
-			}
-			agentConfig.Config[key] = agentValue
-		} else {
-			// Add new key-value pairs from userConfig to agentConfig
-			agentConfig.Config[key] = userValue
-		}
+// redactConfig returns a copy of the config with API keys and values of secret env vars redacted
+func redactConfig(file ConfigFile, agentSpecs map[string]internal.AgentSpec) ConfigFile {
+	redacted := ConfigFile{
+		Config: make(map[string]AgentConfig, len(file.Config)),
 	}
-	return agentConfig
+	for name, agentConfig := range file.Config {
+		agentSpec := agentSpecs[name]
+		agentSpec.SecretEnvVars = append(slices.Clone(agentSpec.SecretEnvVars), agentConfig.SecretEnvVars...)
+		agentConfig.APIKey = internal.Redact(agentConfig.APIKey, false)
+		agentConfig.EnvVars = agentSpec.RedactEnvVars(agentConfig.EnvVars)
+		redacted.Config[name] = agentConfig
+	}
+	return redacted
 }
 
-func mergeK8sConfigs(agentValue AgentConfig, userValue AgentConfig) AgentConfig {
-	if userValue.K8sConfig == nil {
-		userValue.K8sConfig = &internal.K8sConfig{}
-	}
-	if userValue.K8sConfig.EnvVarsFromSecret != "" {
-		agentValue.K8sConfig.EnvVarsFromSecret = userValue.K8sConfig.EnvVarsFromSecret
+// MergeConfigs deep merges the user config into the agent config: maps are merged recursively, other values replace
+// the values of the agent config and null values clear them. The k8s config of the agents is only merged for k8s deployments.
+// The user config must only configure the agents of the agent config.
+func MergeConfigs(agentConfig ConfigFile, userConfig UserConfig, platform string) (ConfigFile, error) {
+	tree, err := toTree(agentConfig.Config)
+	if err != nil {
+		return ConfigFile{}, err
 	}
-	// Merge K8sConfig.StatefulSet
-	agentValue.K8sConfig.StatefulSet.Labels = util.MergeMaps(agentValue.K8sConfig.StatefulSet.Labels, userValue.K8sConfig.StatefulSet.Labels)
-	agentValue.K8sConfig.StatefulSet.Annotations = util.MergeMaps(agentValue.K8sConfig.StatefulSet.Annotations, userValue.K8sConfig.StatefulSet.Annotations)
-	agentValue.K8sConfig.StatefulSet.PodAnnotations = util.MergeMaps(agentValue.K8sConfig.StatefulSet.PodAnnotations, userValue.K8sConfig.StatefulSet.PodAnnotations)
 
-	if userValue.K8sConfig.StatefulSet.Replicas != 0 {
-		agentValue.K8sConfig.StatefulSet.Replicas = userValue.K8sConfig.StatefulSet.Replicas
+	var unknownAgents []string
+	for agentName := range userConfig.Config {
+		if _, ok := agentConfig.Config[agentName]; !ok {
+			unknownAgents = append(unknownAgents, agentName)
+		}
 	}
-	if userValue.K8sConfig.StatefulSet.NodeSelector != nil {
-		agentValue.K8sConfig.StatefulSet.NodeSelector = userValue.K8sConfig.StatefulSet.NodeSelector
+	if len(unknownAgents) > 0 {
+		sort.Strings(unknownAgents)
+		agentNames := make([]string, 0, len(agentConfig.Config))
+		for agentName := range agentConfig.Config {
+			agentNames = append(agentNames, agentName)
+		}
+		sort.Strings(agentNames)
+		return ConfigFile{}, fmt.Errorf("config of unknown agents %s, the agents of the deployment are %s",
+			strings.Join(unknownAgents, ", "), strings.Join(agentNames, ", "))
+	}
+
+	userTree := userConfig.Config
+	if platform != internal.KUBERNETES {
+		userTree = withoutK8sConfig(userTree)
 	}
-	if userValue.K8sConfig.StatefulSet.Tolerations != nil {
-		agentValue.K8sConfig.StatefulSet.Tolerations = userValue.K8sConfig.StatefulSet.Tolerations
+
+	merged := ConfigFile{}
+	if err := fromTree(pruneNulls(mergeTrees(tree, userTree)), &merged.Config); err != nil {
+		return ConfigFile{}, err
 	}
-	agentValue.K8sConfig.StatefulSet.Affinity = userValue.K8sConfig.StatefulSet.Affinity
-	agentValue.K8sConfig.StatefulSet.Resources = userValue.K8sConfig.StatefulSet.Resources
+	return merged, nil
+}
 
-	// Merge K8sConfig.Service
-	agentValue.K8sConfig.Service.Labels = util.MergeMaps(agentValue.K8sConfig.Service.Labels, userValue.K8sConfig.Service.Labels)
-	agentValue.K8sConfig.Service.Annotations = util.MergeMaps(agentValue.K8sConfig.Service.Annotations, userValue.K8sConfig.Service.Annotations)
-	if userValue.K8sConfig.Service.Type != "" {
-		agentValue.K8sConfig.Service.Type = userValue.K8sConfig.Service.Type
+// withoutK8sConfig returns a copy of the user config tree without the k8s config of the agents
+func withoutK8sConfig(tree map[string]any) map[string]any {
+	stripped := make(map[string]any, len(tree))
+	for agentName, value := range tree {
+		agentTree, ok := value.(map[string]any)
+		if !ok {
+			stripped[agentName] = value
+			continue
+		}
+		agentTreeCopy := make(map[string]any, len(agentTree))
+		for key, agentValue := range agentTree {
+			if key != "k8s" {
+				agentTreeCopy[key] = agentValue
+			}
+		}
+		stripped[agentName] = agentTreeCopy
 	}
-	return agentValue
+	return stripped
 }
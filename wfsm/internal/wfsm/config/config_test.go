@@ -0,0 +1,320 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+
+	"github.com/cisco-eti/wfsm/internal"
+)
+
+func defaultK8sConfig() *internal.K8sConfig {
+	return &internal.K8sConfig{
+		StatefulSet: internal.StatefulSet{
+			Replicas: 1,
+			Labels:   map[string]string{"app": "agent_A"},
+			Resources: internal.Resources{
+				Limits: map[string]string{"cpu": "500m", "memory": "256Mi"},
+			},
+			Affinity: internal.Affinity{NodeAffinity: internal.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: internal.RequiredDuringSchedulingIgnoredDuringExecution{
+					NodeSelectorTerms: []internal.NodeSelectorTerm{{MatchExpressions: []internal.MatchExpression{
+						{Key: "zone", Operator: "In", Values: []string{"az1"}},
+					}}},
+				},
+			}},
+		},
+		Service: internal.Service{Type: "NodePort", Labels: map[string]string{"app": "agent_A"}},
+	}
+}
+
+func userConfig(t *testing.T, content string) UserConfig {
+	var tree map[string]any
+	assert.NoError(t, yaml.Unmarshal([]byte(content), &tree))
+	return UserConfig{Config: tree}
+}
+
+func TestMergeConfigs(t *testing.T) {
+	tests := []struct {
+		name       string
+		platform   string
+		userConfig string
+		modify     func(expected *AgentConfig)
+	}{
+		{
+			name:       "empty user config keeps defaults",
+			platform:   internal.KUBERNETES,
+			userConfig: `agent_A: {}`,
+			modify:     func(expected *AgentConfig) {},
+		},
+		{
+			name:     "scalars override defaults",
+			platform: internal.DOCKER,
+			userConfig: `
+agent_A:
+  port: 9090
+  apiKey: user_key`,
+			modify: func(expected *AgentConfig) {
+				expected.Port = 9090
+				expected.APIKey = "user_key"
+			},
+		},
+		{
+			name:     "maps are merged key by key",
+			platform: internal.DOCKER,
+			userConfig: `
+agent_A:
+  envVars:
+    MODEL: gpt-4o`,
+			modify: func(expected *AgentConfig) {
+				expected.EnvVars["MODEL"] = "gpt-4o"
+			},
+		},
+		{
+			name:     "null clears map entries",
+			platform: internal.DOCKER,
+			userConfig: `
+agent_A:
+  envVars:
+    LOG_LEVEL: null`,
+			modify: func(expected *AgentConfig) {
+				delete(expected.EnvVars, "LOG_LEVEL")
+			},
+		},
+		{
+			name:     "k8s config is ignored for docker",
+			platform: internal.DOCKER,
+			userConfig: `
+agent_A:
+  k8s:
+    statefulset:
+      replicas: 3`,
+			modify: func(expected *AgentConfig) {},
+		},
+		{
+			name:     "k8s affinity and resources are kept if not set",
+			platform: internal.KUBERNETES,
+			userConfig: `
+agent_A:
+  k8s:
+    statefulset:
+      replicas: 3`,
+			modify: func(expected *AgentConfig) {
+				expected.K8sConfig.StatefulSet.Replicas = 3
+			},
+		},
+		{
+			name:     "k8s resources are merged",
+			platform: internal.KUBERNETES,
+			userConfig: `
+agent_A:
+  k8s:
+    statefulset:
+      resources:
+        limits:
+          cpu: "1"
+        requests:
+          cpu: 250m`,
+			modify: func(expected *AgentConfig) {
+				expected.K8sConfig.StatefulSet.Resources = internal.Resources{
+					Limits:   map[string]string{"cpu": "1", "memory": "256Mi"},
+					Requests: map[string]string{"cpu": "250m"},
+				}
+			},
+		},
+		{
+			name:     "k8s lists replace defaults",
+			platform: internal.KUBERNETES,
+			userConfig: `
+agent_A:
+  k8s:
+    statefulset:
+      affinity:
+        nodeAffinity:
+          requiredDuringSchedulingIgnoredDuringExecution:
+            nodeSelectorTerms:
+              - matchExpressions:
+                  - key: disktype
+                    operator: In
+                    values: [ssd]`,
+			modify: func(expected *AgentConfig) {
+				expected.K8sConfig.StatefulSet.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms = []internal.NodeSelectorTerm{
+					{MatchExpressions: []internal.MatchExpression{{Key: "disktype", Operator: "In", Values: []string{"ssd"}}}},
+				}
+			},
+		},
+		{
+			name:     "null clears k8s fields",
+			platform: internal.KUBERNETES,
+			userConfig: `
+agent_A:
+  k8s:
+    statefulset:
+      affinity: null
+      resources:
+        limits: null
+    service:
+      labels: null`,
+			modify: func(expected *AgentConfig) {
+				expected.K8sConfig.StatefulSet.Affinity = internal.Affinity{}
+				expected.K8sConfig.StatefulSet.Resources = internal.Resources{}
+				expected.K8sConfig.Service.Labels = nil
+			},
+		},
+		{
+			name:     "secret env vars are combined",
+			platform: internal.DOCKER,
+			userConfig: `
+agent_A:
+  secretEnvVars: [PASSWORD]`,
+			modify: func(expected *AgentConfig) {
+				expected.SecretEnvVars = []string{"TOKEN", "PASSWORD"}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.platform+"/"+tt.name, func(t *testing.T) {
+			defaults := AgentConfig{
+				Port:          8000,
+				APIKey:        "default_key",
+				ID:            "default_id",
+				EnvVars:       map[string]string{"LOG_LEVEL": "info"},
+				SecretEnvVars: []string{"TOKEN"},
+			}
+			if tt.platform == internal.KUBERNETES {
+				defaults.K8sConfig = defaultK8sConfig()
+			}
+			agentConfig := ConfigFile{Config: map[string]AgentConfig{"agent_A": defaults}}
+
+			expected := defaults
+			expected.EnvVars = map[string]string{"LOG_LEVEL": "info"}
+			if tt.platform == internal.KUBERNETES {
+				expected.K8sConfig = defaultK8sConfig()
+			}
+			tt.modify(&expected)
+
+			merged, err := MergeConfigs(agentConfig, userConfig(t, tt.userConfig), tt.platform)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, merged.Config["agent_A"])
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		paths       []string
+		profile     string
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:  "single file",
+			paths: []string{"test/base_config.yaml"},
+			expected: `
+agent_A:
+  port: 8080
+  envVars: {LOG_LEVEL: info, MODEL: gpt-4o}
+  secretEnvVars: [TOKEN]
+  k8s: {statefulset: {resources: {limits: {cpu: 500m}}}}`,
+		},
+		{
+			name:  "later files override earlier ones",
+			paths: []string{"test/base_config.yaml", "test/override_config.yaml"},
+			expected: `
+agent_A:
+  port: 8080
+  envVars: {LOG_LEVEL: info, MODEL: null}
+  secretEnvVars: [TOKEN, PASSWORD]
+  k8s: {statefulset: {resources: {limits: {cpu: 500m}}}}`,
+		},
+		{
+			name:    "profiles override the config of their file",
+			paths:   []string{"test/base_config.yaml", "test/override_config.yaml"},
+			profile: "dev",
+			expected: `
+agent_A:
+  port: 9090
+  envVars: {LOG_LEVEL: debug, MODEL: null}
+  secretEnvVars: [TOKEN, PASSWORD]
+  k8s: {statefulset: {resources: {limits: {cpu: 500m}}}}`,
+		},
+		{
+			name:    "profile defined in one file",
+			paths:   []string{"test/base_config.yaml", "test/override_config.yaml"},
+			profile: "prod",
+			expected: `
+agent_A:
+  port: 8080
+  envVars: {LOG_LEVEL: info, MODEL: null}
+  secretEnvVars: [TOKEN, PASSWORD]
+  k8s: {statefulset: {replicas: 3, resources: {limits: {cpu: 500m}}}}`,
+		},
+		{
+			name:        "unknown profile",
+			paths:       []string{"test/base_config.yaml"},
+			profile:     "staging",
+			expectedErr: "profile staging is not defined in the config files",
+		},
+		{
+			name:        "misplaced field",
+			paths:       []string{"test/misplaced_config.yaml"},
+			expectedErr: "invalid config file test/misplaced_config.yaml: yaml: unmarshal errors:\n  line 3: field envVarsFromSecret not found in type config.AgentConfig",
+		},
+		{
+			name:        "unknown field in later file",
+			paths:       []string{"test/base_config.yaml", "test/typo_config.yaml"},
+			expectedErr: "invalid config file test/typo_config.yaml: yaml: unmarshal errors:\n  line 5: field replica not found in type internal.StatefulSet",
+		},
+		{
+			name:        "missing file",
+			paths:       []string{"test/missing.yaml"},
+			expectedErr: "failed to read config file: open test/missing.yaml: no such file or directory",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded, err := LoadConfig(tt.paths, tt.profile)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, userConfig(t, tt.expected), loaded)
+		})
+	}
+}
+
+func TestMergeConfigs_UnknownAgents(t *testing.T) {
+	agentConfig := ConfigFile{Config: map[string]AgentConfig{
+		"agent_A": {APIKey: "key_a"},
+		"agent_B": {APIKey: "key_b"},
+	}}
+	_, err := MergeConfigs(agentConfig, userConfig(t, `
+agent_A: {port: 8080}
+agent_c: {port: 8081}
+agnet_A: {port: 8082}`), internal.DOCKER)
+	assert.EqualError(t, err, "config of unknown agents agent_c, agnet_A, the agents of the deployment are agent_A, agent_B")
+}
+
+func TestSchema(t *testing.T) {
+	schema, err := Schema()
+	assert.NoError(t, err)
+
+	// the published schema is generated with 'make config-schema'
+	published, err := os.ReadFile("../../../spec/config_schema.json")
+	assert.NoError(t, err)
+	assert.Equal(t, string(published), string(schema), "spec/config_schema.json is outdated, run 'make config-schema'")
+
+	var decoded map[string]any
+	assert.NoError(t, json.Unmarshal(schema, &decoded))
+	agentSchema := decoded["properties"].(map[string]any)["config"].(map[string]any)["additionalProperties"].(map[string]any)
+	assert.Equal(t, false, agentSchema["additionalProperties"])
+	assert.Contains(t, agentSchema["properties"], "secretEnvVars")
+	assert.Contains(t, decoded["properties"], "profiles")
+}
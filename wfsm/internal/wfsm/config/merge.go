@@ -0,0 +1,88 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package config
+
+import (
+	"fmt"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// unionLists are the lists which are combined with the list of lower layers instead of replacing it,
+// so that a layer cannot unmark a secret env var by accident
+var unionLists = []string{"secretEnvVars"}
+
+// mergeTrees deep merges the yaml tree src into dst and returns dst (a new map if dst is nil):
+//   - maps are merged recursively, keys missing in src are kept
+//   - scalars and lists in src replace the value in dst (except unionLists)
+//   - null values in src clear the value in dst, the null is kept so that it also clears the value of lower layers
+func mergeTrees(dst map[string]any, src map[string]any) map[string]any {
+	if dst == nil {
+		dst = make(map[string]any, len(src))
+	}
+	for key, srcValue := range src {
+		srcMap, srcIsMap := srcValue.(map[string]any)
+		dstMap, dstIsMap := dst[key].(map[string]any)
+		srcList, srcIsList := srcValue.([]any)
+		dstList, dstIsList := dst[key].([]any)
+		switch {
+		case srcIsMap:
+			// src maps are copied, so merging later layers does not modify earlier ones
+			if !dstIsMap {
+				dstMap = nil
+			}
+			dst[key] = mergeTrees(dstMap, srcMap)
+		case srcIsList && dstIsList && slices.Contains(unionLists, key):
+			dstList = slices.Clone(dstList)
+			for _, item := range srcList {
+				if !slices.Contains(dstList, item) {
+					dstList = append(dstList, item)
+				}
+			}
+			dst[key] = dstList
+		default:
+			dst[key] = srcValue
+		}
+	}
+	return dst
+}
+
+// pruneNulls removes the null values left by mergeTrees from the tree, so that cleared map entries are removed
+// and cleared fields are decoded to their zero value
+func pruneNulls(tree map[string]any) map[string]any {
+	for key, value := range tree {
+		switch v := value.(type) {
+		case nil:
+			delete(tree, key)
+		case map[string]any:
+			pruneNulls(v)
+		}
+	}
+	return tree
+}
+
+// toTree converts a value to its yaml tree
+func toTree(value any) (map[string]any, error) {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %v", err)
+	}
+	tree := make(map[string]any)
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %v", err)
+	}
+	return tree, nil
+}
+
+// fromTree decodes a yaml tree into out, null values set the fields to their zero value
+func fromTree(tree map[string]any, out any) error {
+	data, err := yaml.Marshal(tree)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %v", err)
+	}
+	if err := yaml.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to unmarshal config: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// SchemaID is the id of the JSON Schema of config files, published in the repository
+const SchemaID = "https://raw.githubusercontent.com/agntcy/workflow-srv-mgr/main/wfsm/spec/config_schema.json"
+
+// Schema returns the JSON Schema of config files generated from ConfigFile, editors use it to validate and
+// autocomplete config files. Every field can be null, to clear the value of the default config or an earlier config file.
+func Schema() ([]byte, error) {
+	schema := typeSchema(reflect.TypeOf(ConfigFile{}))
+	schema["type"] = "object"
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["$id"] = SchemaID
+	schema["title"] = "wfsm config file"
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config schema: %v", err)
+	}
+	return append(data, '\n'), nil
+}
+
+func typeSchema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": []string{"string", "null"}}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": []string{"integer", "null"}}
+	case reflect.Bool:
+		return map[string]any{"type": []string{"boolean", "null"}}
+	case reflect.Slice:
+		return map[string]any{"type": []string{"array", "null"}, "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": []string{"object", "null"}, "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]any, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			properties[name] = typeSchema(field.Type)
+		}
+		return map[string]any{"type": []string{"object", "null"}, "properties": properties, "additionalProperties": false}
+	default:
+		panic(fmt.Sprintf("unsupported type %s in config schema", t))
+	}
+}
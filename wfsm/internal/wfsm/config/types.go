@@ -4,12 +4,22 @@ import "github.com/cisco-eti/wfsm/internal"
 
 type ConfigFile struct {
 	Config map[string]AgentConfig `yaml:"config"`
+	// Profiles override the config of the agents when selected with --profile
+	Profiles map[string]map[string]AgentConfig `yaml:"profiles,omitempty"`
 }
 
 type AgentConfig struct {
-	Port      int                 `yaml:"port"`
-	APIKey    string              `yaml:"apiKey"`
-	ID        string              `yaml:"id"`
-	EnvVars   map[string]string   `yaml:"envVars"`
-	K8sConfig *internal.K8sConfig `yaml:"k8s,omitempty"`
+	Port    int               `yaml:"port"`
+	APIKey  string            `yaml:"apiKey"`
+	ID      string            `yaml:"id"`
+	EnvVars map[string]string `yaml:"envVars"`
+	// SecretEnvVars marks env vars as secret, their values are redacted in printouts and stored in a k8s Secret
+	SecretEnvVars []string `yaml:"secretEnvVars,omitempty"`
+	// InjectDependencyEnvVars set to false for a dependency disables its <DEP>_API_KEY, <DEP>_ID and <DEP>_ENDPOINT env vars,
+	// e.g. if the agent expects other names or formats set with template expressions in envVars
+	InjectDependencyEnvVars map[string]bool     `yaml:"injectDependencyEnvVars,omitempty"`
+	K8sConfig               *internal.K8sConfig `yaml:"k8s,omitempty"`
+	// Dockerfile is the path of a Dockerfile relative to the root of the agent source, the image of the agent is built from it
+	// instead of the generated one, e.g. to install system packages. Dockerfile.wfsm is used if not set and the agent source has one.
+	Dockerfile string `yaml:"dockerfile,omitempty"`
 }
@@ -0,0 +1,117 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package envreport
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cisco-eti/wfsm/internal"
+	"github.com/cisco-eti/wfsm/internal/platforms"
+	"github.com/cisco-eti/wfsm/internal/wfsm/manifest"
+)
+
+// Report is the final environment of the agents of a deployment
+type Report struct {
+	// Root is the deployment name of the main agent
+	Root     string  `json:"root"`
+	Platform string  `json:"platform"`
+	Agents   []Agent `json:"agents"`
+}
+
+// Agent is the final environment of an agent
+type Agent struct {
+	DeploymentName string   `json:"deploymentName"`
+	EnvVars        []EnvVar `json:"envVars"`
+	// MissingEnvVars are required env vars declared in the manifest without a value
+	MissingEnvVars []string `json:"missingEnvVars,omitempty"`
+}
+
+// EnvVar is the final value of an env var with the layer it was set by and the values it overrode
+type EnvVar struct {
+	Name   string `json:"name"`
+	Secret bool   `json:"secret"`
+	manifest.EnvVarSetting
+	// Overridden are the values set by earlier layers, in the order they were applied
+	Overridden []manifest.EnvVarSetting `json:"overridden,omitempty"`
+}
+
+// NewReport creates the report of the env vars loaded by the agent spec builder (see AgentSpecBuilder.LoadFromConfig),
+// with template expressions interpolated and including the env vars the runner of the platform sets on deployment.
+// Secret values are redacted unless reveal is set.
+func NewReport(builder *manifest.AgentSpecBuilder, platform string, reveal bool) (Report, error) {
+	agentDeploymentSpecs := platforms.PreviewDeploymentSpecs(builder.AgentSpecs)
+	if err := platforms.InterpolateEnvVars(platform, agentDeploymentSpecs, builder.Dependencies); err != nil {
+		return Report{}, fmt.Errorf("failed to interpolate env vars: %v", err)
+	}
+
+	report := Report{
+		Root:     builder.DeploymentName,
+		Platform: platform,
+		Agents:   make([]Agent, 0, len(builder.AgentSpecs)),
+	}
+	for _, name := range sortedAgentNames(builder) {
+		spec := agentDeploymentSpecs[name]
+		settings := copySettings(builder.EnvVarSettings(name))
+		for envVarName, envVarSettings := range settings {
+			if last := len(envVarSettings) - 1; spec.EnvVars[envVarName] != envVarSettings[last].Value {
+				envVarSettings[last].Template = envVarSettings[last].Value
+				envVarSettings[last].Value = spec.EnvVars[envVarName]
+			}
+		}
+		for envVarName, value := range platforms.InjectedEnvVars(platform, name, agentDeploymentSpecs, builder.Dependencies) {
+			settings[envVarName] = append(settings[envVarName], manifest.EnvVarSetting{Layer: manifest.EnvLayerRunner, Value: value})
+		}
+
+		agent := Agent{DeploymentName: name, EnvVars: make([]EnvVar, 0, len(settings))}
+		for envVarName, envVarSettings := range settings {
+			secret := spec.IsSecretEnvVar(envVarName)
+			if secret && !reveal {
+				for i := range envVarSettings {
+					envVarSettings[i].Value = internal.Redact(envVarSettings[i].Value, reveal)
+				}
+			}
+			last := len(envVarSettings) - 1
+			envVar := EnvVar{Name: envVarName, Secret: secret, EnvVarSetting: envVarSettings[last]}
+			if last > 0 {
+				envVar.Overridden = envVarSettings[:last]
+			}
+			agent.EnvVars = append(agent.EnvVars, envVar)
+		}
+		sort.Slice(agent.EnvVars, func(i, j int) bool {
+			return agent.EnvVars[i].Name < agent.EnvVars[j].Name
+		})
+
+		for _, envVarDef := range manifest.GetDeployment(spec.Manifest).EnvVars {
+			if envVarDef.GetRequired() && spec.EnvVars[envVarDef.GetName()] == "" {
+				agent.MissingEnvVars = append(agent.MissingEnvVars, envVarDef.GetName())
+			}
+		}
+		report.Agents = append(report.Agents, agent)
+	}
+	return report, nil
+}
+
+// sortedAgentNames returns the main agent first, followed by the dependencies in alphabetical order
+func sortedAgentNames(builder *manifest.AgentSpecBuilder) []string {
+	names := make([]string, 0, len(builder.AgentSpecs))
+	for name := range builder.AgentSpecs {
+		if name != builder.DeploymentName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := builder.AgentSpecs[builder.DeploymentName]; ok {
+		names = append([]string{builder.DeploymentName}, names...)
+	}
+	return names
+}
+
+// copySettings copies the recorded settings, so they are not modified by redaction and the runner layer
+func copySettings(settings map[string][]manifest.EnvVarSetting) map[string][]manifest.EnvVarSetting {
+	copied := make(map[string][]manifest.EnvVarSetting, len(settings))
+	for name, envVarSettings := range settings {
+		copied[name] = append([]manifest.EnvVarSetting(nil), envVarSettings...)
+	}
+	return copied
+}
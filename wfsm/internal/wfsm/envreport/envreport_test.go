@@ -0,0 +1,210 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package envreport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cisco-eti/wfsm/internal"
+	"github.com/cisco-eti/wfsm/internal/wfsm/config"
+	"github.com/cisco-eti/wfsm/internal/wfsm/manifest"
+)
+
+func buildReport(t *testing.T, reveal bool) Report {
+	t.Setenv("AGENT_A_ENV_VAR_AGENT_A", "from_os")
+
+	builder := manifest.NewAgentSpecBuilder()
+	assert.NoError(t, builder.BuildAgentSpec(context.Background(), "../manifest/test/manifest_2/agent_A_manifest.json", "", nil, nil))
+	err := builder.LoadFromConfig(context.Background(), config.ConfigFile{
+		Config: map[string]config.AgentConfig{
+			"agent_A": {APIKey: "key_a", ID: "id_a", EnvVars: map[string]string{"ENV_VAR_AGENT_A": "from_config"}},
+			"agent_B_1": {
+				APIKey: "key_b",
+				ID:     "id_b",
+				Port:   8000,
+				EnvVars: map[string]string{
+					"AGENT_C_URL":   "{{ deps.agent_C_1.endpoint }}/api",
+					"AGENT_C_TOKEN": "{{deps.agent_C_1.apiKey}}",
+				},
+				InjectDependencyEnvVars: map[string]bool{"agent_C_1": false},
+			},
+			"agent_C_1": {APIKey: "key_c", ID: "id_c", EnvVars: map[string]string{"ENV_VAR_AGENT_C_1": "file://../manifest/test/secrets/agent_a_secret"}},
+		},
+	}, manifest.EnvFile{Values: map[string]string{"AGENT_B_1_ENV_VAR_AGENT_B_1": "from_env_file"}})
+	assert.NoError(t, err)
+	report, err := NewReport(builder, internal.DOCKER, reveal)
+	assert.NoError(t, err)
+	return report
+}
+
+func findEnvVar(t *testing.T, report Report, agentName string, envVarName string) EnvVar {
+	for _, agent := range report.Agents {
+		if agent.DeploymentName != agentName {
+			continue
+		}
+		for _, envVar := range agent.EnvVars {
+			if envVar.Name == envVarName {
+				return envVar
+			}
+		}
+	}
+	t.Fatalf("env var %s of agent %s not found", envVarName, agentName)
+	return EnvVar{}
+}
+
+func TestNewReport(t *testing.T) {
+	report := buildReport(t, true)
+
+	assert.Equal(t, "agent_A", report.Root)
+	assert.Len(t, report.Agents, 3)
+	assert.Equal(t, "agent_A", report.Agents[0].DeploymentName)
+
+	tests := []struct {
+		agent    string
+		name     string
+		expected EnvVar
+	}{
+		{
+			agent: "agent_A",
+			name:  "ENV_VAR_AGENT_A",
+			expected: EnvVar{
+				Name:          "ENV_VAR_AGENT_A",
+				Secret:        true,
+				EnvVarSetting: manifest.EnvVarSetting{Layer: manifest.EnvLayerConfig, Value: "from_config"},
+				Overridden: []manifest.EnvVarSetting{
+					{Layer: manifest.EnvLayerOSPrefixed, Source: "AGENT_A_ENV_VAR_AGENT_A", Value: "from_os"},
+				},
+			},
+		},
+		{
+			agent: "agent_B_1",
+			name:  "ENV_VAR_AGENT_B_1",
+			expected: EnvVar{
+				Name:          "ENV_VAR_AGENT_B_1",
+				EnvVarSetting: manifest.EnvVarSetting{Layer: manifest.EnvLayerEnvFilePrefixed, Source: "AGENT_B_1_ENV_VAR_AGENT_B_1", Value: "from_env_file"},
+				Overridden: []manifest.EnvVarSetting{
+					{Layer: manifest.EnvLayerManifestValues, Value: "env_var_value_agent_b_a1"},
+				},
+			},
+		},
+		{
+			agent: "agent_C_1",
+			name:  "ENV_VAR_AGENT_C_1",
+			expected: EnvVar{
+				Name: "ENV_VAR_AGENT_C_1",
+				EnvVarSetting: manifest.EnvVarSetting{
+					Layer:     manifest.EnvLayerConfig,
+					Value:     "secret_value_from_file",
+					Reference: "file://../manifest/test/secrets/agent_a_secret",
+				},
+				Overridden: []manifest.EnvVarSetting{
+					{Layer: manifest.EnvLayerManifestValues, Value: "env_var_value_agent_c_a1"},
+				},
+			},
+		},
+		{
+			agent: "agent_A",
+			name:  "AGENT_B_1_ENDPOINT",
+			expected: EnvVar{
+				Name:          "AGENT_B_1_ENDPOINT",
+				EnvVarSetting: manifest.EnvVarSetting{Layer: manifest.EnvLayerRunner, Value: "http://agent_B_1:8000"},
+			},
+		},
+		{
+			agent: "agent_B_1",
+			name:  "AGENT_C_URL",
+			expected: EnvVar{
+				Name:          "AGENT_C_URL",
+				EnvVarSetting: manifest.EnvVarSetting{Layer: manifest.EnvLayerConfig, Value: "http://agent_C_1:8000/api", Template: "{{ deps.agent_C_1.endpoint }}/api"},
+			},
+		},
+		{
+			agent: "agent_B_1",
+			name:  "AGENT_C_TOKEN",
+			expected: EnvVar{
+				Name:          "AGENT_C_TOKEN",
+				Secret:        true,
+				EnvVarSetting: manifest.EnvVarSetting{Layer: manifest.EnvLayerConfig, Value: "key_c", Template: "{{deps.agent_C_1.apiKey}}"},
+			},
+		},
+		{
+			agent: "agent_A",
+			name:  "API_KEY",
+			expected: EnvVar{
+				Name:          "API_KEY",
+				Secret:        true,
+				EnvVarSetting: manifest.EnvVarSetting{Layer: manifest.EnvLayerRunner, Value: "key_a"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.agent+"/"+tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, findEnvVar(t, report, tt.agent, tt.name))
+		})
+	}
+}
+
+func TestNewReport_OSEnvLayer(t *testing.T) {
+	t.Setenv("ENV_VAR_AGENT_A", "from_os")
+
+	builder := manifest.NewAgentSpecBuilder()
+	assert.NoError(t, builder.BuildAgentSpec(context.Background(), "../manifest/test/manifest_2/agent_A_manifest.json", "", nil, nil))
+	// OS env vars missing from the env file are reported as OS env, not as env file values
+	err := builder.LoadFromConfig(context.Background(), config.ConfigFile{}, manifest.EnvFile{Values: map[string]string{"OTHER": "value"}})
+	assert.NoError(t, err)
+	report, err := NewReport(builder, internal.DOCKER, true)
+	assert.NoError(t, err)
+
+	envVar := findEnvVar(t, report, "agent_A", "ENV_VAR_AGENT_A")
+	assert.Equal(t, manifest.EnvVarSetting{Layer: manifest.EnvLayerOS, Value: "from_os"}, envVar.EnvVarSetting)
+	assert.Empty(t, envVar.Overridden)
+}
+
+func TestNewReport_Redacted(t *testing.T) {
+	report := buildReport(t, false)
+
+	envVar := findEnvVar(t, report, "agent_A", "ENV_VAR_AGENT_A")
+	assert.Equal(t, internal.RedactedValue, envVar.Value)
+	assert.Equal(t, internal.RedactedValue, envVar.Overridden[0].Value)
+	assert.Equal(t, internal.RedactedValue, findEnvVar(t, report, "agent_A", "AGENT_B_1_API_KEY").Value)
+	assert.Equal(t, "from_env_file", findEnvVar(t, report, "agent_B_1", "ENV_VAR_AGENT_B_1").Value)
+	// API keys interpolated into env vars are redacted
+	assert.Equal(t, internal.RedactedValue, findEnvVar(t, report, "agent_B_1", "AGENT_C_TOKEN").Value)
+}
+
+func TestNewReport_InjectDependencyEnvVars(t *testing.T) {
+	report := buildReport(t, true)
+
+	for _, agent := range report.Agents {
+		for _, envVar := range agent.EnvVars {
+			if agent.DeploymentName == "agent_B_1" {
+				assert.NotContains(t, envVar.Name, "AGENT_C_1_", "env vars of agent_C_1 are disabled for agent_B_1")
+			}
+		}
+	}
+	assert.Equal(t, "http://agent_B_1:8000", findEnvVar(t, report, "agent_A", "AGENT_B_1_ENDPOINT").Value)
+}
+
+func TestReport_Render(t *testing.T) {
+	report := buildReport(t, false)
+
+	var text bytes.Buffer
+	assert.NoError(t, report.Render(&text, FormatText))
+	assert.Contains(t, text.String(), "agent_A:\n")
+	assert.Contains(t, text.String(), "  ENV_VAR_AGENT_A=<redacted>  [config]\n    overrides <redacted>  [OS env (prefixed), from AGENT_A_ENV_VAR_AGENT_A]\n")
+	assert.Contains(t, text.String(), "  ENV_VAR_AGENT_C_1=secret_value_from_file  [config, resolved from file://../manifest/test/secrets/agent_a_secret]\n")
+	assert.NotContains(t, text.String(), "key_a")
+
+	var out bytes.Buffer
+	assert.NoError(t, report.Render(&out, FormatJSON))
+	var decoded Report
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, report, decoded)
+
+	assert.Error(t, report.Render(&out, "yaml"))
+}
@@ -0,0 +1,72 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package envreport
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cisco-eti/wfsm/internal/wfsm/manifest"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Formats lists the supported output formats
+var Formats = []string{FormatText, FormatJSON}
+
+// Render writes the report in the given format
+func (r Report) Render(w io.Writer, format string) error {
+	switch format {
+	case FormatText, "":
+		return r.renderText(w)
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	default:
+		return fmt.Errorf("unsupported format %s, supported formats: %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// renderText writes the env vars of each agent with their layer, followed by the values they overrode
+func (r Report) renderText(w io.Writer) error {
+	var sb strings.Builder
+	for i, agent := range r.Agents {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(agent.DeploymentName + ":\n")
+		for _, envVar := range agent.EnvVars {
+			sb.WriteString("  " + envVar.Name + "=" + envVar.Value + "  " + describeSetting(envVar.EnvVarSetting) + "\n")
+			for j := len(envVar.Overridden) - 1; j >= 0; j-- {
+				overridden := envVar.Overridden[j]
+				sb.WriteString("    overrides " + overridden.Value + "  " + describeSetting(overridden) + "\n")
+			}
+		}
+		if len(agent.MissingEnvVars) > 0 {
+			sb.WriteString("  missing required env vars: " + strings.Join(agent.MissingEnvVars, ", ") + "\n")
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// describeSetting returns the layer of the setting with the variable, secret reference and template the value was read from
+func describeSetting(setting manifest.EnvVarSetting) string {
+	details := []string{string(setting.Layer)}
+	if setting.Source != "" {
+		details = append(details, "from "+setting.Source)
+	}
+	if setting.Reference != "" {
+		details = append(details, "resolved from "+setting.Reference)
+	}
+	if setting.Template != "" {
+		details = append(details, "interpolated from "+setting.Template)
+	}
+	return "[" + strings.Join(details, ", ") + "]"
+}
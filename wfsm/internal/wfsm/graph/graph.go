@@ -0,0 +1,166 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package graph
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cisco-eti/wfsm/internal"
+	"github.com/cisco-eti/wfsm/internal/builder/python"
+	"github.com/cisco-eti/wfsm/internal/wfsm/manifest"
+	"github.com/cisco-eti/wfsm/manifests"
+)
+
+const (
+	SourceTypeDocker     = "docker"
+	SourceTypeSourceCode = "source code"
+	SourceTypeRemote     = "remote"
+)
+
+// Graph is the resolved dependency graph of an agent deployment
+type Graph struct {
+	// Root is the deployment name of the main agent
+	Root  string `json:"root"`
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// Node is an agent of the deployment
+type Node struct {
+	DeploymentName   string `json:"deploymentName"`
+	AgentName        string `json:"agentName"`
+	Version          string `json:"version"`
+	ManifestPath     string `json:"manifestPath"`
+	DeploymentOption string `json:"deploymentOption"`
+	SourceType       string `json:"sourceType"`
+	// Image is the image used for docker deployments, or the image built for source code deployments (without tag)
+	Image     string `json:"image,omitempty"`
+	SourceURL string `json:"sourceUrl,omitempty"`
+	Framework string `json:"framework,omitempty"`
+	// Endpoint is the url of remote services
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// Edge is a dependency of an agent
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// InjectedEnvVars are set by the runner in the dependent agent to connect to the dependency,
+	// empty if disabled by injectDependencyEnvVars in the config of the dependent agent
+	InjectedEnvVars []string `json:"injectedEnvVars"`
+	// EnvVarValues are passed to the dependency by the manifest of the dependent agent, secret values are redacted
+	EnvVarValues map[string]string `json:"envVarValues,omitempty"`
+}
+
+// NewGraph creates the graph of the agent specs and dependencies resolved by the agent spec builder,
+// if reveal is set the values of secret env vars are not redacted
+func NewGraph(builder *manifest.AgentSpecBuilder, reveal bool) (Graph, error) {
+	g := Graph{
+		Root:  builder.DeploymentName,
+		Nodes: make([]Node, 0, len(builder.AgentSpecs)),
+		Edges: make([]Edge, 0),
+	}
+
+	deploymentNames := make([]string, 0, len(builder.AgentSpecs))
+	for name := range builder.AgentSpecs {
+		deploymentNames = append(deploymentNames, name)
+	}
+	// the main agent comes first, the others in alphabetical order
+	sort.Slice(deploymentNames, func(i, j int) bool {
+		if deploymentNames[i] == g.Root || deploymentNames[j] == g.Root {
+			return deploymentNames[i] == g.Root
+		}
+		return deploymentNames[i] < deploymentNames[j]
+	})
+
+	for _, name := range deploymentNames {
+		spec := builder.AgentSpecs[name]
+		deployment := manifest.GetDeployment(spec.Manifest)
+		if spec.SelectedDeploymentOption >= len(deployment.DeploymentOptions) {
+			return Graph{}, fmt.Errorf("agent %s has no deployment option %d", name, spec.SelectedDeploymentOption)
+		}
+		node := newNode(spec.Manifest, deployment.DeploymentOptions[spec.SelectedDeploymentOption], spec.SelectedDeploymentOption)
+		node.DeploymentName = name
+		node.ManifestPath = spec.ManifestPath
+		g.Nodes = append(g.Nodes, node)
+
+		for _, depName := range builder.Dependencies[name] {
+			g.Edges = append(g.Edges, newEdge(spec, builder.AgentSpecs[depName], deployment, reveal))
+		}
+	}
+	return g, nil
+}
+
+func newNode(agentManifest manifests.AgentManifest, option manifests.AgentDeploymentDeploymentOptionsInner, optionIdx int) Node {
+	node := Node{
+		AgentName:        agentManifest.Name,
+		Version:          agentManifest.Version,
+		DeploymentOption: fmt.Sprintf("#%d", optionIdx),
+	}
+	var name *string
+	switch {
+	case option.DockerDeployment != nil:
+		name = option.DockerDeployment.Name
+		node.SourceType = SourceTypeDocker
+		node.Image = option.DockerDeployment.Image
+	case option.SourceCodeDeployment != nil:
+		name = option.SourceCodeDeployment.Name
+		node.SourceType = SourceTypeSourceCode
+		node.Image = python.ImageName(agentManifest)
+		node.SourceURL = option.SourceCodeDeployment.Url
+		if framework, ok := option.SourceCodeDeployment.FrameworkConfig.GetActualInstance().(interface{ GetFrameworkType() string }); ok {
+			node.Framework = framework.GetFrameworkType()
+		}
+	case option.RemoteServiceDeployment != nil:
+		name = option.RemoteServiceDeployment.Name
+		node.SourceType = SourceTypeRemote
+		node.Endpoint = option.RemoteServiceDeployment.Protocol.Url
+	}
+	if name != nil && *name != "" {
+		node.DeploymentOption = *name
+	}
+	return node
+}
+
+func newEdge(from internal.AgentSpec, to internal.AgentSpec, deployment manifests.AgentDeployment, reveal bool) Edge {
+	edge := Edge{
+		From:            from.DeploymentName,
+		To:              to.DeploymentName,
+		InjectedEnvVars: from.InjectedDependencyEnvVarNames(to.DeploymentName),
+	}
+	if edge.InjectedEnvVars == nil {
+		edge.InjectedEnvVars = []string{}
+	}
+	for _, dependency := range deployment.AgentDeps {
+		if dependency.Name == to.DeploymentName && dependency.EnvVarValues != nil && len(dependency.EnvVarValues.Values) > 0 {
+			edge.EnvVarValues = dependency.EnvVarValues.Values
+			if !reveal {
+				// the values are env vars of the dependency
+				edge.EnvVarValues = to.RedactEnvVars(edge.EnvVarValues)
+			}
+		}
+	}
+	return edge
+}
+
+// DependenciesOf returns the edges starting at the given agent
+func (g Graph) DependenciesOf(deploymentName string) []Edge {
+	edges := make([]Edge, 0)
+	for _, edge := range g.Edges {
+		if edge.From == deploymentName {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+// Node returns the node of the given agent
+func (g Graph) Node(deploymentName string) (Node, bool) {
+	for _, node := range g.Nodes {
+		if node.DeploymentName == deploymentName {
+			return node, true
+		}
+	}
+	return Node{}, false
+}
@@ -0,0 +1,133 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cisco-eti/wfsm/internal"
+	"github.com/cisco-eti/wfsm/internal/wfsm/config"
+	"github.com/cisco-eti/wfsm/internal/wfsm/manifest"
+)
+
+func buildGraph(t *testing.T, manifestPath string) Graph {
+	builder := manifest.NewAgentSpecBuilder()
+	assert.NoError(t, builder.BuildAgentSpec(context.Background(), manifestPath, "", nil, nil))
+	g, err := NewGraph(builder, true)
+	assert.NoError(t, err)
+	return g
+}
+
+func TestNewGraph(t *testing.T) {
+	g := buildGraph(t, "../manifest/test/manifest_5/agent_A_manifest.json")
+
+	assert.Equal(t, "agent_A", g.Root)
+	assert.Len(t, g.Nodes, 3)
+	assert.Equal(t, "agent_A", g.Nodes[0].DeploymentName)
+
+	node, ok := g.Node("agent_B_1")
+	assert.True(t, ok)
+	assert.Equal(t, Node{
+		DeploymentName:   "agent_B_1",
+		AgentName:        "agent_B",
+		Version:          "1.0.0",
+		ManifestPath:     "../manifest/test/manifest_5/agent_B_manifest.json",
+		DeploymentOption: "src",
+		SourceType:       SourceTypeSourceCode,
+		Image:            "agntcy/wfsm-agent_B",
+		SourceURL:        "https://github.com/example/agent_B",
+		Framework:        "langgraph",
+	}, node)
+
+	edges := g.DependenciesOf("agent_A")
+	assert.Len(t, edges, 2)
+	assert.Equal(t, "agent_B_1", edges[0].To)
+	assert.Equal(t, []string{"AGENT_B_1_API_KEY", "AGENT_B_1_ID", "AGENT_B_1_ENDPOINT"}, edges[0].InjectedEnvVars)
+	assert.Equal(t, map[string]string{"ENV_VAR_AGENT_B_1": "env_var_value_agent_b_a1"}, edges[0].EnvVarValues)
+	// agent_C_1 is shared by agent_A and agent_B_1
+	assert.Len(t, g.DependenciesOf("agent_B_1"), 1)
+	assert.Len(t, g.DependenciesOf("agent_C_1"), 0)
+}
+
+func TestNewGraph_Config(t *testing.T) {
+	builder := manifest.NewAgentSpecBuilder()
+	assert.NoError(t, builder.BuildAgentSpec(context.Background(), "../manifest/test/manifest_5/agent_A_manifest.json", "", nil, nil))
+	builder.LoadGraphConfig(config.ConfigFile{Config: map[string]config.AgentConfig{
+		"agent_A":   {InjectDependencyEnvVars: map[string]bool{"agent_B_1": false}},
+		"agent_B_1": {SecretEnvVars: []string{"ENV_VAR_AGENT_B_1"}},
+	}})
+
+	g, err := NewGraph(builder, false)
+	assert.NoError(t, err)
+	edges := g.DependenciesOf("agent_A")
+	assert.Empty(t, edges[0].InjectedEnvVars)
+	assert.Equal(t, map[string]string{"ENV_VAR_AGENT_B_1": internal.RedactedValue}, edges[0].EnvVarValues)
+	assert.Equal(t, []string{"AGENT_C_1_API_KEY", "AGENT_C_1_ID", "AGENT_C_1_ENDPOINT"}, edges[1].InjectedEnvVars)
+
+	var out bytes.Buffer
+	assert.NoError(t, g.Render(&out, FormatText))
+	assert.Contains(t, out.String(), "injected into agent_A: none (disabled by injectDependencyEnvVars)\n")
+	assert.NotContains(t, out.String(), "env_var_value_agent_b_a1")
+
+	g, err = NewGraph(builder, true)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"ENV_VAR_AGENT_B_1": "env_var_value_agent_b_a1"}, g.DependenciesOf("agent_A")[0].EnvVarValues)
+}
+
+func TestGraph_Render(t *testing.T) {
+	g := buildGraph(t, "../manifest/test/manifest_5/agent_A_manifest.json")
+
+	tests := []struct {
+		format   string
+		contains []string
+	}{
+		{
+			format: FormatText,
+			contains: []string{
+				"agent_A [agent_A 1.0.0] source code (src) image: agntcy/wfsm-agent_A\n",
+				"├── agent_B_1 [agent_B 1.0.0] source code (src) image: agntcy/wfsm-agent_B\n",
+				"│   └── agent_C_1 [agent_C 1.0.0]",
+				"└── agent_C_1 [agent_C 1.0.0] source code (src) image: agntcy/wfsm-agent_C (shared, see above)\n",
+				"injected into agent_A: AGENT_B_1_API_KEY, AGENT_B_1_ID, AGENT_B_1_ENDPOINT\n",
+				"env vars from agent_A: ENV_VAR_AGENT_B_1=env_var_value_agent_b_a1\n",
+			},
+		},
+		{
+			format: FormatDot,
+			contains: []string{
+				"digraph \"agent_A\" {\n",
+				"\"agent_A\" -> \"agent_B_1\" [label=\"AGENT_B_1_API_KEY\\nAGENT_B_1_ID\\nAGENT_B_1_ENDPOINT\\nENV_VAR_AGENT_B_1=env_var_value_agent_b_a1\"];\n",
+			},
+		},
+		{
+			format: FormatMermaid,
+			contains: []string{
+				"graph TD\n",
+				"agent_B_1[\"agent_B_1<br/>agent_B 1.0.0<br/>source code (src)<br/>image: agntcy/wfsm-agent_B<br/>framework: langgraph\"]\n",
+				"agent_B_1 -->|",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			assert.NoError(t, g.Render(&out, tt.format))
+			for _, s := range tt.contains {
+				assert.Contains(t, out.String(), s)
+			}
+		})
+	}
+
+	var out bytes.Buffer
+	assert.NoError(t, g.Render(&out, FormatJSON))
+	var decoded Graph
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, g, decoded)
+
+	assert.ErrorContains(t, g.Render(&out, "svg"), "unsupported format svg")
+}
@@ -0,0 +1,182 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	FormatText    = "text"
+	FormatDot     = "dot"
+	FormatMermaid = "mermaid"
+	FormatJSON    = "json"
+)
+
+// Formats lists the supported output formats
+var Formats = []string{FormatText, FormatDot, FormatMermaid, FormatJSON}
+
+var mermaidIDPattern = regexp.MustCompile("[^a-zA-Z0-9_]")
+
+// Render writes the graph in the given format
+func (g Graph) Render(w io.Writer, format string) error {
+	switch format {
+	case FormatText, "":
+		return g.renderText(w)
+	case FormatDot:
+		return g.renderDot(w)
+	case FormatMermaid:
+		return g.renderMermaid(w)
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(g)
+	default:
+		return fmt.Errorf("unsupported format %s, supported formats: %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// renderText writes the dependency tree, dependencies shared by several agents are expanded only at their first occurrence
+func (g Graph) renderText(w io.Writer) error {
+	var sb strings.Builder
+	root, ok := g.Node(g.Root)
+	if !ok {
+		return fmt.Errorf("main agent %s not found in graph", g.Root)
+	}
+	sb.WriteString(root.summary() + "\n")
+	g.writeTextDependencies(&sb, g.Root, "", map[string]bool{g.Root: true})
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (g Graph) writeTextDependencies(sb *strings.Builder, deploymentName string, indent string, expanded map[string]bool) {
+	edges := g.DependenciesOf(deploymentName)
+	for i, edge := range edges {
+		branch, childIndent := "├── ", indent+"│   "
+		if i == len(edges)-1 {
+			branch, childIndent = "└── ", indent+"    "
+		}
+		node, _ := g.Node(edge.To)
+		summary := node.summary()
+		if expanded[edge.To] {
+			summary += " (shared, see above)"
+		}
+		sb.WriteString(indent + branch + summary + "\n")
+		sb.WriteString(childIndent + "  injected into " + edge.From + ": " + edge.injected() + "\n")
+		if len(edge.EnvVarValues) > 0 {
+			sb.WriteString(childIndent + "  env vars from " + edge.From + ": " + formatEnvVarValues(edge.EnvVarValues, ", ") + "\n")
+		}
+		if !expanded[edge.To] {
+			expanded[edge.To] = true
+			g.writeTextDependencies(sb, edge.To, childIndent, expanded)
+		}
+	}
+}
+
+func (g Graph) renderDot(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("digraph %s {\n", dotQuote(g.Root)))
+	sb.WriteString("  rankdir=LR;\n")
+	sb.WriteString("  node [shape=box];\n")
+	for _, node := range g.Nodes {
+		sb.WriteString(fmt.Sprintf("  %s [label=%s];\n", dotQuote(node.DeploymentName), dotQuote(strings.Join(node.details(), "\n"))))
+	}
+	for _, edge := range g.Edges {
+		sb.WriteString(fmt.Sprintf("  %s -> %s [label=%s];\n", dotQuote(edge.From), dotQuote(edge.To), dotQuote(strings.Join(edge.labels(), "\n"))))
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (g Graph) renderMermaid(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("graph TD\n")
+	for _, node := range g.Nodes {
+		sb.WriteString(fmt.Sprintf("  %s[%s]\n", mermaidID(node.DeploymentName), mermaidQuote(strings.Join(node.details(), "<br/>"))))
+	}
+	for _, edge := range g.Edges {
+		sb.WriteString(fmt.Sprintf("  %s -->|%s| %s\n", mermaidID(edge.From), mermaidQuote(strings.Join(edge.labels(), "<br/>")), mermaidID(edge.To)))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (n Node) summary() string {
+	summary := fmt.Sprintf("%s [%s %s] %s (%s)", n.DeploymentName, n.AgentName, n.Version, n.SourceType, n.DeploymentOption)
+	if n.Image != "" {
+		summary += " image: " + n.Image
+	}
+	if n.Endpoint != "" {
+		summary += " endpoint: " + n.Endpoint
+	}
+	return summary
+}
+
+func (n Node) details() []string {
+	details := []string{
+		n.DeploymentName,
+		fmt.Sprintf("%s %s", n.AgentName, n.Version),
+		fmt.Sprintf("%s (%s)", n.SourceType, n.DeploymentOption),
+	}
+	if n.Image != "" {
+		details = append(details, "image: "+n.Image)
+	}
+	if n.Framework != "" {
+		details = append(details, "framework: "+n.Framework)
+	}
+	if n.Endpoint != "" {
+		details = append(details, "endpoint: "+n.Endpoint)
+	}
+	return details
+}
+
+// injected lists the injected env vars of the edge
+func (e Edge) injected() string {
+	if len(e.InjectedEnvVars) == 0 {
+		return "none (disabled by injectDependencyEnvVars)"
+	}
+	return strings.Join(e.InjectedEnvVars, ", ")
+}
+
+func (e Edge) labels() []string {
+	labels := append([]string{}, e.InjectedEnvVars...)
+	if len(e.EnvVarValues) > 0 {
+		labels = append(labels, formatEnvVarValues(e.EnvVarValues, "\n"))
+	}
+	return labels
+}
+
+func formatEnvVarValues(values map[string]string, sep string) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+values[key])
+	}
+	return strings.Join(pairs, sep)
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+func mermaidID(s string) string {
+	return mermaidIDPattern.ReplaceAllString(s, "_")
+}
+
+func mermaidQuote(s string) string {
+	s = strings.ReplaceAll(s, "\n", "<br/>")
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
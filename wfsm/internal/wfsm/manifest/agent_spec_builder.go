@@ -3,18 +3,21 @@
 package manifest
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 
 	"github.com/rs/zerolog"
 
 	"github.com/cisco-eti/wfsm/internal"
+	"github.com/cisco-eti/wfsm/internal/cache"
+	"github.com/cisco-eti/wfsm/internal/secrets"
 	"github.com/cisco-eti/wfsm/internal/util"
 	"github.com/cisco-eti/wfsm/internal/wfsm/config"
 	"github.com/cisco-eti/wfsm/manifests"
@@ -25,20 +28,67 @@ type AgentSpecBuilder struct {
 	DeploymentName string
 	AgentSpecs     map[string]internal.AgentSpec
 	Dependencies   map[string][]string
+	// Cache stores remote manifests, if nil manifests are fetched on every build
+	Cache *cache.Cache
+	// Lock pins the manifests of remote dependencies, if nil dependencies are resolved on every build
+	Lock *LockFile
+	// Secrets resolves secret references in env var values and API keys, if nil the default resolvers are used
+	Secrets *secrets.Resolver
+
+	requests      map[string]dependencyRequest
+	versionLister versionLister
+	// envSettings records the settings of the env vars of each agent in LoadFromConfig
+	envSettings map[string]map[string][]EnvVarSetting
 }
 
 func NewAgentSpecBuilder() *AgentSpecBuilder {
 	return &AgentSpecBuilder{
 		AgentSpecs:   make(map[string]internal.AgentSpec),
 		Dependencies: make(map[string][]string),
+		requests:     make(map[string]dependencyRequest),
+		envSettings:  make(map[string]map[string][]EnvVarSetting),
 	}
 }
 
 // BuildAgentSpec builds the agent spec from the given manifest recursively for all dependencies of the agent.
 // Environment values are merged from the manifest and the env var values passed in.
+// A dependency referenced by several agents with the same manifest and deployment option is deployed once and shared,
+// cycles and conflicting definitions of a dependency are reported as errors.
 func (a *AgentSpecBuilder) BuildAgentSpec(ctx context.Context, manifestPath string, deploymentName string, selectedDeploymentOption *string, envVarValues *manifests.EnvVarValues) error {
+	return a.buildAgentSpec(ctx, manifestPath, deploymentName, selectedDeploymentOption, envVarValues, nil)
+}
+
+// buildAgentSpec resolves the dependency graph depth first, path holds the deployment names from the main agent to the current one
+func (a *AgentSpecBuilder) buildAgentSpec(ctx context.Context, manifestPath string, deploymentName string, selectedDeploymentOption *string, envVarValues *manifests.EnvVarValues, path []string) error {
+	requestedBy := ""
+	if len(path) > 0 {
+		requestedBy = path[len(path)-1]
+	}
+	request := newDependencyRequest(requestedBy, manifestPath, selectedDeploymentOption)
+
+	if deploymentName != "" {
+		for _, name := range path {
+			if name == deploymentName {
+				cyclePath := append(append([]string{}, path...), deploymentName)
+				return &DependencyCycleError{Path: cyclePath}
+			}
+		}
+		// the dependency is already resolved through another agent
+		if _, ok := a.AgentSpecs[deploymentName]; ok {
+			first := a.requests[deploymentName]
+			if reason := first.conflictsWith(request); reason != "" {
+				return &DependencyConflictError{
+					DeploymentName: deploymentName,
+					First:          first,
+					Second:         request,
+					Reason:         reason,
+				}
+			}
+			return a.shareDependency(deploymentName, request, envVarValues)
+		}
+	}
 
-	manifestLoader, err := LoaderFactory(manifestPath)
+	manifestLoader, err := LoaderFactory(manifestPath, a.Cache)
 	if err != nil {
 		return fmt.Errorf("failed to create manifest loader: %s", err)
 	}
@@ -63,11 +113,6 @@ func (a *AgentSpecBuilder) BuildAgentSpec(ctx context.Context, manifestPath stri
 		a.DeploymentName = deploymentName
 	}
 
-	// check deployment name is unique among dependencies
-	if _, ok := a.AgentSpecs[deploymentName]; ok {
-		return fmt.Errorf("agent deployment name must be unique: %s", deploymentName)
-	}
-
 	if envVarValues == nil {
 		envVarValues = &manifests.EnvVarValues{
 			Values: make(map[string]string),
@@ -84,8 +129,12 @@ func (a *AgentSpecBuilder) BuildAgentSpec(ctx context.Context, manifestPath stri
 		SelectedDeploymentOption: selectedDeploymentOptionIdx,
 		EnvVars:                  envVarValues.Values,
 		ManifestPath:             manifestPath,
+		SecretEnvVars:            getSecretEnvVars(manifest),
 	}
 	a.AgentSpecs[deploymentName] = agentSpec
+	request.digest = manifestSvc.GetDigest()
+	a.requests[deploymentName] = request
+	path = append(path, deploymentName)
 
 	deployment := GetDeployment(manifest)
 	if len(deployment.AgentDeps) > 0 {
@@ -93,27 +142,102 @@ func (a *AgentSpecBuilder) BuildAgentSpec(ctx context.Context, manifestPath stri
 		for _, dependency := range deployment.AgentDeps {
 			depNames = append(depNames, dependency.Name)
 
-			if dependency.Ref.Url == nil {
-				return fmt.Errorf("ref url is required for dependency: %s", dependency.Name)
-			}
-
 			// merge env vars
 			dependency.EnvVarValues = mergeEnvVarValues(dependency.EnvVarValues, *envVarValues, dependency.Name)
 
-			normalizedManifestPath, nErr := a.NormalizeDependencyRef(manifestPath, *dependency.Ref.Url)
-			if nErr != nil {
-				return fmt.Errorf("failed to normalize manifest path for dependent agent: %s", nErr)
+			depManifestPath, locked, rErr := a.resolveDependencyRef(ctx, manifestPath, deploymentName, dependency)
+			if rErr != nil {
+				return rErr
 			}
 
-			if err = a.BuildAgentSpec(ctx, normalizedManifestPath, dependency.Name, dependency.DeploymentOption, dependency.EnvVarValues); err != nil {
+			if err = a.buildAgentSpec(ctx, depManifestPath, dependency.Name, dependency.DeploymentOption, dependency.EnvVarValues, path); err != nil {
+				if isDependencyGraphError(err) {
+					return err
+				}
 				return fmt.Errorf("failed building spec for dependent agent: %s", err)
 			}
+
+			if err = a.lockDependency(ctx, deploymentName, dependency, depManifestPath, locked); err != nil {
+				return err
+			}
 		}
 		a.Dependencies[deploymentName] = depNames
 	}
 	return nil
 }
 
+func isDependencyGraphError(err error) bool {
+	var cycleErr *DependencyCycleError
+	var conflictErr *DependencyConflictError
+	var versionErr *VersionConstraintError
+	return errors.As(err, &cycleErr) || errors.As(err, &conflictErr) || errors.As(err, &versionErr)
+}
+
+// resolveDependencyRef returns the manifest path of a dependency. Dependencies pinned in the lock file use the locked manifest,
+// dependencies referenced by name are resolved to the newest version satisfying the version constraint of the reference.
+func (a *AgentSpecBuilder) resolveDependencyRef(ctx context.Context, manifestPath string, deploymentName string, dependency manifests.AgentDependency) (string, *LockedDependency, error) {
+	if locked, ok := a.Lock.lookup(lockKey(deploymentName, dependency.Name), dependency.Ref); ok {
+		return locked.Resolved, &locked, nil
+	}
+
+	if needsVersionResolution(dependency.Ref) {
+		resolved, err := a.resolveVersion(ctx, dependency.Ref)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to resolve version of dependent agent %s: %s", dependency.Name, err)
+		}
+		return resolved, nil, nil
+	}
+
+	normalizedManifestPath, err := a.NormalizeDependencyRef(manifestPath, *dependency.Ref.Url)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to normalize manifest path for dependent agent: %s", err)
+	}
+	return normalizedManifestPath, nil, nil
+}
+
+// lockDependency checks the loaded dependency manifest against the version constraint of the reference and the lock file,
+// and pins remote manifests in the lock file
+func (a *AgentSpecBuilder) lockDependency(ctx context.Context, deploymentName string, dependency manifests.AgentDependency, depManifestPath string, locked *LockedDependency) error {
+	spec := a.AgentSpecs[dependency.Name]
+	digest := a.requests[dependency.Name].digest
+
+	if err := checkVersionConstraint(dependency.Name, dependency.Ref.Version, spec.Manifest.Version); err != nil {
+		var versionErr *VersionConstraintError
+		if !errors.As(err, &versionErr) || needsVersionResolution(dependency.Ref) || strictVersions() {
+			return err
+		}
+		// the version of references with url used to be ignored, mismatches are reported without failing
+		// until the manifests are migrated
+		zerolog.Ctx(ctx).Warn().Msgf("%s, the version of dependencies with url will be enforced in a future release "+
+			"(set %s=true to enforce it now)", err, strictVersionsEnv)
+	}
+	if locked != nil {
+		if locked.Digest != digest {
+			return fmt.Errorf("manifest of agent dependency %s (%s) does not match the digest in %s, run 'wfsm deps update' to accept the change",
+				dependency.Name, depManifestPath, a.Lock.Path())
+		}
+		return nil
+	}
+	if !isRemoteManifest(depManifestPath) {
+		// local manifests are part of the project and are not pinned
+		return nil
+	}
+	a.Lock.set(lockKey(deploymentName, dependency.Name), LockedDependency{
+		Name:            dependency.Ref.Name,
+		Version:         dependency.Ref.Version,
+		Url:             dependency.Ref.GetUrl(),
+		Resolved:        depManifestPath,
+		ResolvedVersion: spec.Manifest.Version,
+		Digest:          digest,
+	})
+	return nil
+}
+
+func isRemoteManifest(manifestPath string) bool {
+	u, err := url.Parse(manifestPath)
+	return err == nil && u.Scheme != "" && u.Scheme != "file"
+}
+
 // NormalizeDependencyRef normalizes the manifest path for the agent spec builder
 func (a *AgentSpecBuilder) NormalizeDependencyRef(manifestPath string, dependencyRefPath string) (string, error) {
 	parsedRef, err := url.Parse(dependencyRefPath)
@@ -141,7 +265,13 @@ func (a *AgentSpecBuilder) NormalizeDependencyRef(manifestPath string, dependenc
 
 }
 
-func (a *AgentSpecBuilder) LoadFromConfig(ctx context.Context, configFile config.ConfigFile, envFile map[string]string) {
+// LoadFromConfig sets the config of the agents and merges their env vars from the manifest defaults, the OS env,
+// the env file and the config. Secret references (e.g. vault://secret/data/app#OPENAI_API_KEY) in env var values
+// and API keys are resolved afterwards, so the config and env files need not contain the secrets themselves.
+// Template expressions in env var values are kept, they are interpolated once the agents are built (see platforms.InterpolateEnvVars).
+func (a *AgentSpecBuilder) LoadFromConfig(ctx context.Context, configFile config.ConfigFile, envFile EnvFile) error {
+	envFileValues := a.envFileValues(ctx, envFile.EnvVarValues)
+	var configErrs []error
 	for agentName, agentSpec := range a.AgentSpecs {
 		agentConfig := configFile.Config[agentName]
 		agentSpec.AgentID = agentConfig.ID
@@ -150,38 +280,129 @@ func (a *AgentSpecBuilder) LoadFromConfig(ctx context.Context, configFile config
 		if agentConfig.K8sConfig != nil {
 			agentSpec.K8sConfig = *agentConfig.K8sConfig
 		}
+		agentSpec.Dockerfile = agentConfig.Dockerfile
 
-		// configure env vars in order or precedence
+		if agentSpec.EnvVars == nil {
+			agentSpec.EnvVars = make(map[string]string)
+		}
+		// configure env vars in order or precedence, the source of each value is recorded
+		env := newEnvRecorder(agentSpec)
 		localEnvVars := getLocalEnvs()
 		// set declared env vars from local env
-		setDeclaredEnvVars(agentSpec, localEnvVars)
+		setDeclaredEnvVars(env, localEnvVars, EnvLayerOS)
 		// set prefixed env vars from local env
-		setPrefixedEnvVars(agentSpec, localEnvVars)
+		setPrefixedEnvVars(env, localEnvVars, EnvLayerOSPrefixed)
 
 		// set declared env vars from env file
-		setDeclaredEnvVars(agentSpec, envFile)
+		setDeclaredEnvVars(env, envFile.Values, EnvLayerEnvFile)
 		// set prefixed env vars from env file
-		setPrefixedEnvVars(agentSpec, envFile)
+		setPrefixedEnvVars(env, envFile.Values, EnvLayerEnvFilePrefixed)
+		// set env vars of the agent from yaml env file
+		env.setAll(envFileValues[agentName], EnvLayerEnvFileYAML)
 
 		// set env vars from config
-		agentSpec.EnvVars = util.MergeMaps(agentSpec.EnvVars, agentConfig.EnvVars)
+		env.setAll(agentConfig.EnvVars, EnvLayerConfig)
+
+		setDefaultsForEnvVars(env)
+		a.envSettings[agentName] = env.settings
+		for _, name := range agentConfig.SecretEnvVars {
+			if !slices.Contains(agentSpec.SecretEnvVars, name) {
+				agentSpec.SecretEnvVars = append(agentSpec.SecretEnvVars, name)
+			}
+		}
+		for depName := range agentConfig.InjectDependencyEnvVars {
+			if !slices.Contains(a.Dependencies[agentName], depName) {
+				configErrs = append(configErrs, fmt.Errorf("injectDependencyEnvVars of agent %s: %s is not a dependency of the agent", agentName, depName))
+			}
+		}
+		agentSpec.InjectDependencyEnvVars = agentConfig.InjectDependencyEnvVars
+
+		a.AgentSpecs[agentName] = agentSpec
+	}
+	if len(configErrs) > 0 {
+		return errors.Join(configErrs...)
+	}
+	return a.resolveSecrets(ctx)
+}
 
-		setDefaultsForEnvVars(ctx, agentSpec)
+// LoadBuildConfig sets the config of the agents needed to build their images only,
+// without the env vars and secrets needed to deploy them
+func (a *AgentSpecBuilder) LoadBuildConfig(configFile config.ConfigFile) {
+	for agentName, agentSpec := range a.AgentSpecs {
+		agentSpec.Dockerfile = configFile.Config[agentName].Dockerfile
+		a.AgentSpecs[agentName] = agentSpec
+	}
+}
 
+// LoadGraphConfig sets the config of the agents which changes their dependency graph: the dependency env vars injected
+// by the runners and the env vars marked secret, without resolving env vars and secrets
+func (a *AgentSpecBuilder) LoadGraphConfig(configFile config.ConfigFile) {
+	for agentName, agentSpec := range a.AgentSpecs {
+		agentConfig := configFile.Config[agentName]
+		for _, name := range agentConfig.SecretEnvVars {
+			if !slices.Contains(agentSpec.SecretEnvVars, name) {
+				agentSpec.SecretEnvVars = append(agentSpec.SecretEnvVars, name)
+			}
+		}
+		agentSpec.InjectDependencyEnvVars = agentConfig.InjectDependencyEnvVars
 		a.AgentSpecs[agentName] = agentSpec
 	}
 }
 
+// resolveSecrets replaces the secret references in env var values and API keys of all agents,
+// errors are collected for all agents
+func (a *AgentSpecBuilder) resolveSecrets(ctx context.Context) error {
+	if a.Secrets == nil {
+		a.Secrets = secrets.NewResolver()
+	}
+
+	var errs []error
+	for _, agentName := range a.sortedAgentNames() {
+		agentSpec := a.AgentSpecs[agentName]
+		for envVarName, value := range agentSpec.EnvVars {
+			resolved, err := a.Secrets.Resolve(ctx, value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("env var %s of agent %s: %w", envVarName, agentName, err))
+				continue
+			}
+			agentSpec.EnvVars[envVarName] = resolved
+			if settings := a.envSettings[agentName][envVarName]; resolved != value && len(settings) > 0 {
+				settings[len(settings)-1].Value = resolved
+				if a.Secrets.IsReference(value) {
+					settings[len(settings)-1].Reference = value
+				}
+			}
+		}
+		resolved, err := a.Secrets.Resolve(ctx, agentSpec.ApiKey)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("api key of agent %s: %w", agentName, err))
+		} else {
+			agentSpec.ApiKey = resolved
+		}
+		a.AgentSpecs[agentName] = agentSpec
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateEnvVars validates the env vars of all agents against the env var definitions of their manifests
+// (required env vars and the constraints in their annotations), errors are collected for all agents
 func (a *AgentSpecBuilder) ValidateEnvVars(ctx context.Context) []error {
 	errs := make([]error, 0)
-	for _, spec := range a.AgentSpecs {
-		if err := validateAgentEnvVars(ctx, spec); err != nil {
-			errs = append(errs, validateAgentEnvVars(ctx, spec))
-		}
+	for _, agentName := range a.sortedAgentNames() {
+		errs = append(errs, ValidateAgentEnvVars(a.AgentSpecs[agentName])...)
 	}
 	return errs
 }
 
+func (a *AgentSpecBuilder) sortedAgentNames() []string {
+	agentNames := make([]string, 0, len(a.AgentSpecs))
+	for agentName := range a.AgentSpecs {
+		agentNames = append(agentNames, agentName)
+	}
+	sort.Strings(agentNames)
+	return agentNames
+}
+
 func getLocalEnvs() map[string]string {
 	// Get the environment variables
 	envVars := os.Environ()
@@ -201,62 +422,45 @@ func getLocalEnvs() map[string]string {
 }
 
 // set env vars for the agent spec which are prefixed with the agent name
-func setPrefixedEnvVars(inputSpec internal.AgentSpec, envVars map[string]string) {
-	agentPrefix := util.CalculateEnvVarPrefix(inputSpec.DeploymentName)
+func setPrefixedEnvVars(env *envRecorder, envVars map[string]string, layer EnvVarLayer) {
+	agentPrefix := util.CalculateEnvVarPrefix(env.spec.DeploymentName)
 	for key, value := range envVars {
 		if strings.HasPrefix(key, agentPrefix) {
 			envVarName := strings.TrimPrefix(key, agentPrefix)
-			inputSpec.EnvVars[envVarName] = value
+			env.set(envVarName, value, layer, key)
 		}
 	}
 }
 
-// set env vars for the agent spec which are declared in the agent manifest
-func setDeclaredEnvVars(inputSpec internal.AgentSpec, envFile map[string]string) {
-	deployment := GetDeployment(inputSpec.Manifest)
+// set env vars for the agent spec which are declared in the agent manifest, envVars is the source of the given
+// layer only (OS env or env file), so that the layer recorded for each value is the one it was read from
+func setDeclaredEnvVars(env *envRecorder, envVars map[string]string, layer EnvVarLayer) {
+	deployment := GetDeployment(env.spec.Manifest)
 	for _, envVarDefs := range deployment.EnvVars {
-		if value := getEnvVarValue(envVarDefs.GetName(), envFile); value != "" {
-			inputSpec.EnvVars[envVarDefs.GetName()] = value
+		if value := envVars[envVarDefs.GetName()]; value != "" {
+			env.set(envVarDefs.GetName(), value, layer, "")
 		}
 	}
 }
 
-func getEnvVarValue(envVarName string, envFile map[string]string) string {
-	if value, ok := envFile[envVarName]; ok {
-		return value
-	}
-	if value := os.Getenv(envVarName); value != "" {
-		return value
-	}
-	return ""
-}
-
-func setDefaultsForEnvVars(ctx context.Context, inputSpec internal.AgentSpec) {
-	deployment := GetDeployment(inputSpec.Manifest)
-	for _, envVarDefs := range deployment.EnvVars {
-		if inputSpec.EnvVars[envVarDefs.GetName()] == "" && envVarDefs.HasDefaultValue() {
-			inputSpec.EnvVars[envVarDefs.GetName()] = envVarDefs.GetDefaultValue()
+// getSecretEnvVars returns the env vars annotated as secret in the manifest
+func getSecretEnvVars(manifest manifests.AgentManifest) []string {
+	var secretEnvVars []string
+	for _, envVarDef := range GetDeployment(manifest).EnvVars {
+		if envVarDef.GetAnnotations()[internal.SecretEnvVarAnnotation] == "true" {
+			secretEnvVars = append(secretEnvVars, envVarDef.GetName())
 		}
 	}
+	return secretEnvVars
 }
 
-func validateAgentEnvVars(ctx context.Context, inputSpec internal.AgentSpec) error {
-	log := zerolog.Ctx(ctx)
-	// validate that all required env vars are present in inputSpec.EnvVars
-	deployment := GetDeployment(inputSpec.Manifest)
+func setDefaultsForEnvVars(env *envRecorder) {
+	deployment := GetDeployment(env.spec.Manifest)
 	for _, envVarDefs := range deployment.EnvVars {
-		if envVarDefs.GetRequired() {
-			if _, ok := inputSpec.EnvVars[envVarDefs.GetName()]; !ok {
-				if envVarDefs.HasDefaultValue() {
-					log.Warn().Msgf("agent %s config is missing required env var %s, using default value %s", inputSpec.DeploymentName, envVarDefs.GetName(), envVarDefs.GetDefaultValue())
-					inputSpec.EnvVars[envVarDefs.GetName()] = envVarDefs.GetDefaultValue()
-				} else {
-					return fmt.Errorf("missing required env var %s", envVarDefs.GetName())
-				}
-			}
+		if env.spec.EnvVars[envVarDefs.GetName()] == "" && envVarDefs.HasDefaultValue() {
+			env.set(envVarDefs.GetName(), envVarDefs.GetDefaultValue(), EnvLayerManifestDefault, "")
 		}
 	}
-	return nil
 }
 
 func mergeEnvVarValues(dest *manifests.EnvVarValues, src manifests.EnvVarValues, dependencyName string) *manifests.EnvVarValues {
@@ -287,43 +491,3 @@ func mergeDepEnvVarValues(dest []manifests.EnvVarValues, src []manifests.EnvVarV
 	}
 	return dest
 }
-
-func LoadEnvVars(envFilePath string) (map[string]string, error) {
-	envVars := make(map[string]string)
-	if envFilePath == "" {
-		return envVars, nil
-	}
-
-	file, err := os.Open(envFilePath)
-	if err != nil {
-		return nil, errors.New("failed to open env file")
-	}
-	defer file.Close()
-
-	// Read the file line by line
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		// Skip empty lines or comments
-		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
-			continue
-		}
-
-		// Split the line into key and value
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid line in env file: %s", line)
-		}
-
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-		envVars[key] = value
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, errors.New("failed to read env file")
-	}
-
-	return envVars, nil
-}
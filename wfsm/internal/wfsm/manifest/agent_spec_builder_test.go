@@ -104,12 +104,33 @@ func TestAgentSpecBuilder_BuildAgentSpec_WithEnvVars(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:         "Test with agent_A_manifest.json with yaml env file",
+			manifestPath: "test/manifest_2/agent_A_manifest.json",
+			envFilePath:  "test/manifest_2/env-vars.yaml",
+			config: config.ConfigFile{
+				Config: map[string]config.AgentConfig{},
+			},
+			expectedAgentDeploymentEnvVars: map[string]map[string]string{
+				"agent_A": {
+					"ENV_VAR_AGENT_A": "env_var_value_agent_a_from_yaml",
+				},
+				"agent_B_1": {
+					"ENV_VAR_AGENT_B_1": "env_var_value_agent_b_1_from_yaml",
+					"ENV_VAR_AGENT_B_2": "env_var_value_agent_b_a2",
+				},
+				"agent_C_1": {
+					"ENV_VAR_AGENT_C_1": "env_var_value_agent_c_1_from_yaml",
+					"ENV_VAR_AGENT_C_2": "env_var_value_agent_c_a2",
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			envFile, err := LoadEnvVars(tt.envFilePath)
-			assert.NoError(t, err, "LoadEnvVars should not return an error")
+			envFile, err := LoadEnvFile(tt.envFilePath)
+			assert.NoError(t, err, "LoadEnvFile should not return an error")
 
 			builder := NewAgentSpecBuilder()
 			err = builder.BuildAgentSpec(context.Background(), tt.manifestPath, "", nil, nil)
@@ -118,7 +139,7 @@ func TestAgentSpecBuilder_BuildAgentSpec_WithEnvVars(t *testing.T) {
 			if tt.localEnv != nil {
 				setLocalEnvVars(tt.localEnv)
 			}
-			builder.LoadFromConfig(context.Background(), tt.config, envFile)
+			assert.NoError(t, builder.LoadFromConfig(context.Background(), tt.config, envFile))
 
 			for agentDepName, envVarValues := range tt.expectedAgentDeploymentEnvVars {
 				for key, value := range envVarValues {
@@ -135,22 +156,23 @@ func TestAgentSpecBuilder_Required_Env_Var_Missing(t *testing.T) {
 	manifestPath := "test/manifest_3/agent_A_manifest.json"
 	envFilePath := "test/manifest_3/env-vars"
 
-	envFile, err := LoadEnvVars(envFilePath)
-	assert.NoError(t, err, "LoadEnvVars should not return an error")
+	envFile, err := LoadEnvFile(envFilePath)
+	assert.NoError(t, err, "LoadEnvFile should not return an error")
 
 	builder := NewAgentSpecBuilder()
 	err = builder.BuildAgentSpec(context.Background(), manifestPath, "", nil, nil)
 	assert.NoError(t, err, "BuildAgentSpec should not return an error")
 
-	builder.LoadFromConfig(context.Background(), config.ConfigFile{
+	err = builder.LoadFromConfig(context.Background(), config.ConfigFile{
 		Config: map[string]config.AgentConfig{},
 	}, envFile)
+	assert.NoError(t, err, "LoadFromConfig should not return an error")
 	errs := builder.ValidateEnvVars(context.Background())
 
 	assert.Len(t, errs, 1, "ValidateEnvVars should return one error")
 }
 
-func TestAgentSpecBuilder_Deployment_Name_Non_Unique(t *testing.T) {
+func TestAgentSpecBuilder_Dependency_Cycle(t *testing.T) {
 
 	manifestPath := "test/manifest_4/agent_A_manifest.json"
 
@@ -158,7 +180,45 @@ func TestAgentSpecBuilder_Deployment_Name_Non_Unique(t *testing.T) {
 	err := builder.BuildAgentSpec(context.Background(), manifestPath, "", nil, nil)
 
 	assert.Error(t, err, "BuildAgentSpec should return an error")
-	assert.Contains(t, err.Error(), "agent deployment name must be unique: agent_C_1")
+	var cycleErr *DependencyCycleError
+	assert.ErrorAs(t, err, &cycleErr)
+	assert.Equal(t, "dependency cycle detected: agent_A -> agent_B_1 -> agent_C_1 -> agent_C_1", err.Error())
+}
+
+func TestAgentSpecBuilder_Shared_Dependency(t *testing.T) {
+
+	manifestPath := "test/manifest_5/agent_A_manifest.json"
+
+	builder := NewAgentSpecBuilder()
+	err := builder.BuildAgentSpec(context.Background(), manifestPath, "", nil, nil)
+	assert.NoError(t, err, "BuildAgentSpec should not return an error")
+
+	assert.Len(t, builder.AgentSpecs, 3, "shared dependency should be deployed once")
+	assert.Equal(t, map[string][]string{
+		"agent_A":   {"agent_B_1", "agent_C_1"},
+		"agent_B_1": {"agent_C_1"},
+	}, builder.Dependencies)
+	// env var values of both references are merged
+	assert.Equal(t, map[string]string{
+		"ENV_VAR_AGENT_C_1": "env_var_value_agent_c_a1",
+		"ENV_VAR_AGENT_C_2": "env_var_value_agent_c_b2",
+	}, builder.AgentSpecs["agent_C_1"].EnvVars)
+}
+
+func TestAgentSpecBuilder_Conflicting_Dependency(t *testing.T) {
+
+	manifestPath := "test/manifest_6/agent_A_manifest.json"
+
+	builder := NewAgentSpecBuilder()
+	err := builder.BuildAgentSpec(context.Background(), manifestPath, "", nil, nil)
+
+	assert.Error(t, err, "BuildAgentSpec should return an error")
+	var conflictErr *DependencyConflictError
+	assert.ErrorAs(t, err, &conflictErr)
+	assert.Equal(t, "agent_C_1", conflictErr.DeploymentName)
+	assert.Contains(t, err.Error(), "different manifest references")
+	assert.Contains(t, err.Error(), "referenced by agent_B_1 as test/manifest_6/agent_D_manifest.json")
+	assert.Contains(t, err.Error(), "referenced by agent_A as test/manifest_6/agent_C_manifest.json")
 }
 
 func setLocalEnvVars(envVars map[string]string) {
@@ -269,3 +329,49 @@ func TestAgentSpecBuilder_NormalizeManifestPath(t *testing.T) {
 		})
 	}
 }
+
+func TestAgentSpecBuilder_LoadFromConfig_Secret_References(t *testing.T) {
+	t.Setenv("WFSM_TEST_AGENT_B_SECRET", "secret_value_from_env")
+
+	builder := NewAgentSpecBuilder()
+	err := builder.BuildAgentSpec(context.Background(), "test/manifest_2/agent_A_manifest.json", "", nil, nil)
+	assert.NoError(t, err, "BuildAgentSpec should not return an error")
+
+	err = builder.LoadFromConfig(context.Background(), config.ConfigFile{
+		Config: map[string]config.AgentConfig{
+			"agent_A": {
+				APIKey:  "env://WFSM_TEST_AGENT_B_SECRET",
+				EnvVars: map[string]string{"ENV_VAR_AGENT_A": "file://test/secrets/agent_a_secret"},
+			},
+			"agent_B_1": {
+				EnvVars:       map[string]string{"ENV_VAR_AGENT_B_1": "env://WFSM_TEST_AGENT_B_SECRET"},
+				SecretEnvVars: []string{"ENV_VAR_AGENT_B_1"},
+			},
+		},
+	}, EnvFile{})
+	assert.NoError(t, err, "LoadFromConfig should not return an error")
+	assert.Equal(t, "secret_value_from_file", builder.AgentSpecs["agent_A"].EnvVars["ENV_VAR_AGENT_A"])
+	assert.Equal(t, "secret_value_from_env", builder.AgentSpecs["agent_A"].ApiKey)
+	assert.Equal(t, "secret_value_from_env", builder.AgentSpecs["agent_B_1"].EnvVars["ENV_VAR_AGENT_B_1"])
+	// secret env vars are marked by annotation in the manifest of agent_A and in the config of agent_B_1
+	assert.Equal(t, []string{"ENV_VAR_AGENT_A"}, builder.AgentSpecs["agent_A"].SecretEnvVars)
+	assert.Equal(t, []string{"ENV_VAR_AGENT_B_1"}, builder.AgentSpecs["agent_B_1"].SecretEnvVars)
+	assert.False(t, builder.AgentSpecs["agent_C_1"].IsSecretEnvVar("ENV_VAR_AGENT_C_1"))
+
+	builder = NewAgentSpecBuilder()
+	err = builder.BuildAgentSpec(context.Background(), "test/manifest_2/agent_A_manifest.json", "", nil, nil)
+	assert.NoError(t, err, "BuildAgentSpec should not return an error")
+
+	err = builder.LoadFromConfig(context.Background(), config.ConfigFile{
+		Config: map[string]config.AgentConfig{
+			"agent_A": {
+				EnvVars: map[string]string{"ENV_VAR_AGENT_A": "file://test/secrets/missing"},
+			},
+			"agent_C_1": {
+				EnvVars: map[string]string{"ENV_VAR_AGENT_C_1": "env://WFSM_TEST_MISSING_SECRET"},
+			},
+		},
+	}, EnvFile{})
+	assert.ErrorContains(t, err, "env var ENV_VAR_AGENT_A of agent agent_A: failed to resolve secret reference file://test/secrets/missing")
+	assert.ErrorContains(t, err, "env var ENV_VAR_AGENT_C_1 of agent agent_C_1: failed to resolve secret reference env://WFSM_TEST_MISSING_SECRET")
+}
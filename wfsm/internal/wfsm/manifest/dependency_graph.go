@@ -0,0 +1,106 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package manifest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cisco-eti/wfsm/manifests"
+)
+
+// DependencyCycleError is returned when an agent depends on itself directly or through its dependencies
+type DependencyCycleError struct {
+	// Path lists the deployment names from the main agent to the repeated dependency
+	Path []string
+}
+
+func (e *DependencyCycleError) Error() string {
+	return fmt.Sprintf("dependency cycle detected: %s", strings.Join(e.Path, " -> "))
+}
+
+// DependencyConflictError is returned when the same deployment name is defined differently by two agents
+type DependencyConflictError struct {
+	DeploymentName string
+	First          dependencyRequest
+	Second         dependencyRequest
+	Reason         string
+}
+
+func (e *DependencyConflictError) Error() string {
+	return fmt.Sprintf("conflicting definitions for agent dependency %s: %s; %s, %s",
+		e.DeploymentName, e.Reason, e.First, e.Second)
+}
+
+// dependencyRequest records how a deployment was referenced, used to tell shared dependencies from conflicting ones
+type dependencyRequest struct {
+	requestedBy      string
+	manifestPath     string
+	deploymentOption string
+	// digest of the loaded manifest
+	digest string
+}
+
+func newDependencyRequest(requestedBy string, manifestPath string, deploymentOption *string) dependencyRequest {
+	option := ""
+	if deploymentOption != nil {
+		option = *deploymentOption
+	}
+	return dependencyRequest{
+		requestedBy:      requestedBy,
+		manifestPath:     manifestPath,
+		deploymentOption: option,
+	}
+}
+
+func (r dependencyRequest) String() string {
+	option := r.deploymentOption
+	if option == "" {
+		option = "<default>"
+	}
+	requestedBy := r.requestedBy
+	if requestedBy == "" {
+		requestedBy = "command line"
+	}
+	return fmt.Sprintf("referenced by %s as %s (deployment option %s)", requestedBy, r.manifestPath, option)
+}
+
+// conflictsWith returns the reason why two requests for the same deployment name cannot be shared, or empty string
+func (r dependencyRequest) conflictsWith(other dependencyRequest) string {
+	if r.manifestPath != other.manifestPath {
+		return "different manifest references"
+	}
+	if r.deploymentOption != other.deploymentOption {
+		return "different deployment options"
+	}
+	return ""
+}
+
+// shareDependency merges the env var values of another reference to an already resolved deployment
+// into its spec and the specs of its dependencies. Values set differently by two references are reported as conflict.
+func (a *AgentSpecBuilder) shareDependency(deploymentName string, request dependencyRequest, envVarValues *manifests.EnvVarValues) error {
+	spec := a.AgentSpecs[deploymentName]
+	if envVarValues == nil {
+		return nil
+	}
+	for key, value := range envVarValues.Values {
+		if existing, ok := spec.EnvVars[key]; ok && existing != value {
+			return &DependencyConflictError{
+				DeploymentName: deploymentName,
+				First:          a.requests[deploymentName],
+				Second:         request,
+				Reason:         fmt.Sprintf("env var %s is set to different values", key),
+			}
+		}
+		spec.EnvVars[key] = value
+	}
+	for _, depName := range a.Dependencies[deploymentName] {
+		depValues := mergeEnvVarValues(nil, *envVarValues, depName)
+		depRequest := a.requests[depName]
+		depRequest.requestedBy = deploymentName
+		if err := a.shareDependency(depName, depRequest, depValues); err != nil {
+			return err
+		}
+	}
+	return nil
+}
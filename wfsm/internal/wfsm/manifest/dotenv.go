@@ -0,0 +1,324 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var envVarNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// DotenvError is returned for syntax errors in dotenv files
+type DotenvError struct {
+	Line int
+	Msg  string
+}
+
+func (e *DotenvError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// LoadEnvVars parses a dotenv file. Supported syntax:
+//
+//	# comment
+//	KEY=value                  unquoted values are trimmed, ' #' starts an inline comment
+//	export KEY=value           'export ' prefix is ignored
+//	KEY="line1\nline2"         double quoted values support \n, \r, \t, \", \\ and \$ escapes and can span multiple lines
+//	KEY='${NOT_EXPANDED}'      single quoted values are taken literally and can span multiple lines
+//	KEY=${OTHER}/path          ${VAR}, ${VAR:-default} and $VAR are expanded from earlier lines and the OS environment
+//	KEY=first \
+//	  second                   a backslash at the end of an unquoted value continues it on the next line
+func LoadEnvVars(envFilePath string) (map[string]string, error) {
+	if envFilePath == "" {
+		return make(map[string]string), nil
+	}
+
+	data, err := os.ReadFile(envFilePath)
+	if err != nil {
+		return nil, errors.New("failed to open env file")
+	}
+	envVars, err := parseDotenv(string(data), os.LookupEnv)
+	if err != nil {
+		return nil, fmt.Errorf("invalid env file %s: %w", envFilePath, err)
+	}
+	return envVars, nil
+}
+
+type dotenvParser struct {
+	data   string
+	pos    int
+	line   int
+	values map[string]string
+	lookup func(string) (string, bool)
+}
+
+// parseDotenv parses dotenv content, variables not defined in earlier lines are looked up with lookup
+func parseDotenv(data string, lookup func(string) (string, bool)) (map[string]string, error) {
+	p := &dotenvParser{
+		data:   strings.ReplaceAll(data, "\r\n", "\n"),
+		line:   1,
+		values: make(map[string]string),
+		lookup: lookup,
+	}
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return p.values, nil
+		}
+		if p.peek() == '#' {
+			p.skipLine()
+			continue
+		}
+		if err := p.parseAssignment(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *dotenvParser) parseAssignment() error {
+	keyLine := p.line
+	if strings.HasPrefix(p.data[p.pos:], "export ") || strings.HasPrefix(p.data[p.pos:], "export\t") {
+		p.pos += len("export")
+		p.skipSpaces()
+	}
+
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune("= \t\n", rune(p.peek())) {
+		p.pos++
+	}
+	key := p.data[start:p.pos]
+	if key == "" {
+		return p.errorf(keyLine, "missing variable name")
+	}
+	if !envVarNamePattern.MatchString(key) {
+		return p.errorf(keyLine, "invalid variable name %q", key)
+	}
+
+	p.skipSpaces()
+	if p.eof() || p.peek() != '=' {
+		return p.errorf(keyLine, "expected '=' after %s", key)
+	}
+	p.pos++
+	p.skipSpaces()
+
+	var value string
+	var err error
+	switch {
+	case p.eof():
+		value = ""
+	case p.peek() == '"':
+		value, err = p.parseDoubleQuoted()
+	case p.peek() == '\'':
+		value, err = p.parseSingleQuoted()
+	default:
+		value, err = p.parseUnquoted()
+	}
+	if err != nil {
+		return err
+	}
+	p.values[key] = value
+	return nil
+}
+
+func (p *dotenvParser) parseDoubleQuoted() (string, error) {
+	startLine := p.line
+	p.pos++ // opening quote
+	var sb strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf(startLine, "unterminated double quoted value")
+		}
+		c := p.next()
+		switch c {
+		case '"':
+			return sb.String(), p.endOfQuotedValue()
+		case '\\':
+			if p.eof() {
+				return "", p.errorf(startLine, "unterminated double quoted value")
+			}
+			escaped := p.next()
+			switch escaped {
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case '"', '\\', '$':
+				sb.WriteByte(escaped)
+			case '\n':
+				// escaped line break joins the lines
+			default:
+				sb.WriteByte('\\')
+				sb.WriteByte(escaped)
+			}
+		case '$':
+			expanded, err := p.expand()
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(expanded)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+}
+
+func (p *dotenvParser) parseSingleQuoted() (string, error) {
+	startLine := p.line
+	p.pos++ // opening quote
+	end := strings.IndexByte(p.data[p.pos:], '\'')
+	if end < 0 {
+		return "", p.errorf(startLine, "unterminated single quoted value")
+	}
+	value := p.data[p.pos : p.pos+end]
+	p.line += strings.Count(value, "\n")
+	p.pos += end + 1
+	return value, p.endOfQuotedValue()
+}
+
+// endOfQuotedValue allows only white space and a comment after the closing quote
+func (p *dotenvParser) endOfQuotedValue() error {
+	p.skipSpaces()
+	if p.eof() || p.peek() == '\n' {
+		return nil
+	}
+	if p.peek() == '#' {
+		p.skipLine()
+		return nil
+	}
+	return p.errorf(p.line, "unexpected character %q after quoted value", p.peek())
+}
+
+// parseUnquoted reads the value up to the end of the line, '#' starts a comment only when white space precedes it
+// (KEY=#abc is the value #abc, KEY= #abc an empty value)
+func (p *dotenvParser) parseUnquoted() (string, error) {
+	var sb strings.Builder
+	previousIsSpace := p.pos > 0 && (p.data[p.pos-1] == ' ' || p.data[p.pos-1] == '\t')
+	for !p.eof() {
+		c := p.peek()
+		if c == '\n' {
+			break
+		}
+		if c == '#' && previousIsSpace {
+			p.skipLine()
+			break
+		}
+		p.pos++
+		switch {
+		case c == '\\' && !p.eof() && p.peek() == '\n':
+			// line continuation, the indentation of the next line is dropped
+			p.next()
+			p.skipSpaces()
+		case c == '\\' && !p.eof() && p.peek() == '$':
+			sb.WriteByte(p.next())
+		case c == '$':
+			expanded, err := p.expand()
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(expanded)
+		default:
+			sb.WriteByte(c)
+		}
+		previousIsSpace = c == ' ' || c == '\t'
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// expand resolves the variable reference following '$', a '$' not followed by a variable name is kept
+func (p *dotenvParser) expand() (string, error) {
+	if p.eof() {
+		return "$", nil
+	}
+	if p.peek() == '{' {
+		startLine := p.line
+		end := strings.IndexAny(p.data[p.pos:], "}\n")
+		if end < 0 || p.data[p.pos+end] != '}' {
+			return "", p.errorf(startLine, "unterminated variable reference")
+		}
+		reference := p.data[p.pos+1 : p.pos+end]
+		p.pos += end + 1
+
+		name, defaultValue, hasDefault := strings.Cut(reference, ":-")
+		if !envVarNamePattern.MatchString(name) {
+			return "", p.errorf(startLine, "invalid variable reference ${%s}", reference)
+		}
+		value, ok := p.resolve(name)
+		if (!ok || value == "") && hasDefault {
+			return defaultValue, nil
+		}
+		return value, nil
+	}
+
+	start := p.pos
+	for !p.eof() && isNameChar(p.peek(), p.pos == start) {
+		p.pos++
+	}
+	if start == p.pos {
+		return "$", nil
+	}
+	value, _ := p.resolve(p.data[start:p.pos])
+	return value, nil
+}
+
+// resolve looks up variables defined in earlier lines first, then in the OS environment
+func (p *dotenvParser) resolve(name string) (string, bool) {
+	if value, ok := p.values[name]; ok {
+		return value, true
+	}
+	if p.lookup == nil {
+		return "", false
+	}
+	return p.lookup(name)
+}
+
+func isNameChar(c byte, first bool) bool {
+	if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+		return true
+	}
+	return !first && c >= '0' && c <= '9'
+}
+
+func (p *dotenvParser) eof() bool {
+	return p.pos >= len(p.data)
+}
+
+func (p *dotenvParser) peek() byte {
+	return p.data[p.pos]
+}
+
+func (p *dotenvParser) next() byte {
+	c := p.data[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+func (p *dotenvParser) skipSpaces() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+func (p *dotenvParser) skipBlank() {
+	for !p.eof() && strings.ContainsRune(" \t\n", rune(p.peek())) {
+		p.next()
+	}
+}
+
+func (p *dotenvParser) skipLine() {
+	for !p.eof() && p.peek() != '\n' {
+		p.pos++
+	}
+}
+
+func (p *dotenvParser) errorf(line int, format string, args ...any) error {
+	return &DotenvError{Line: line, Msg: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,137 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package manifest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDotenv(t *testing.T) {
+	osEnv := map[string]string{
+		"HOME":      "/home/agent",
+		"OS_ONLY":   "from-os",
+		"OVERRIDES": "from-os",
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := osEnv[name]
+		return value, ok
+	}
+
+	tests := []struct {
+		name     string
+		data     string
+		want     map[string]string
+		wantLine int
+	}{
+		{
+			name: "plain values, comments and blank lines",
+			data: "# comment\n\nA=1\n  B = two words  \nC=\n",
+			want: map[string]string{"A": "1", "B": "two words", "C": ""},
+		},
+		{
+			name: "export prefix",
+			data: "export A=1\nexport\tB=2\nexporter=3\n",
+			want: map[string]string{"A": "1", "B": "2", "exporter": "3"},
+		},
+		{
+			name: "inline comments",
+			data: "A=value # comment\nB=value#not-a-comment\nC=\"quoted # kept\" # comment\nD='single' # comment\nE=#abc\nF= #comment\n",
+			want: map[string]string{"A": "value", "B": "value#not-a-comment", "C": "quoted # kept", "D": "single", "E": "#abc", "F": ""},
+		},
+		{
+			name: "double quoted escapes",
+			data: `A="line1\nline2\ttab \"quoted\" \\ \$HOME"`,
+			want: map[string]string{"A": "line1\nline2\ttab \"quoted\" \\ $HOME"},
+		},
+		{
+			name: "single quoted values are literal",
+			data: `A='${HOME} \n "x"'`,
+			want: map[string]string{"A": `${HOME} \n "x"`},
+		},
+		{
+			name: "multi-line quoted values",
+			data: "KEY=\"-----BEGIN KEY-----\nabc\n-----END KEY-----\"\nJSON='{\n  \"a\": 1\n}'\nNEXT=1\n",
+			want: map[string]string{
+				"KEY":  "-----BEGIN KEY-----\nabc\n-----END KEY-----",
+				"JSON": "{\n  \"a\": 1\n}",
+				"NEXT": "1",
+			},
+		},
+		{
+			name: "json value",
+			data: `API_KEY={"x-api-key": "secret"}`,
+			want: map[string]string{"API_KEY": `{"x-api-key": "secret"}`},
+		},
+		{
+			name: "interpolation from earlier lines and os env",
+			data: "OVERRIDES=from-file\nA=${HOME}/data\nB=\"$OS_ONLY-${OVERRIDES}\"\nC=${MISSING}\nD=${MISSING:-default}\nE=$A/x\nF=price $5 \\$HOME\n",
+			want: map[string]string{
+				"OVERRIDES": "from-file",
+				"A":         "/home/agent/data",
+				"B":         "from-os-from-file",
+				"C":         "",
+				"D":         "default",
+				"E":         "/home/agent/data/x",
+				"F":         "price $5 $HOME",
+			},
+		},
+		{
+			name: "escaped newlines continue unquoted and double quoted values",
+			data: "A=first \\\n  second\nB=\"one \\\ntwo\"\n",
+			want: map[string]string{"A": "first second", "B": "one two"},
+		},
+		{
+			name: "windows line endings",
+			data: "A=1\r\nB=\"2\"\r\n",
+			want: map[string]string{"A": "1", "B": "2"},
+		},
+		{
+			name:     "missing equal sign",
+			data:     "A=1\nINVALID\n",
+			wantLine: 2,
+		},
+		{
+			name:     "invalid name",
+			data:     "A=1\n\n1A=2\n",
+			wantLine: 3,
+		},
+		{
+			name:     "unterminated double quote reports the opening line",
+			data:     "A=1\nB=\"open\nstill open\n",
+			wantLine: 2,
+		},
+		{
+			name:     "unterminated single quote",
+			data:     "A='open\n",
+			wantLine: 1,
+		},
+		{
+			name:     "characters after closing quote",
+			data:     "A=\"1\"\nB=\"2\"x\n",
+			wantLine: 2,
+		},
+		{
+			name:     "unterminated variable reference",
+			data:     "A=1\nB=${A\n",
+			wantLine: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDotenv(tt.data, lookup)
+			if tt.wantLine > 0 {
+				var dotenvErr *DotenvError
+				assert.True(t, errors.As(err, &dotenvErr), "expected dotenv error, got %v", err)
+				if dotenvErr != nil {
+					assert.Equal(t, tt.wantLine, dotenvErr.Line, dotenvErr.Error())
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+
+	"github.com/cisco-eti/wfsm/internal/util"
+	"github.com/cisco-eti/wfsm/manifests"
+)
+
+// EnvFile holds the env vars loaded from the env file passed to deploy.
+//
+// Dotenv files (KEY=VALUE lines) fill Values, which apply to every agent declaring the env var in its manifest,
+// or to a single agent if the key is prefixed with its deployment name (e.g. MAILCOMPOSER_OPENAI_API_KEY).
+//
+// YAML files (.yaml, .yml) are in EnvVarValues format and fill EnvVarValues: top level values apply to the main agent,
+// the entries of env_deps (or dependencies) to the dependency with that name, nested entries to its own dependencies.
+type EnvFile struct {
+	Values       map[string]string
+	EnvVarValues *manifests.EnvVarValues
+}
+
+// yamlEnvVarValues is the YAML form of manifests.EnvVarValues, dependencies is accepted as alias of env_deps
+type yamlEnvVarValues struct {
+	Name         string             `yaml:"name,omitempty"`
+	Values       map[string]string  `yaml:"values,omitempty"`
+	EnvDeps      []yamlEnvVarValues `yaml:"env_deps,omitempty"`
+	Dependencies []yamlEnvVarValues `yaml:"dependencies,omitempty"`
+}
+
+// LoadEnvFile loads the env file, the format is chosen by file extension: YAML for .yaml and .yml files, dotenv otherwise
+func LoadEnvFile(envFilePath string) (EnvFile, error) {
+	if !isYAMLEnvFile(envFilePath) {
+		values, err := LoadEnvVars(envFilePath)
+		if err != nil {
+			return EnvFile{}, err
+		}
+		return EnvFile{Values: values}, nil
+	}
+
+	data, err := os.ReadFile(envFilePath)
+	if err != nil {
+		return EnvFile{}, errors.New("failed to open env file")
+	}
+	envVarValues, err := parseYAMLEnvVarValues(data)
+	if err != nil {
+		return EnvFile{}, fmt.Errorf("invalid env file %s: %v", envFilePath, err)
+	}
+	return EnvFile{
+		Values:       make(map[string]string),
+		EnvVarValues: envVarValues,
+	}, nil
+}
+
+func isYAMLEnvFile(envFilePath string) bool {
+	ext := strings.ToLower(filepath.Ext(envFilePath))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func parseYAMLEnvVarValues(data []byte) (*manifests.EnvVarValues, error) {
+	var values yamlEnvVarValues
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&values); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return values.toEnvVarValues("")
+}
+
+func (v yamlEnvVarValues) toEnvVarValues(path string) (*manifests.EnvVarValues, error) {
+	envVarValues := &manifests.EnvVarValues{
+		Values: v.Values,
+	}
+	if v.Name != "" {
+		envVarValues.Name = &v.Name
+	}
+	for _, dep := range append(v.EnvDeps, v.Dependencies...) {
+		if dep.Name == "" {
+			if path == "" {
+				return nil, errors.New("name is required for dependency values")
+			}
+			return nil, fmt.Errorf("name is required for dependency values of %s", strings.TrimPrefix(path, "/"))
+		}
+		depValues, err := dep.toEnvVarValues(path + "/" + dep.Name)
+		if err != nil {
+			return nil, err
+		}
+		envVarValues.EnvDeps = append(envVarValues.EnvDeps, *depValues)
+	}
+	return envVarValues, nil
+}
+
+// envFileValues returns the values of a YAML env file by deployment name, walking the env_deps entries along the dependency graph.
+// Values for a dependency shared by several agents are merged from all paths leading to it.
+func (a *AgentSpecBuilder) envFileValues(ctx context.Context, envVarValues *manifests.EnvVarValues) map[string]map[string]string {
+	log := zerolog.Ctx(ctx)
+
+	result := make(map[string]map[string]string)
+	if envVarValues == nil {
+		return result
+	}
+
+	var walk func(deploymentName string, values manifests.EnvVarValues)
+	walk = func(deploymentName string, values manifests.EnvVarValues) {
+		result[deploymentName] = util.MergeMaps(result[deploymentName], values.Values)
+		for _, depValues := range values.EnvDeps {
+			if !a.dependsOn(deploymentName, depValues.GetName()) {
+				log.Warn().Msgf("env file has values for %s, which is not a dependency of %s", depValues.GetName(), deploymentName)
+			}
+		}
+		for _, depName := range a.Dependencies[deploymentName] {
+			walk(depName, *mergeEnvVarValues(nil, values, depName))
+		}
+	}
+	walk(a.DeploymentName, *envVarValues)
+	return result
+}
+
+func (a *AgentSpecBuilder) dependsOn(deploymentName string, dependencyName string) bool {
+	for _, depName := range a.Dependencies[deploymentName] {
+		if depName == dependencyName {
+			return true
+		}
+	}
+	return false
+}
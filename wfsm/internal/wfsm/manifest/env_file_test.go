@@ -0,0 +1,78 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseYAMLEnvVarValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+		check   func(t *testing.T, values map[string]string, depValues map[string]map[string]string)
+	}{
+		{
+			name: "dependencies and env_deps",
+			data: `
+values:
+  PORT: 8080
+  DEBUG: true
+dependencies:
+  - name: mailcomposer
+    values:
+      MODEL: gpt-4o
+env_deps:
+  - name: reviewer
+    values:
+      MODEL: llama3
+`,
+			check: func(t *testing.T, values map[string]string, depValues map[string]map[string]string) {
+				assert.Equal(t, map[string]string{"PORT": "8080", "DEBUG": "true"}, values)
+				assert.Equal(t, map[string]string{"MODEL": "gpt-4o"}, depValues["mailcomposer"])
+				assert.Equal(t, map[string]string{"MODEL": "llama3"}, depValues["reviewer"])
+			},
+		},
+		{
+			name: "empty file",
+			data: "",
+			check: func(t *testing.T, values map[string]string, depValues map[string]map[string]string) {
+				assert.Empty(t, values)
+				assert.Empty(t, depValues)
+			},
+		},
+		{
+			name:    "dependency without name",
+			data:    "dependencies:\n  - values:\n      A: b\n",
+			wantErr: "name is required for dependency values",
+		},
+		{
+			name:    "nested dependency without name",
+			data:    "dependencies:\n  - name: mailcomposer\n    env_deps:\n      - values:\n          A: b\n",
+			wantErr: "name is required for dependency values of mailcomposer",
+		},
+		{
+			name:    "unknown field",
+			data:    "value:\n  A: b\n",
+			wantErr: "field value not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envVarValues, err := parseYAMLEnvVarValues([]byte(tt.data))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			depValues := make(map[string]map[string]string)
+			for _, dep := range envVarValues.EnvDeps {
+				depValues[dep.GetName()] = dep.Values
+			}
+			tt.check(t, envVarValues.Values, depValues)
+		})
+	}
+}
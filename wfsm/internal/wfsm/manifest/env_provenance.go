@@ -0,0 +1,81 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package manifest
+
+import (
+	"github.com/cisco-eti/wfsm/internal"
+)
+
+// EnvVarLayer is a source of env var values, layers are applied in the order of the constants below,
+// a value set by a later layer overrides the values of earlier layers
+type EnvVarLayer string
+
+const (
+	// EnvLayerManifestValues are the env var values set for a dependency in the manifest of the dependent agent
+	EnvLayerManifestValues EnvVarLayer = "manifest values"
+	// EnvLayerOS are OS env vars declared in the agent manifest
+	EnvLayerOS EnvVarLayer = "OS env"
+	// EnvLayerOSPrefixed are OS env vars prefixed with the deployment name of the agent
+	EnvLayerOSPrefixed EnvVarLayer = "OS env (prefixed)"
+	// EnvLayerEnvFile are env file values declared in the agent manifest
+	EnvLayerEnvFile EnvVarLayer = "env file"
+	// EnvLayerEnvFilePrefixed are env file values prefixed with the deployment name of the agent
+	EnvLayerEnvFilePrefixed EnvVarLayer = "env file (prefixed)"
+	// EnvLayerEnvFileYAML are the values of the agent in a YAML env file
+	EnvLayerEnvFileYAML EnvVarLayer = "env file (yaml)"
+	// EnvLayerConfig are the envVars of the agent in the config
+	EnvLayerConfig EnvVarLayer = "config"
+	// EnvLayerManifestDefault are the default values of the agent manifest, used if no other layer sets a value
+	EnvLayerManifestDefault EnvVarLayer = "manifest default"
+	// EnvLayerRunner are the env vars set by the platform runner on deployment (API_*, AGENT_ID and dependency vars)
+	EnvLayerRunner EnvVarLayer = "runner"
+)
+
+// EnvVarSetting is a value set for an env var by a layer
+type EnvVarSetting struct {
+	Layer EnvVarLayer `json:"layer"`
+	// Source is the name of the variable the value was read from, if it differs from the env var name
+	Source string `json:"source,omitempty"`
+	Value  string `json:"value"`
+	// Reference is the secret reference the value was resolved from
+	Reference string `json:"reference,omitempty"`
+	// Template is the value with template expressions the value was interpolated from
+	Template string `json:"template,omitempty"`
+}
+
+// envRecorder sets the env vars of an agent spec and records the settings of each env var in the order they are applied
+type envRecorder struct {
+	spec     internal.AgentSpec
+	settings map[string][]EnvVarSetting
+}
+
+func newEnvRecorder(spec internal.AgentSpec) *envRecorder {
+	r := &envRecorder{
+		spec:     spec,
+		settings: make(map[string][]EnvVarSetting),
+	}
+	for name, value := range spec.EnvVars {
+		r.settings[name] = []EnvVarSetting{{Layer: EnvLayerManifestValues, Value: value}}
+	}
+	return r
+}
+
+func (r *envRecorder) set(name string, value string, layer EnvVarLayer, source string) {
+	if source == name {
+		source = ""
+	}
+	r.spec.EnvVars[name] = value
+	r.settings[name] = append(r.settings[name], EnvVarSetting{Layer: layer, Source: source, Value: value})
+}
+
+func (r *envRecorder) setAll(values map[string]string, layer EnvVarLayer) {
+	for name, value := range values {
+		r.set(name, value, layer, "")
+	}
+}
+
+// EnvVarSettings returns the settings of the env vars of an agent recorded by LoadFromConfig,
+// the last setting of each env var is the effective one
+func (a *AgentSpecBuilder) EnvVarSettings(deploymentName string) map[string][]EnvVarSetting {
+	return a.envSettings[deploymentName]
+}
@@ -0,0 +1,145 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cisco-eti/wfsm/internal"
+	"github.com/cisco-eti/wfsm/manifests"
+)
+
+// Annotations of env vars declared in the agent manifest constraining their values, e.g.
+//
+//	"annotations": {"org.agntcy.wfsm.type": "int", "org.agntcy.wfsm.min": "1", "org.agntcy.wfsm.max": "10"}
+const (
+	// EnvVarTypeAnnotation is the type of the value: string (default), int, bool, url or json
+	EnvVarTypeAnnotation = "org.agntcy.wfsm.type"
+	// EnvVarPatternAnnotation is a regular expression the whole value must match
+	EnvVarPatternAnnotation = "org.agntcy.wfsm.pattern"
+	// EnvVarEnumAnnotation is a comma separated list of the allowed values
+	EnvVarEnumAnnotation = "org.agntcy.wfsm.enum"
+	// EnvVarMinAnnotation is the minimum of int values, or the minimum length of other values
+	EnvVarMinAnnotation = "org.agntcy.wfsm.min"
+	// EnvVarMaxAnnotation is the maximum of int values, or the maximum length of other values
+	EnvVarMaxAnnotation = "org.agntcy.wfsm.max"
+)
+
+const (
+	EnvVarTypeString = "string"
+	EnvVarTypeInt    = "int"
+	EnvVarTypeBool   = "bool"
+	EnvVarTypeURL    = "url"
+	EnvVarTypeJSON   = "json"
+)
+
+// ValidateAgentEnvVars validates the env vars of the agent against the env var definitions of its manifest,
+// all errors are returned. Constraints are only checked for env vars with a value, values with template expressions
+// are validated after they are interpolated.
+func ValidateAgentEnvVars(inputSpec internal.AgentSpec) []error {
+	errs := make([]error, 0)
+	deployment := GetDeployment(inputSpec.Manifest)
+	for _, envVarDef := range deployment.EnvVars {
+		value, ok := inputSpec.EnvVars[envVarDef.GetName()]
+		if !ok {
+			if envVarDef.GetRequired() {
+				errs = append(errs, fmt.Errorf("agent %s: missing required env var %s", inputSpec.DeploymentName, envVarDef.GetName()))
+			}
+			continue
+		}
+		if value == "" || internal.HasEnvTemplate(value) {
+			continue
+		}
+		if err := validateEnvVarValue(envVarDef, value); err != nil {
+			// secret values are not included in the error
+			errs = append(errs, fmt.Errorf("agent %s: invalid value of env var %s: %v", inputSpec.DeploymentName, envVarDef.GetName(), err))
+		}
+	}
+	return errs
+}
+
+// validateEnvVarValue checks the value against the constraints in the annotations of the env var definition
+func validateEnvVarValue(envVarDef manifests.EnvVar, value string) error {
+	annotations := envVarDef.GetAnnotations()
+	envVarType := annotations[EnvVarTypeAnnotation]
+
+	var intValue int64
+	switch envVarType {
+	case EnvVarTypeString, "":
+	case EnvVarTypeInt:
+		var err error
+		if intValue, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("must be an int")
+		}
+	case EnvVarTypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("must be a bool (true or false)")
+		}
+	case EnvVarTypeURL:
+		u, err := url.Parse(value)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("must be an absolute url (e.g. http://host:8000)")
+		}
+	case EnvVarTypeJSON:
+		if !json.Valid([]byte(value)) {
+			return fmt.Errorf("must be valid json")
+		}
+	default:
+		return fmt.Errorf("unsupported type %s in annotation %s", envVarType, EnvVarTypeAnnotation)
+	}
+
+	if pattern, ok := annotations[EnvVarPatternAnnotation]; ok {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid pattern %s in annotation %s: %v", pattern, EnvVarPatternAnnotation, err)
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("must match pattern %s", pattern)
+		}
+	}
+
+	if enum, ok := annotations[EnvVarEnumAnnotation]; ok {
+		allowed := strings.Split(enum, ",")
+		for i := range allowed {
+			allowed[i] = strings.TrimSpace(allowed[i])
+		}
+		if !slices.Contains(allowed, value) {
+			return fmt.Errorf("must be one of %s", strings.Join(allowed, ", "))
+		}
+	}
+
+	// min and max limit int values, or the length of other values
+	actual, kind := int64(len(value)), "length"
+	if envVarType == EnvVarTypeInt {
+		actual, kind = intValue, "value"
+	}
+	if limit, ok, err := getLimit(annotations, EnvVarMinAnnotation); err != nil {
+		return err
+	} else if ok && actual < limit {
+		return fmt.Errorf("%s must be at least %d", kind, limit)
+	}
+	if limit, ok, err := getLimit(annotations, EnvVarMaxAnnotation); err != nil {
+		return err
+	} else if ok && actual > limit {
+		return fmt.Errorf("%s must be at most %d", kind, limit)
+	}
+	return nil
+}
+
+func getLimit(annotations map[string]string, annotation string) (int64, bool, error) {
+	limitStr, ok := annotations[annotation]
+	if !ok {
+		return 0, false, nil
+	}
+	limit, err := strconv.ParseInt(limitStr, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid int %s in annotation %s", limitStr, annotation)
+	}
+	return limit, true, nil
+}
@@ -0,0 +1,78 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package manifest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cisco-eti/wfsm/internal/wfsm/config"
+	"github.com/cisco-eti/wfsm/manifests"
+)
+
+func TestValidateEnvVarValue(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		value       string
+		expectedErr string
+	}{
+		{name: "no constraints", value: "anything"},
+		{name: "int", annotations: map[string]string{EnvVarTypeAnnotation: "int"}, value: "42"},
+		{name: "not an int", annotations: map[string]string{EnvVarTypeAnnotation: "int"}, value: "4.2", expectedErr: "must be an int"},
+		{name: "bool", annotations: map[string]string{EnvVarTypeAnnotation: "bool"}, value: "true"},
+		{name: "not a bool", annotations: map[string]string{EnvVarTypeAnnotation: "bool"}, value: "yes", expectedErr: "must be a bool (true or false)"},
+		{name: "url", annotations: map[string]string{EnvVarTypeAnnotation: "url"}, value: "http://agent-b:8000/api"},
+		{name: "url without scheme", annotations: map[string]string{EnvVarTypeAnnotation: "url"}, value: "agent-b:8000", expectedErr: "must be an absolute url (e.g. http://host:8000)"},
+		{name: "json", annotations: map[string]string{EnvVarTypeAnnotation: "json"}, value: `{"x-api-key": "key"}`},
+		{name: "invalid json", annotations: map[string]string{EnvVarTypeAnnotation: "json"}, value: `{"x-api-key": }`, expectedErr: "must be valid json"},
+		{name: "unsupported type", annotations: map[string]string{EnvVarTypeAnnotation: "float"}, value: "1.0", expectedErr: "unsupported type float in annotation org.agntcy.wfsm.type"},
+		{name: "pattern", annotations: map[string]string{EnvVarPatternAnnotation: "gpt-[0-9]+"}, value: "gpt-4"},
+		{name: "pattern matches whole value", annotations: map[string]string{EnvVarPatternAnnotation: "gpt-[0-9]+"}, value: "gpt-4o", expectedErr: "must match pattern gpt-[0-9]+"},
+		{name: "invalid pattern", annotations: map[string]string{EnvVarPatternAnnotation: "gpt-[0-9"}, value: "gpt-4", expectedErr: "invalid pattern gpt-[0-9 in annotation org.agntcy.wfsm.pattern: error parsing regexp: missing closing ]: `[0-9)$`"},
+		{name: "enum", annotations: map[string]string{EnvVarEnumAnnotation: "debug, info"}, value: "info"},
+		{name: "not in enum", annotations: map[string]string{EnvVarEnumAnnotation: "debug, info"}, value: "trace", expectedErr: "must be one of debug, info"},
+		{name: "int in range", annotations: map[string]string{EnvVarTypeAnnotation: "int", EnvVarMinAnnotation: "1", EnvVarMaxAnnotation: "10"}, value: "10"},
+		{name: "int below min", annotations: map[string]string{EnvVarTypeAnnotation: "int", EnvVarMinAnnotation: "1"}, value: "0", expectedErr: "value must be at least 1"},
+		{name: "int above max", annotations: map[string]string{EnvVarTypeAnnotation: "int", EnvVarMaxAnnotation: "10"}, value: "11", expectedErr: "value must be at most 10"},
+		{name: "string too short", annotations: map[string]string{EnvVarMinAnnotation: "8"}, value: "secret", expectedErr: "length must be at least 8"},
+		{name: "invalid limit", annotations: map[string]string{EnvVarMaxAnnotation: "ten"}, value: "secret", expectedErr: "invalid int ten in annotation org.agntcy.wfsm.max"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envVarDef := manifests.EnvVar{Name: "ENV_VAR", Annotations: tt.annotations}
+			err := validateEnvVarValue(envVarDef, tt.value)
+			if tt.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestAgentSpecBuilder_ValidateEnvVars_Constraints(t *testing.T) {
+	builder := NewAgentSpecBuilder()
+	err := builder.BuildAgentSpec(context.Background(), "test/manifest_8/agent_A_manifest.json", "", nil, nil)
+	assert.NoError(t, err, "BuildAgentSpec should not return an error")
+
+	err = builder.LoadFromConfig(context.Background(), config.ConfigFile{
+		Config: map[string]config.AgentConfig{},
+	}, EnvFile{})
+	assert.NoError(t, err, "LoadFromConfig should not return an error")
+
+	// errors of all agents are returned, values passed to the dependency by the manifest are validated as well
+	errs := builder.ValidateEnvVars(context.Background())
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	assert.Equal(t, []string{
+		"agent agent_A: invalid value of env var CACHE_PORT: value must be at most 65535",
+		"agent agent_B_1: invalid value of env var SERVICE_URL: must be an absolute url (e.g. http://host:8000)",
+		"agent agent_B_1: invalid value of env var LOG_LEVEL: must be one of debug, info, warning, error",
+		"agent agent_B_1: missing required env var MODEL",
+	}, messages)
+}
@@ -0,0 +1,133 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/cisco-eti/wfsm/manifests"
+)
+
+const (
+	LockFileName    = "wfsm.lock"
+	lockFileVersion = 1
+	lockFileHeader  = "# This file is generated by wfsm, do not edit it manually.\n# Run 'wfsm deps update' to resolve the agent dependencies again.\n"
+)
+
+// LockFile pins the manifests of remote agent dependencies, so that deployments use the same dependency versions
+// until the lock file is refreshed with 'wfsm deps update'
+type LockFile struct {
+	LockFileVersion int `yaml:"lockFileVersion"`
+	// Dependencies are keyed by <deployment name of the parent agent>/<dependency name>
+	Dependencies map[string]LockedDependency `yaml:"dependencies"`
+
+	path    string
+	changed bool
+}
+
+// LockedDependency is the resolved manifest of a dependency reference
+type LockedDependency struct {
+	// Name, Version and Url are copied from the reference in the manifest, the entry is resolved again when they change
+	Name    string `yaml:"name"`
+	Version string `yaml:"version,omitempty"`
+	Url     string `yaml:"url,omitempty"`
+	// Resolved is the manifest location used for the dependency
+	Resolved        string `yaml:"resolved"`
+	ResolvedVersion string `yaml:"resolvedVersion"`
+	Digest          string `yaml:"digest"`
+}
+
+// GetLockFilePath returns the location of the lock file, it is stored next to local manifests and in the current
+// directory for remote ones
+func GetLockFilePath(manifestPath string) string {
+	if isRemoteManifest(manifestPath) {
+		return LockFileName
+	}
+	return filepath.Join(filepath.Dir(strings.TrimPrefix(manifestPath, "file://")), LockFileName)
+}
+
+// NewLockFile creates an empty lock file which is written to path
+func NewLockFile(path string) *LockFile {
+	return &LockFile{
+		LockFileVersion: lockFileVersion,
+		Dependencies:    make(map[string]LockedDependency),
+		path:            path,
+	}
+}
+
+// LoadLockFile reads the lock file from path, a missing file results in an empty lock file
+func LoadLockFile(path string) (*LockFile, error) {
+	lockFile := NewLockFile(path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return lockFile, nil
+		}
+		return nil, fmt.Errorf("failed to read lock file: %v", err)
+	}
+	if err := yaml.Unmarshal(data, lockFile); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal lock file %s: %v", path, err)
+	}
+	if lockFile.LockFileVersion != lockFileVersion {
+		return nil, fmt.Errorf("unsupported lock file version %d in %s, run 'wfsm deps update'", lockFile.LockFileVersion, path)
+	}
+	if lockFile.Dependencies == nil {
+		lockFile.Dependencies = make(map[string]LockedDependency)
+	}
+	return lockFile, nil
+}
+
+// Path returns the location of the lock file
+func (l *LockFile) Path() string {
+	return l.path
+}
+
+// Changed returns true if dependencies were added or resolved again since the lock file was loaded
+func (l *LockFile) Changed() bool {
+	return l.changed
+}
+
+// Write stores the lock file
+func (l *LockFile) Write() error {
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lock file: %v", err)
+	}
+	if err := os.WriteFile(l.path, append([]byte(lockFileHeader), data...), 0644); err != nil {
+		return fmt.Errorf("failed to write lock file: %v", err)
+	}
+	l.changed = false
+	return nil
+}
+
+// lookup returns the locked dependency if it was resolved from the same reference
+func (l *LockFile) lookup(key string, ref manifests.AgentReference) (LockedDependency, bool) {
+	if l == nil {
+		return LockedDependency{}, false
+	}
+	locked, ok := l.Dependencies[key]
+	if !ok || locked.Name != ref.Name || locked.Version != ref.Version || locked.Url != ref.GetUrl() {
+		return LockedDependency{}, false
+	}
+	return locked, true
+}
+
+func (l *LockFile) set(key string, locked LockedDependency) {
+	if l == nil {
+		return
+	}
+	if existing, ok := l.Dependencies[key]; ok && existing == locked {
+		return
+	}
+	l.Dependencies[key] = locked
+	l.changed = true
+}
+
+func lockKey(requestedBy string, dependencyName string) string {
+	return requestedBy + "/" + dependencyName
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/cisco-eti/wfsm/internal/cache"
 	"github.com/cisco-eti/wfsm/manifests"
 )
 
@@ -14,24 +15,32 @@ type ManifestService interface {
 	Validate() error
 	GetDeploymentOptionIdx(option *string) (int, error)
 	GetManifest() manifests.AgentManifest
+	// GetDigest returns the digest of the manifest document as it was loaded
+	GetDigest() string
 }
 
 type ManifestLoader interface {
-	loadManifest(context.Context) (manifests.AgentManifest, error)
+	loadManifest(context.Context) ([]byte, error)
 }
 
 type manifestService struct {
 	manifestLoader ManifestLoader
 	manifest       manifests.AgentManifest
+	digest         string
 }
 
 func NewManifestService(ctx context.Context, manifestLoader ManifestLoader) (ManifestService, error) {
-	manifest, err := manifestLoader.loadManifest(ctx)
+	data, err := manifestLoader.loadManifest(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load manifest: %s", err)
 	}
+	manifest, err := processOASFManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process manifest: %s", err)
+	}
 	return &manifestService{
 		manifest: manifest,
+		digest:   cache.Digest(data),
 	}, nil
 }
 
@@ -39,6 +48,10 @@ func (m manifestService) GetManifest() manifests.AgentManifest {
 	return m.manifest
 }
 
+func (m manifestService) GetDigest() string {
+	return m.digest
+}
+
 func (m manifestService) Validate() error {
 	// validate ref name and version
 	if m.manifest.Name == "" {
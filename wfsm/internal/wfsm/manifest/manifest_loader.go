@@ -8,7 +8,6 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"net/http"
 	"net/url"
 	"os"
 	"strings"
@@ -18,6 +17,8 @@ import (
 
 	"github.com/agntcy/dir/client"
 	"github.com/agntcy/dir/hub/api/v1alpha1"
+	"github.com/cisco-eti/wfsm/internal/cache"
+	"github.com/cisco-eti/wfsm/internal/httpclient"
 	"github.com/cisco-eti/wfsm/manifests"
 	"google.golang.org/grpc/metadata"
 )
@@ -31,19 +32,27 @@ type fileManifestLoader struct {
 type hubManifestLoader struct {
 	accessToken string
 	digest      string
-	host        string
+	// repository and version are set instead of digest for hub://host/<repository>@<version> references
+	repository string
+	version    string
+	host       string
+	cache      *cache.Cache
 }
 
 type directoryManifestLoader struct {
 	digest       string
 	directoryURL string
+	cache        *cache.Cache
 }
 
 type httpManifestLoader struct {
-	url string
+	url   string
+	cache *cache.Cache
 }
 
-func LoaderFactory(path string) (ManifestLoader, error) {
+// LoaderFactory creates a manifest loader for the given path. Remote manifests are stored in manifestCache
+// if it is not nil, local files are always read directly.
+func LoaderFactory(path string, manifestCache *cache.Cache) (ManifestLoader, error) {
 	u, err := url.Parse(path)
 	if err != nil {
 		return nil, fmt.Errorf("invalid manifest path: %s", err)
@@ -52,7 +61,8 @@ func LoaderFactory(path string) (ManifestLoader, error) {
 	switch u.Scheme {
 	case "http", "https":
 		return &httpManifestLoader{
-			url: path,
+			url:   path,
+			cache: manifestCache,
 		}, nil
 	case "file", "":
 		return &fileManifestLoader{
@@ -60,14 +70,21 @@ func LoaderFactory(path string) (ManifestLoader, error) {
 		}, nil
 	case "hub":
 		accessToken := os.Getenv("ACCESS_TOKEN")
-		if accessToken == "" {
+		if accessToken == "" && !manifestCache.Offline() {
 			return nil, fmt.Errorf("access token is not set")
 		}
-		return &hubManifestLoader{
+		loader := &hubManifestLoader{
 			accessToken: accessToken,
 			digest:      strings.TrimPrefix(u.Path, "/"),
 			host:        u.Host,
-		}, nil
+			cache:       manifestCache,
+		}
+		if repository, version, found := strings.Cut(loader.digest, "@"); found {
+			loader.digest = ""
+			loader.repository = repository
+			loader.version = version
+		}
+		return loader, nil
 	case "sha256":
 		directoryURL := os.Getenv("DIRECTORY_URL")
 		if directoryURL == "" {
@@ -76,13 +93,14 @@ func LoaderFactory(path string) (ManifestLoader, error) {
 		return &directoryManifestLoader{
 			digest:       path,
 			directoryURL: directoryURL,
+			cache:        manifestCache,
 		}, nil
 	default:
 		return nil, fmt.Errorf("unsupported manifest location: %s", path)
 	}
 }
 
-func (f *fileManifestLoader) loadManifest(ctx context.Context) (manifests.AgentManifest, error) {
+func (f *fileManifestLoader) loadManifest(ctx context.Context) ([]byte, error) {
 	file, err := os.Open(f.filePath)
 	if err != nil {
 		log.Fatalf("failed to open file: %s", err)
@@ -94,42 +112,62 @@ func (f *fileManifestLoader) loadManifest(ctx context.Context) (manifests.AgentM
 	if err != nil {
 		log.Fatalf("failed to read file: %s", err)
 	}
-	agentManifest, err := processOASFManifest(byteSlice)
-	if err != nil {
-		return manifests.AgentManifest{}, fmt.Errorf("failed to process OASF manifest: %s", err)
+	return byteSlice, nil
+}
+
+func (l *hubManifestLoader) loadManifest(ctx context.Context) ([]byte, error) {
+	if l.repository != "" {
+		// versions of a repository can be republished, so they are fetched every time and the cache is only a fallback
+		return l.cache.Load(ctx, "hub://"+l.host+"/"+l.repository+"@"+l.version, "", l.fetch)
 	}
-	return agentManifest, nil
+	// agents in the hub are referenced by a digest the hub assigns, so the cached copy never goes stale,
+	// but the digest is not the one of the pulled bytes and cannot verify them
+	return l.cache.LoadImmutable(ctx, "hub://"+l.host+"/"+l.digest, l.fetch)
 }
 
-func (l *hubManifestLoader) loadManifest(ctx context.Context) (manifests.AgentManifest, error) {
+func (l *hubManifestLoader) fetch(ctx context.Context) ([]byte, error) {
 	ctx = metadata.NewOutgoingContext(ctx, metadata.Pairs("authorization", "Bearer "+l.accessToken))
 	hc, err := hubClient.New(l.host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create hub client: %v", err)
+	}
 	agentID := &v1alpha1.AgentIdentifier{
 		Id: &v1alpha1.AgentIdentifier_Digest{
 			Digest: l.digest,
 		},
 	}
+	if l.repository != "" {
+		agentID = &v1alpha1.AgentIdentifier{
+			Id: &v1alpha1.AgentIdentifier_RepoVersionId{
+				RepoVersionId: &v1alpha1.RepoVersionId{
+					RepositoryName: l.repository,
+					Version:        l.version,
+				},
+			},
+		}
+	}
 
 	dirManifest, err := hc.PullAgent(ctx, &v1alpha1.PullAgentRequest{
 		Id: agentID,
 	})
 	if err != nil {
-		return manifests.AgentManifest{}, fmt.Errorf("failed to pull agent: %v", err)
+		return nil, fmt.Errorf("failed to pull agent: %v", err)
 	}
+	return dirManifest, nil
+}
 
-	agentManifest, err := processOASFManifest(dirManifest)
-	if err != nil {
-		return manifests.AgentManifest{}, fmt.Errorf("failed to process directory manifest: %s", err)
-	}
-	return agentManifest, nil
+func (l *directoryManifestLoader) loadManifest(ctx context.Context) ([]byte, error) {
+	// objects in the directory are referenced by a digest the directory assigns, so the cached copy never goes stale,
+	// but the digest is not the one of the pulled bytes and cannot verify them
+	return l.cache.LoadImmutable(ctx, l.digest, l.fetch)
 }
 
-func (l *directoryManifestLoader) loadManifest(ctx context.Context) (manifests.AgentManifest, error) {
+func (l *directoryManifestLoader) fetch(ctx context.Context) ([]byte, error) {
 	dirClient, err := client.New(client.WithConfig(&client.Config{
 		ServerAddress: l.directoryURL,
 	}))
 	if err != nil {
-		return manifests.AgentManifest{}, fmt.Errorf("failed to create directory client: %s", err)
+		return nil, fmt.Errorf("failed to create directory client: %s", err)
 	}
 	reader, err := dirClient.Pull(ctx, &coretypes.ObjectRef{
 		Digest:      l.digest,
@@ -137,40 +175,30 @@ func (l *directoryManifestLoader) loadManifest(ctx context.Context) (manifests.A
 		Annotations: nil,
 	})
 	if err != nil {
-		return manifests.AgentManifest{}, fmt.Errorf("failed to pull manifest from directory: %s", err)
+		return nil, fmt.Errorf("failed to pull manifest from directory: %s", err)
 	}
 	data, err := io.ReadAll(reader)
 	if err != nil {
-		return manifests.AgentManifest{}, fmt.Errorf("failed to read data from reader: %s", err)
-	}
-	agentManifest, err := processOASFManifest(data)
-	if err != nil {
-		return manifests.AgentManifest{}, fmt.Errorf("failed to process directory manifest: %s", err)
+		return nil, fmt.Errorf("failed to read data from reader: %s", err)
 	}
-
-	return agentManifest, nil
+	return data, nil
 }
 
-func (l *httpManifestLoader) loadManifest(ctx context.Context) (manifests.AgentManifest, error) {
-	resp, err := http.Get(l.url)
-	if err != nil {
-		return manifests.AgentManifest{}, err
-	}
-	defer resp.Body.Close()
+func (l *httpManifestLoader) loadManifest(ctx context.Context) ([]byte, error) {
+	return l.cache.Load(ctx, l.url, "", l.fetch)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return manifests.AgentManifest{}, fmt.Errorf("failed to fetch manifest: %s", resp.Status)
-	}
-	byteSlice, err := io.ReadAll(resp.Body)
+func (l *httpManifestLoader) fetch(ctx context.Context) ([]byte, error) {
+	// auth, CA bundle, timeout and retry settings are read from the credentials file and env vars
+	client, err := httpclient.NewClient()
 	if err != nil {
-		return manifests.AgentManifest{}, fmt.Errorf("failed to read response body: %s", err)
+		return nil, fmt.Errorf("failed to create http client: %s", err)
 	}
-
-	agentManifest, err := processOASFManifest(byteSlice)
+	byteSlice, err := client.Get(ctx, l.url)
 	if err != nil {
-		return manifests.AgentManifest{}, fmt.Errorf("failed to process directory manifest: %s", err)
+		return nil, fmt.Errorf("failed to fetch manifest: %s", err)
 	}
-	return agentManifest, nil
+	return byteSlice, nil
 }
 
 func processOASFManifest(OASFManifestRaw []byte) (manifests.AgentManifest, error) {
@@ -0,0 +1,239 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package manifest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	routingtypes "github.com/agntcy/dir/api/routing/v1alpha1"
+	"github.com/agntcy/dir/client"
+	"github.com/rs/zerolog"
+
+	"github.com/cisco-eti/wfsm/internal/cache"
+	"github.com/cisco-eti/wfsm/manifests"
+)
+
+// directoryAgentsLabel is the directory label of the records which carry an agent manifest
+const directoryAgentsLabel = "/extensions/" + AgentExtensionName
+
+// strictVersionsEnv enforces the version constraints of dependencies referenced by url, which are only warned about by default
+const strictVersionsEnv = "WFSM_STRICT_VERSIONS"
+
+// VersionConstraintError is returned when the version of a loaded dependency manifest doesn't satisfy the version of its reference
+type VersionConstraintError struct {
+	DeploymentName string
+	Constraint     string
+	Version        string
+}
+
+func (e *VersionConstraintError) Error() string {
+	return fmt.Sprintf("version %s of agent dependency %s does not satisfy version constraint %s",
+		e.Version, e.DeploymentName, e.Constraint)
+}
+
+// agentVersion is a version of an agent available in a hub or directory
+type agentVersion struct {
+	version      *semver.Version
+	manifestPath string
+}
+
+// versionLister lists the versions of an agent available in a hub or directory
+type versionLister interface {
+	listVersions(ctx context.Context, name string, constraint string) ([]agentVersion, error)
+}
+
+// checkVersionConstraint checks the version of a dependency manifest against the version constraint of its reference,
+// e.g. 1.2.3, ^1.2, ~1.2.0 or >=1.0.0 <2.0.0. An empty constraint matches every version.
+func checkVersionConstraint(deploymentName string, constraint string, version string) error {
+	if constraint == "" {
+		return nil
+	}
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return fmt.Errorf("invalid version constraint %s for agent dependency %s: %v", constraint, deploymentName, err)
+	}
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return fmt.Errorf("invalid version %s in manifest of agent dependency %s: %v", version, deploymentName, err)
+	}
+	if !c.Check(v) {
+		return &VersionConstraintError{DeploymentName: deploymentName, Constraint: constraint, Version: version}
+	}
+	return nil
+}
+
+func strictVersions() bool {
+	strict, _ := strconv.ParseBool(os.Getenv(strictVersionsEnv))
+	return strict
+}
+
+// needsVersionResolution returns true if the dependency is referenced by name, the manifest to use is looked up
+// in the directory (no url) or in a hub (hub://host without digest)
+func needsVersionResolution(ref manifests.AgentReference) bool {
+	if ref.Url == nil {
+		return true
+	}
+	u, err := url.Parse(*ref.Url)
+	return err == nil && u.Scheme == "hub" && (u.Path == "" || u.Path == "/")
+}
+
+// resolveVersion returns the manifest path of the newest version of the referenced agent satisfying the version constraint
+func (a *AgentSpecBuilder) resolveVersion(ctx context.Context, ref manifests.AgentReference) (string, error) {
+	log := zerolog.Ctx(ctx)
+
+	lister, err := a.getVersionLister(ref)
+	if err != nil {
+		return "", err
+	}
+	if ref.Name == "" {
+		return "", fmt.Errorf("agent name is required to resolve the version of a dependency without url")
+	}
+
+	constraint := ref.Version
+	if constraint == "" {
+		constraint = "*"
+	}
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return "", fmt.Errorf("invalid version constraint %s for agent %s: %v", constraint, ref.Name, err)
+	}
+
+	versions, err := lister.listVersions(ctx, ref.Name, constraint)
+	if err != nil {
+		return "", fmt.Errorf("failed to list versions of agent %s: %v", ref.Name, err)
+	}
+	var newest *agentVersion
+	for i, v := range versions {
+		if !c.Check(v.version) {
+			continue
+		}
+		if newest == nil || v.version.GreaterThan(newest.version) {
+			newest = &versions[i]
+		}
+	}
+	if newest == nil {
+		return "", fmt.Errorf("no version of agent %s satisfies version constraint %s", ref.Name, constraint)
+	}
+	log.Debug().Msgf("resolved agent %s %s to version %s (%s)", ref.Name, constraint, newest.version, newest.manifestPath)
+	return newest.manifestPath, nil
+}
+
+func (a *AgentSpecBuilder) getVersionLister(ref manifests.AgentReference) (versionLister, error) {
+	if a.versionLister != nil {
+		return a.versionLister, nil
+	}
+	if ref.Url == nil {
+		directoryURL := os.Getenv("DIRECTORY_URL")
+		if directoryURL == "" {
+			directoryURL = client.DefaultServerAddress
+		}
+		return &directoryVersionLister{directoryURL: directoryURL, cache: a.Cache}, nil
+	}
+	u, err := url.Parse(*ref.Url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid agent reference url: %v", err)
+	}
+	return &hubVersionLister{host: u.Host}, nil
+}
+
+// directoryVersionLister finds the versions of an agent in the agent records published in the directory.
+// The directory can't be queried by agent name, so records are pulled (served from the cache when present,
+// as records are content addressed) and matched by name before their manifest is processed.
+type directoryVersionLister struct {
+	directoryURL string
+	cache        *cache.Cache
+}
+
+// directoryRecord holds the fields of an agent record needed to match it against a dependency
+type directoryRecord struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+func (l *directoryVersionLister) listVersions(ctx context.Context, name string, _ string) ([]agentVersion, error) {
+	log := zerolog.Ctx(ctx)
+
+	if l.cache.Offline() {
+		return nil, fmt.Errorf("the directory can't be searched in offline mode")
+	}
+	dirClient, err := client.New(client.WithConfig(&client.Config{
+		ServerAddress: l.directoryURL,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create directory client: %s", err)
+	}
+	items, err := dirClient.List(ctx, &routingtypes.ListRequest{
+		Labels: []string{directoryAgentsLabel},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agents in directory: %s", err)
+	}
+
+	versions := make([]agentVersion, 0)
+	for item := range items {
+		if item.GetRecord() == nil {
+			continue
+		}
+		digest := item.GetRecord().GetDigest()
+		record, err := l.loadRecord(ctx, digest)
+		if err != nil {
+			// a broken record of another agent must not prevent resolving this one
+			log.Warn().Err(err).Str("digest", digest).Msg("skipping directory record")
+			continue
+		}
+		if record.Name != name {
+			continue
+		}
+		version, err := semver.NewVersion(record.Version)
+		if err != nil {
+			log.Warn().Str("digest", digest).Msgf("skipping record of agent %s without semantic version: %s", name, record.Version)
+			continue
+		}
+		versions = append(versions, agentVersion{version: version, manifestPath: digest})
+	}
+	return versions, nil
+}
+
+func (l *directoryVersionLister) loadRecord(ctx context.Context, digest string) (directoryRecord, error) {
+	loader := &directoryManifestLoader{
+		digest:       digest,
+		directoryURL: l.directoryURL,
+		cache:        l.cache,
+	}
+	data, err := loader.loadManifest(ctx)
+	if err != nil {
+		return directoryRecord{}, err
+	}
+	var record directoryRecord
+	if err = json.Unmarshal(data, &record); err != nil {
+		return directoryRecord{}, fmt.Errorf("failed to unmarshal agent record: %v", err)
+	}
+	return record, nil
+}
+
+// hubVersionLister looks up agents in the hub by repository name and version.
+// The hub doesn't provide an api to list the versions of a repository, so only constraints matching a single version
+// (1.2.3, =1.2.3 or v1.2.3) can be resolved, ranges are rejected with an error explaining how to pin the dependency.
+type hubVersionLister struct {
+	host string
+}
+
+func (l *hubVersionLister) listVersions(_ context.Context, name string, constraint string) ([]agentVersion, error) {
+	exact := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(constraint), "="))
+	version, err := semver.StrictNewVersion(strings.TrimPrefix(exact, "v"))
+	if err != nil {
+		return nil, fmt.Errorf("the hub can't list the versions of agent %s to resolve version constraint %s, "+
+			"reference an exact version (e.g. 1.2.3) or the digest of the agent (hub://%s/<digest>)", name, constraint, l.host)
+	}
+	return []agentVersion{{
+		version:      version,
+		manifestPath: fmt.Sprintf("hub://%s/%s@%s", l.host, name, exact),
+	}}, nil
+}
@@ -0,0 +1,176 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+package manifest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cisco-eti/wfsm/manifests"
+)
+
+type fakeVersionLister struct {
+	versions map[string]string
+}
+
+func (f *fakeVersionLister) listVersions(_ context.Context, _ string, _ string) ([]agentVersion, error) {
+	versions := make([]agentVersion, 0, len(f.versions))
+	for version, manifestPath := range f.versions {
+		versions = append(versions, agentVersion{version: semver.MustParse(version), manifestPath: manifestPath})
+	}
+	return versions, nil
+}
+
+func TestCheckVersionConstraint(t *testing.T) {
+	tests := []struct {
+		name       string
+		constraint string
+		version    string
+		wantErr    bool
+	}{
+		{name: "no constraint", constraint: "", version: "0.0.1"},
+		{name: "exact version", constraint: "1.0.0", version: "1.0.0"},
+		{name: "exact version mismatch", constraint: "1.0.0", version: "1.0.1", wantErr: true},
+		{name: "caret range", constraint: "^1.2", version: "1.9.0"},
+		{name: "caret range excludes next major", constraint: "^1.2", version: "2.0.0", wantErr: true},
+		{name: "tilde range", constraint: "~1.2.0", version: "1.2.5"},
+		{name: "comparison range", constraint: ">=1.0.0 <2.0.0", version: "1.5.0"},
+		{name: "invalid constraint", constraint: "latest", version: "1.0.0", wantErr: true},
+		{name: "invalid version", constraint: "^1.0", version: "one", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkVersionConstraint("agent_B_1", tt.constraint, tt.version)
+			assert.Equal(t, tt.wantErr, err != nil, "checkVersionConstraint() error = %v", err)
+		})
+	}
+}
+
+func TestAgentSpecBuilder_Version_Resolution(t *testing.T) {
+	builder := NewAgentSpecBuilder()
+	builder.versionLister = &fakeVersionLister{versions: map[string]string{
+		"1.0.0": "test/manifest_7/agent_B_1.0.0.json",
+		"1.2.0": "test/manifest_7/agent_B_1.2.0.json",
+		"2.0.0": "test/manifest_7/agent_B_2.0.0.json",
+	}}
+
+	err := builder.BuildAgentSpec(context.Background(), "test/manifest_7/agent_A_manifest.json", "", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.0", builder.AgentSpecs["agent_B_1"].Manifest.Version)
+	assert.Equal(t, "test/manifest_7/agent_B_1.2.0.json", builder.AgentSpecs["agent_B_1"].ManifestPath)
+
+	builder = NewAgentSpecBuilder()
+	builder.versionLister = &fakeVersionLister{versions: map[string]string{
+		"2.0.0": "test/manifest_7/agent_B_2.0.0.json",
+	}}
+	err = builder.BuildAgentSpec(context.Background(), "test/manifest_7/agent_A_manifest.json", "", nil, nil)
+	assert.ErrorContains(t, err, "no version of agent agent_B satisfies version constraint ^1.0")
+}
+
+func TestAgentSpecBuilder_Version_Constraint_Mismatch(t *testing.T) {
+	absPath, err := filepath.Abs("test/manifest_7/agent_B_2.0.0.json")
+	assert.NoError(t, err)
+	manifestPath := writeManifestWithDependencyUrl(t, t.TempDir(), absPath)
+
+	// mismatches of references with url are only reported until manifests are migrated
+	builder := NewAgentSpecBuilder()
+	assert.NoError(t, builder.BuildAgentSpec(context.Background(), manifestPath, "", nil, nil))
+	assert.Equal(t, "2.0.0", builder.AgentSpecs["agent_B_1"].Manifest.Version)
+
+	t.Setenv(strictVersionsEnv, "true")
+	err = NewAgentSpecBuilder().BuildAgentSpec(context.Background(), manifestPath, "", nil, nil)
+	var versionErr *VersionConstraintError
+	assert.True(t, errors.As(err, &versionErr), "expected version constraint error, got %v", err)
+	assert.EqualError(t, err, "version 2.0.0 of agent dependency agent_B_1 does not satisfy version constraint ^1.0")
+}
+
+func TestHubVersionLister(t *testing.T) {
+	lister := &hubVersionLister{host: "hub.example.com"}
+	for _, constraint := range []string{"1.2.3", "=1.2.3", "v1.2.3"} {
+		versions, err := lister.listVersions(context.Background(), "org/agent_B", constraint)
+		assert.NoError(t, err)
+		assert.Len(t, versions, 1)
+		assert.Equal(t, "1.2.3", versions[0].version.String())
+	}
+	versions, _ := lister.listVersions(context.Background(), "org/agent_B", "=1.2.3")
+	assert.Equal(t, "hub://hub.example.com/org/agent_B@1.2.3", versions[0].manifestPath)
+
+	_, err := lister.listVersions(context.Background(), "org/agent_B", "^1.2")
+	assert.ErrorContains(t, err, "reference an exact version")
+}
+
+func TestAgentSpecBuilder_LockFile(t *testing.T) {
+	t.Setenv("WFSM_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials.yaml"))
+
+	served := "test/manifest_7/agent_B_1.2.0.json"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, served)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	manifestPath := writeManifestWithDependencyUrl(t, dir, server.URL+"/agent_B.json")
+	lockFilePath := GetLockFilePath(manifestPath)
+	assert.Equal(t, filepath.Join(dir, LockFileName), lockFilePath)
+
+	// first build pins the remote dependency
+	lockFile, err := LoadLockFile(lockFilePath)
+	assert.NoError(t, err)
+	builder := NewAgentSpecBuilder()
+	builder.Lock = lockFile
+	assert.NoError(t, builder.BuildAgentSpec(context.Background(), manifestPath, "", nil, nil))
+	assert.True(t, lockFile.Changed())
+	assert.NoError(t, lockFile.Write())
+
+	lockFile, err = LoadLockFile(lockFilePath)
+	assert.NoError(t, err)
+	locked := lockFile.Dependencies["agent_A/agent_B_1"]
+	assert.Equal(t, server.URL+"/agent_B.json", locked.Resolved)
+	assert.Equal(t, "1.2.0", locked.ResolvedVersion)
+	assert.Equal(t, "^1.0", locked.Version)
+	assert.NotEmpty(t, locked.Digest)
+
+	// the pinned manifest is used as long as it doesn't change
+	builder = NewAgentSpecBuilder()
+	builder.Lock = lockFile
+	assert.NoError(t, builder.BuildAgentSpec(context.Background(), manifestPath, "", nil, nil))
+	assert.False(t, lockFile.Changed())
+
+	// a changed manifest is rejected until the lock file is updated
+	served = "test/manifest_7/agent_B_1.0.0.json"
+	builder = NewAgentSpecBuilder()
+	builder.Lock = lockFile
+	err = builder.BuildAgentSpec(context.Background(), manifestPath, "", nil, nil)
+	assert.ErrorContains(t, err, "does not match the digest")
+
+	// updating starts from an empty lock file
+	lockFile = NewLockFile(lockFilePath)
+	builder = NewAgentSpecBuilder()
+	builder.Lock = lockFile
+	assert.NoError(t, builder.BuildAgentSpec(context.Background(), manifestPath, "", nil, nil))
+	assert.Equal(t, "1.0.0", lockFile.Dependencies["agent_A/agent_B_1"].ResolvedVersion)
+}
+
+// writeManifestWithDependencyUrl writes the agent_A manifest of test/manifest_7 to dir with the url of its dependency set
+func writeManifestWithDependencyUrl(t *testing.T, dir string, url string) string {
+	data, err := os.ReadFile("test/manifest_7/agent_A_manifest.json")
+	assert.NoError(t, err)
+	var manifest manifests.AgentManifest
+	assert.NoError(t, json.Unmarshal(data, &manifest))
+	manifest.Extensions[0].Data.Deployment.AgentDeps[0].Ref.Url = &url
+
+	data, err = json.Marshal(manifest)
+	assert.NoError(t, err)
+	manifestPath := filepath.Join(dir, "agent_A_manifest.json")
+	assert.NoError(t, os.WriteFile(manifestPath, data, 0644))
+	return manifestPath
+}
@@ -21,10 +21,11 @@ var _ MappedNullable = &EnvVar{}
 
 // EnvVar Describes an environment variable
 type EnvVar struct {
-	Desc         string  `json:"desc"`
-	Name         string  `json:"name"`
-	Required     *bool   `json:"required,omitempty"`
-	DefaultValue *string `json:"defaultValue,omitempty"`
+	Desc         string            `json:"desc"`
+	Name         string            `json:"name"`
+	Required     *bool             `json:"required,omitempty"`
+	DefaultValue *string           `json:"defaultValue,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
 }
 
 // NewEnvVar instantiates a new EnvVar object
@@ -158,6 +159,38 @@ func (o *EnvVar) SetDefaultValue(v string) {
 	o.DefaultValue = &v
 }
 
+// GetAnnotations returns the Annotations field value if set, zero value otherwise.
+func (o *EnvVar) GetAnnotations() map[string]string {
+	if o == nil || IsNil(o.Annotations) {
+		var ret map[string]string
+		return ret
+	}
+	return o.Annotations
+}
+
+// GetAnnotationsOk returns a tuple with the Annotations field value if set, nil otherwise
+// and a boolean to check if the value has been set.
+func (o *EnvVar) GetAnnotationsOk() (map[string]string, bool) {
+	if o == nil || IsNil(o.Annotations) {
+		return map[string]string{}, false
+	}
+	return o.Annotations, true
+}
+
+// HasAnnotations returns a boolean if a field has been set.
+func (o *EnvVar) HasAnnotations() bool {
+	if o != nil && !IsNil(o.Annotations) {
+		return true
+	}
+
+	return false
+}
+
+// SetAnnotations gets a reference to the given map[string]string and assigns it to the Annotations field.
+func (o *EnvVar) SetAnnotations(v map[string]string) {
+	o.Annotations = v
+}
+
 func (o EnvVar) MarshalJSON() ([]byte, error) {
 	toSerialize, err := o.ToMap()
 	if err != nil {
@@ -176,6 +209,9 @@ func (o EnvVar) ToMap() (map[string]interface{}, error) {
 	if !IsNil(o.DefaultValue) {
 		toSerialize["defaultValue"] = o.DefaultValue
 	}
+	if !IsNil(o.Annotations) {
+		toSerialize["annotations"] = o.Annotations
+	}
 	return toSerialize, nil
 }
 
@@ -19,8 +19,25 @@ import (
 
 // SourceCodeDeploymentFrameworkConfig - struct for SourceCodeDeploymentFrameworkConfig
 type SourceCodeDeploymentFrameworkConfig struct {
-	LangGraphConfig  *LangGraphConfig
-	LlamaIndexConfig *LlamaIndexConfig
+	AutoGenConfig        *AutoGenConfig
+	CrewAIConfig         *CrewAIConfig
+	LangGraphConfig      *LangGraphConfig
+	LlamaIndexConfig     *LlamaIndexConfig
+	PythonCallableConfig *PythonCallableConfig
+}
+
+// AutoGenConfigAsSourceCodeDeploymentFrameworkConfig is a convenience function that returns AutoGenConfig wrapped in SourceCodeDeploymentFrameworkConfig
+func AutoGenConfigAsSourceCodeDeploymentFrameworkConfig(v *AutoGenConfig) SourceCodeDeploymentFrameworkConfig {
+	return SourceCodeDeploymentFrameworkConfig{
+		AutoGenConfig: v,
+	}
+}
+
+// CrewAIConfigAsSourceCodeDeploymentFrameworkConfig is a convenience function that returns CrewAIConfig wrapped in SourceCodeDeploymentFrameworkConfig
+func CrewAIConfigAsSourceCodeDeploymentFrameworkConfig(v *CrewAIConfig) SourceCodeDeploymentFrameworkConfig {
+	return SourceCodeDeploymentFrameworkConfig{
+		CrewAIConfig: v,
+	}
 }
 
 // LangGraphConfigAsSourceCodeDeploymentFrameworkConfig is a convenience function that returns LangGraphConfig wrapped in SourceCodeDeploymentFrameworkConfig
@@ -37,10 +54,43 @@ func LlamaIndexConfigAsSourceCodeDeploymentFrameworkConfig(v *LlamaIndexConfig)
 	}
 }
 
+// PythonCallableConfigAsSourceCodeDeploymentFrameworkConfig is a convenience function that returns PythonCallableConfig wrapped in SourceCodeDeploymentFrameworkConfig
+func PythonCallableConfigAsSourceCodeDeploymentFrameworkConfig(v *PythonCallableConfig) SourceCodeDeploymentFrameworkConfig {
+	return SourceCodeDeploymentFrameworkConfig{
+		PythonCallableConfig: v,
+	}
+}
+
 // Unmarshal JSON data into one of the pointers in the struct
 func (dst *SourceCodeDeploymentFrameworkConfig) UnmarshalJSON(data []byte) error {
 	var err error
 	match := 0
+	// try to unmarshal data into AutoGenConfig
+	err = newStrictDecoder(data).Decode(&dst.AutoGenConfig)
+	if err == nil {
+		jsonAutoGenConfig, _ := json.Marshal(dst.AutoGenConfig)
+		if string(jsonAutoGenConfig) == "{}" { // empty struct
+			dst.AutoGenConfig = nil
+		} else {
+			match++
+		}
+	} else {
+		dst.AutoGenConfig = nil
+	}
+
+	// try to unmarshal data into CrewAIConfig
+	err = newStrictDecoder(data).Decode(&dst.CrewAIConfig)
+	if err == nil {
+		jsonCrewAIConfig, _ := json.Marshal(dst.CrewAIConfig)
+		if string(jsonCrewAIConfig) == "{}" { // empty struct
+			dst.CrewAIConfig = nil
+		} else {
+			match++
+		}
+	} else {
+		dst.CrewAIConfig = nil
+	}
+
 	// try to unmarshal data into LangGraphConfig
 	err = newStrictDecoder(data).Decode(&dst.LangGraphConfig)
 	if err == nil {
@@ -67,10 +117,26 @@ func (dst *SourceCodeDeploymentFrameworkConfig) UnmarshalJSON(data []byte) error
 		dst.LlamaIndexConfig = nil
 	}
 
+	// try to unmarshal data into PythonCallableConfig
+	err = newStrictDecoder(data).Decode(&dst.PythonCallableConfig)
+	if err == nil {
+		jsonPythonCallableConfig, _ := json.Marshal(dst.PythonCallableConfig)
+		if string(jsonPythonCallableConfig) == "{}" { // empty struct
+			dst.PythonCallableConfig = nil
+		} else {
+			match++
+		}
+	} else {
+		dst.PythonCallableConfig = nil
+	}
+
 	if match > 1 { // more than 1 match
 		// reset to nil
+		dst.AutoGenConfig = nil
+		dst.CrewAIConfig = nil
 		dst.LangGraphConfig = nil
 		dst.LlamaIndexConfig = nil
+		dst.PythonCallableConfig = nil
 
 		return fmt.Errorf("data matches more than one schema in oneOf(SourceCodeDeploymentFrameworkConfig)")
 	} else if match == 1 {
@@ -82,6 +148,14 @@ func (dst *SourceCodeDeploymentFrameworkConfig) UnmarshalJSON(data []byte) error
 
 // Marshal data from the first non-nil pointers in the struct to JSON
 func (src SourceCodeDeploymentFrameworkConfig) MarshalJSON() ([]byte, error) {
+	if src.AutoGenConfig != nil {
+		return json.Marshal(&src.AutoGenConfig)
+	}
+
+	if src.CrewAIConfig != nil {
+		return json.Marshal(&src.CrewAIConfig)
+	}
+
 	if src.LangGraphConfig != nil {
 		return json.Marshal(&src.LangGraphConfig)
 	}
@@ -90,6 +164,10 @@ func (src SourceCodeDeploymentFrameworkConfig) MarshalJSON() ([]byte, error) {
 		return json.Marshal(&src.LlamaIndexConfig)
 	}
 
+	if src.PythonCallableConfig != nil {
+		return json.Marshal(&src.PythonCallableConfig)
+	}
+
 	return nil, nil // no data in oneOf schemas
 }
 
@@ -98,6 +176,14 @@ func (obj *SourceCodeDeploymentFrameworkConfig) GetActualInstance() interface{}
 	if obj == nil {
 		return nil
 	}
+	if obj.AutoGenConfig != nil {
+		return obj.AutoGenConfig
+	}
+
+	if obj.CrewAIConfig != nil {
+		return obj.CrewAIConfig
+	}
+
 	if obj.LangGraphConfig != nil {
 		return obj.LangGraphConfig
 	}
@@ -106,6 +192,10 @@ func (obj *SourceCodeDeploymentFrameworkConfig) GetActualInstance() interface{}
 		return obj.LlamaIndexConfig
 	}
 
+	if obj.PythonCallableConfig != nil {
+		return obj.PythonCallableConfig
+	}
+
 	// all schemas are nil
 	return nil
 }